@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	cfg "main/config"
+	"main/util"
+	"main/util/alarm"
+
+	"github.com/gin-gonic/gin"
+)
+
+// initAlarmEngine creates the alarm engine and binds transitions to handler scripts
+func initAlarmEngine(alarmConfig *cfg.AlarmConfig) error {
+	if !alarmConfig.Enable {
+		log.Printf("Alarm engine is disabled")
+		return nil
+	}
+	if err := alarm.Initialize(alarmConfig, util.RedisData); err != nil {
+		return err
+	}
+	alarm.ALARM_ENGINE.SetTransitionHandler(onAlarmTransition)
+	return nil
+}
+
+// 告警状态迁移时异步调用处理脚本
+func onAlarmTransition(a *alarm.Alarm, transition string) {
+	if a.Handler == "" {
+		return
+	}
+	params := map[string]interface{}{
+		"alarm":      a.ToMap(),
+		"transition": transition,
+	}
+	go func(handler string) {
+		if _, err := executeJavaScript(handler, params); err != nil {
+			log.Printf("Alarm handler '%s' failed for %s: %v", handler, a.ID, err)
+		}
+	}(a.Handler)
+}
+
+// AlarmManager handles HTTP requests for alarms
+type AlarmManager struct {
+	engine *alarm.Engine
+}
+
+func NewAlarmManager(engine *alarm.Engine) *AlarmManager {
+	return &AlarmManager{engine: engine}
+}
+
+// ListAlarms handles GET /alarms?state=&device=
+func (h *AlarmManager) ListAlarms(c *gin.Context) {
+	alarms := h.engine.List(c.Query("state"), c.Query("device"))
+	c.JSON(http.StatusOK, gin.H{
+		"alarms": alarms,
+	})
+}
+
+// ListAlarmHistory handles GET /alarms/history?limit=
+func (h *AlarmManager) ListAlarmHistory(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	alarms, err := h.engine.History(limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to load alarm history: %v", err),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"alarms": alarms,
+	})
+}
+
+// AcknowledgeAlarm handles POST /alarms/:id/ack
+func (h *AlarmManager) AcknowledgeAlarm(c *gin.Context) {
+	id := c.Param("id")
+
+	var body struct {
+		User string `json:"user"`
+	}
+	_ = c.ShouldBindJSON(&body)
+	if body.User == "" {
+		body.User = c.ClientIP()
+	}
+
+	a, err := h.engine.Acknowledge(id, body.User)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, a)
+}
+
+func SetupAlarmRoutes(router *gin.Engine, manager *AlarmManager) {
+	alarmGroup := router.Group("/alarms")
+	{
+		alarmGroup.GET("", manager.ListAlarms)
+		alarmGroup.GET("/history", manager.ListAlarmHistory)
+		alarmGroup.POST("/:id/ack", manager.AcknowledgeAlarm)
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
 	"main/util"
 	"main/util/config"
@@ -9,11 +10,19 @@ import (
 	gstrings "strings"
 )
 
+// Redis mirror of the configuration, see reconcileConfigMirror
+const (
+	REDIS_DEVICE_PREFIX   = "device_" // device_<type>: device name -> device JSON
+	REDIS_DEVICE_TYPE     = "DEVICE_TYPE"
+	REDIS_DEVICE_PROTOCOL = "DEVICE_PROTOCOL"
+	REDIS_DICT            = "DICT"
+)
+
 func onDeviceRemove(deviceName string) {
 	log.Printf("Device removed: %s", deviceName)
 
 	deviceType := getRealDeviceType(deviceName)
-	util.RedisData.HDel("device_"+deviceType, deviceName)
+	util.RedisData.HDel(REDIS_DEVICE_PREFIX+deviceType, deviceName)
 }
 
 func onDeviceUpdate(deviceConfig *config.DeviceConfig) {
@@ -26,7 +35,7 @@ func onDeviceUpdate(deviceConfig *config.DeviceConfig) {
 		log.Printf("Failed to marshal device config: %v", err)
 		return
 	}
-	err = util.RedisData.SetHValue("device_"+deviceType, deviceConfig.Name, string(jsonStr))
+	err = util.RedisData.SetHValue(REDIS_DEVICE_PREFIX+deviceType, deviceConfig.Name, string(jsonStr))
 	if err != nil {
 		log.Printf("Failed to set device config: %v", err)
 	}
@@ -40,7 +49,7 @@ func onDeviceTypeUpdate(deviceTypeConfig *config.DeviceTypeConfig) {
 		log.Printf("Failed to marshal device type config: %v", err)
 		return
 	}
-	err = util.RedisData.SetHValue("DEVICE_TYPE", deviceTypeConfig.TypeName, string(jsonStr))
+	err = util.RedisData.SetHValue(REDIS_DEVICE_TYPE, deviceTypeConfig.TypeName, string(jsonStr))
 	if err != nil {
 		log.Printf("Failed to set device type config: %v", err)
 	}
@@ -49,7 +58,7 @@ func onDeviceTypeUpdate(deviceTypeConfig *config.DeviceTypeConfig) {
 func onProtocolUpdate(csvName string, data string) {
 	log.Printf("Protocol updated: %s", csvName)
 
-	err := util.RedisData.SetHValue("DEVICE_PROTOCOL", csvName, data)
+	err := util.RedisData.SetHValue(REDIS_DEVICE_PROTOCOL, csvName, data)
 	if err != nil {
 		log.Printf("Failed to set protocol config: %v", err)
 	}
@@ -58,12 +67,53 @@ func onProtocolUpdate(csvName string, data string) {
 func onDictionaryUpdate(csvName string, data string) {
 	log.Printf("Dictionary updated: %s", csvName)
 
-	err := util.RedisData.SetHValue("DICT", csvName, data)
+	err := util.RedisData.SetHValue(REDIS_DICT, csvName, data)
 	if err != nil {
 		log.Printf("Failed to set dictionary config: %v", err)
 	}
 }
 
+const (
+	REDIS_DEVICE_CHANGES = "DEVICE_CHANGES:"
+	DEVICE_CHANGES_LIMIT = 100
+)
+
+// onDeviceChange keeps the latest changes of every device in Redis
+func onDeviceChange(change *config.DeviceChange) {
+	log.Printf("Device %s %s by %s: %d field(s) changed", change.Device, change.Action, change.User, len(change.Changes))
+
+	data, err := json.Marshal(change)
+	if err != nil {
+		log.Printf("Failed to marshal device change: %v", err)
+		return
+	}
+	key := REDIS_DEVICE_CHANGES + change.Device
+	if _, err := util.RedisData.LPush(key, string(data)); err != nil {
+		log.Printf("Failed to record device change: %v", err)
+		return
+	}
+	util.RedisData.LTrim(key, 0, DEVICE_CHANGES_LIMIT-1)
+}
+
+// getDeviceChanges returns the latest changes of a device, newest first
+func getDeviceChanges(device string, limit int) ([]*config.DeviceChange, error) {
+	if limit <= 0 || limit > DEVICE_CHANGES_LIMIT {
+		limit = DEVICE_CHANGES_LIMIT
+	}
+	entries, err := util.RedisData.LRange(REDIS_DEVICE_CHANGES+device, 0, int64(limit-1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read device changes: %w", err)
+	}
+	changes := make([]*config.DeviceChange, 0, len(entries))
+	for _, data := range entries {
+		var change config.DeviceChange
+		if err := json.Unmarshal([]byte(data), &change); err == nil {
+			changes = append(changes, &change)
+		}
+	}
+	return changes, nil
+}
+
 // 获取设备类型
 func getRealDeviceType(name string) string {
 	return gstrings.Split(name, "_")[0]
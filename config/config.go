@@ -25,12 +25,18 @@ type Config struct {
 	MySQLConfigs map[string]MySQLConfig `yaml:"-"` // Map of MySQL configs by name
 
 	// Grouped structure for YAML
-	App       AppConfig          `yaml:"app"`
-	Nacos     config.NacosConfig `yaml:"nacos"`
-	Database  DatabaseConfig     `yaml:"database"`
-	Messaging MessagingConfig    `yaml:"messaging"`
-	Web       WebConfig          `yaml:"web"`
-	Script    ScriptConfig       `yaml:"script"`
+	App       AppConfig             `yaml:"app"`
+	Nacos     config.NacosConfig    `yaml:"nacos"`
+	Provider  config.ProviderConfig `yaml:"provider"`
+	Database  DatabaseConfig        `yaml:"database"`
+	Messaging MessagingConfig       `yaml:"messaging"`
+	Web       WebConfig             `yaml:"web"`
+	Script    ScriptConfig          `yaml:"script"`
+	Alarm     AlarmConfig           `yaml:"alarm"`
+	History   HistoryConfig         `yaml:"history"`
+	Storage   StorageConfig         `yaml:"storage"`
+	Migration MigrationConfig       `yaml:"migration"`
+	Net       NetConfig             `yaml:"net"`
 }
 
 // syncFlatAndGrouped synchronizes between flat and grouped structures
@@ -76,13 +82,48 @@ type InfluxDBConfig struct {
 	TimeoutVal time.Duration `yaml:"-"`
 }
 
+const (
+	REDIS_MODE_STANDALONE = "standalone"
+	REDIS_MODE_SENTINEL   = "sentinel"
+	REDIS_MODE_CLUSTER    = "cluster"
+)
+
 // RedisConfig holds Redis connection details
 type RedisConfig struct {
-	Addr     string `yaml:"addr,omitempty"`
-	Password string `yaml:"password,omitempty"`
-	DB       int    `yaml:"db,omitempty"`
-	DBConfig int    `yaml:"dbConfig,omitempty"`
-	Enable   bool   `yaml:"enable,omitempty"`
+	Addr     string   `yaml:"addr,omitempty"`
+	Addrs    []string `yaml:"addrs,omitempty"` // cluster nodes or sentinels
+	Mode     string   `yaml:"mode,omitempty"`  // standalone, sentinel or cluster
+	Username string   `yaml:"username,omitempty"`
+	Password string   `yaml:"password,omitempty"`
+	DB       int      `yaml:"db,omitempty"`
+	DBConfig int      `yaml:"dbConfig,omitempty"`
+	Enable   bool     `yaml:"enable,omitempty"`
+
+	// Sentinel
+	MasterName       string `yaml:"masterName,omitempty"`
+	SentinelUsername string `yaml:"sentinelUsername,omitempty"`
+	SentinelPassword string `yaml:"sentinelPassword,omitempty"`
+
+	TLS RedisTLSConfig `yaml:"tls,omitempty"`
+
+	// Pool and timeouts, durations such as 500ms or 5s
+	PoolSize     int    `yaml:"poolSize,omitempty"`
+	MinIdleConns int    `yaml:"minIdleConns,omitempty"`
+	MaxRetries   int    `yaml:"maxRetries,omitempty"` // -1 disables retries
+	DialTimeout  string `yaml:"dialTimeout,omitempty"`
+	ReadTimeout  string `yaml:"readTimeout,omitempty"`
+	WriteTimeout string `yaml:"writeTimeout,omitempty"`
+	PoolTimeout  string `yaml:"poolTimeout,omitempty"`
+}
+
+// RedisTLSConfig enables TLS, optionally with a private CA and a client certificate
+type RedisTLSConfig struct {
+	Enable             bool   `yaml:"enable,omitempty"`
+	CAFile             string `yaml:"caFile,omitempty"`
+	CertFile           string `yaml:"certFile,omitempty"`
+	KeyFile            string `yaml:"keyFile,omitempty"`
+	ServerName         string `yaml:"serverName,omitempty"`
+	InsecureSkipVerify bool   `yaml:"insecureSkipVerify,omitempty"`
 }
 
 // MessagingConfig groups all messaging-related configurations
@@ -123,6 +164,13 @@ func DefaultConfig() Config {
 			Namespace:  "",
 			Group:      "DEFAULT_GROUP",
 			LogDir:     "./nacos",
+
+			ReconnectInterval: 10,
+		},
+		Provider: config.ProviderConfig{
+			Type:     config.PROVIDER_NACOS,
+			Dir:      "./nacos/data",
+			Interval: 5,
 		},
 		Database: DatabaseConfig{
 			MySQLList: []MySQLConfig{
@@ -158,6 +206,32 @@ func DefaultConfig() Config {
 		App: AppConfig{
 			Title: "Default",
 		},
+		Alarm: AlarmConfig{
+			Enable:  false,
+			History: 1000,
+		},
+		History: HistoryConfig{
+			Enable:    false,
+			Backend:   HISTORY_BACKEND_REDIS,
+			Database:  "default",
+			Table:     "device_history",
+			Retention: "720h",
+			MaxPoints: 10000,
+		},
+		Storage: StorageConfig{
+			Backend:          STORAGE_BACKEND_REDIS,
+			SnapshotInterval: 60,
+		},
+		Migration: MigrationConfig{
+			Dir:         "migrations",
+			Table:       "schema_migrations",
+			LockTimeout: 60,
+		},
+		Net: NetConfig{
+			Egress: EgressConfig{
+				Enable: true,
+			},
+		},
 	}
 
 	// Initialize the default MySQL config in the map
@@ -228,6 +302,15 @@ func processConfig() {
 		}
 	}
 
+	// Process history retention
+	if CONFIG.History.Retention != "" {
+		if retention, err := time.ParseDuration(CONFIG.History.Retention); err == nil {
+			CONFIG.History.RetentionVal = retention
+		} else {
+			log.Printf("Warning: Invalid history retention %s: %v", CONFIG.History.Retention, err)
+		}
+	}
+
 	// For backward compatibility, if MySQLConnString is set but not in MySQLList
 	// if CONFIG.MySQLConnString != "" {
 	// 	// Check if this connection string is already in the list
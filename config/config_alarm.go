@@ -0,0 +1,32 @@
+package config
+
+// AlarmConfig holds the alarm engine settings and rule definitions
+type AlarmConfig struct {
+	Enable  bool              `yaml:"enable,omitempty"`
+	Handler string            `yaml:"handler,omitempty"` // script invoked on every alarm transition
+	History int               `yaml:"history,omitempty"` // number of finished alarms kept in Redis
+	Rules   []AlarmRuleConfig `yaml:"rules,omitempty"`
+}
+
+// AlarmRuleConfig describes a single alarm rule.
+//
+// A rule applies to a device type, a single device or every device, and
+// watches one register key. The condition is one of:
+//   - threshold: high / low limits with an optional deadband for clearing
+//   - bit: raised when bit N of the register value equals bitValue
+//   - expr: a JavaScript expression evaluated with value, values, device and key
+type AlarmRuleConfig struct {
+	Name       string   `yaml:"name"`
+	DeviceType string   `yaml:"deviceType,omitempty"`
+	Device     string   `yaml:"device,omitempty"`
+	Key        string   `yaml:"key,omitempty"`
+	Severity   string   `yaml:"severity,omitempty"`
+	Message    string   `yaml:"message,omitempty"`
+	High       *float64 `yaml:"high,omitempty"`
+	Low        *float64 `yaml:"low,omitempty"`
+	Deadband   float64  `yaml:"deadband,omitempty"`
+	Bit        *int     `yaml:"bit,omitempty"`
+	BitValue   int      `yaml:"bitValue,omitempty"`
+	Expr       string   `yaml:"expr,omitempty"`
+	Handler    string   `yaml:"handler,omitempty"` // overrides AlarmConfig.Handler for this rule
+}
@@ -0,0 +1,19 @@
+package config
+
+import "time"
+
+const (
+	HISTORY_BACKEND_REDIS = "redis"
+	HISTORY_BACKEND_MYSQL = "mysql"
+)
+
+// HistoryConfig holds the device value history store settings
+type HistoryConfig struct {
+	Enable       bool          `yaml:"enable,omitempty"`
+	Backend      string        `yaml:"backend,omitempty"`   // redis or mysql
+	Database     string        `yaml:"database,omitempty"`  // named MySQL database for the mysql backend
+	Table        string        `yaml:"table,omitempty"`     // MySQL table name
+	Retention    string        `yaml:"retention,omitempty"` // e.g. 720h
+	RetentionVal time.Duration `yaml:"-"`
+	MaxPoints    int           `yaml:"maxPoints,omitempty"` // max raw points returned without a step
+}
@@ -0,0 +1,12 @@
+package config
+
+// MigrationConfig holds the schema migration settings
+type MigrationConfig struct {
+	Dir         string `yaml:"dir,omitempty"`         // migration directory, <dir>/<database>/<version>_<name>.sql|js
+	Table       string `yaml:"table,omitempty"`       // tracking table created in every migrated database
+	Auto        bool   `yaml:"auto,omitempty"`        // apply pending migrations at startup
+	LockTimeout int    `yaml:"lockTimeout,omitempty"` // seconds to wait for another node's migration
+	// StatementTimeout limits each migration statement in seconds; 0 (the
+	// default) applies none, DDL on large tables may run for a long time
+	StatementTimeout int `yaml:"statementTimeout,omitempty"`
+}
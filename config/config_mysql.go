@@ -15,8 +15,32 @@ type MySQLConfig struct {
 	Password   string
 	DB         string
 	Timeout    int
+	Timezone   string `yaml:"timezone,omitempty"` // 解析 DATETIME / TIMESTAMP 的时区，默认本地时区
+	BigInt     string `yaml:"bigint,omitempty"`   // 超出 JS 安全整数范围的整数：bigint（默认）| string
+	Decimal    string `yaml:"decimal,omitempty"`  // DECIMAL 列：string（默认，保留精度）| number
+	MaxRows    int    `yaml:"maxRows,omitempty"`  // 脚本 mysql.query 最多返回的行数，默认 10000，-1 不限制
+
+	Pool        MySQLPoolConfig `yaml:"pool,omitempty"`
+	Replicas    []string        `yaml:"replicas,omitempty"`    // 只读副本连接字符串，读查询轮询分发到健康的副本
+	HealthCheck int             `yaml:"healthCheck,omitempty"` // 副本健康检查间隔秒数，默认 10
+	SlowQuery   string          `yaml:"slowQuery,omitempty"`   // 慢查询日志阈值，默认 1s，off 关闭
+}
+
+// MySQLPoolConfig holds the connection pool settings, shared by the replicas
+type MySQLPoolConfig struct {
+	MaxOpen     int    `yaml:"maxOpen,omitempty"`     // 最大连接数，默认 25
+	MaxIdle     int    `yaml:"maxIdle,omitempty"`     // 最大空闲连接数，默认 5
+	MaxLifetime string `yaml:"maxLifetime,omitempty"` // 连接最长使用时间，默认 1h
+	MaxIdleTime string `yaml:"maxIdleTime,omitempty"` // 空闲连接保留时间，默认不限
 }
 
+const (
+	MYSQL_BIGINT_BIGINT  = "bigint"
+	MYSQL_BIGINT_STRING  = "string"
+	MYSQL_DECIMAL_STRING = "string"
+	MYSQL_DECIMAL_NUMBER = "number"
+)
+
 // ParseMySQLConnString parses a MySQL connection string and returns a MySQLConfig structure
 // Example: "root:password@tcp(localhost:3306)/test?parseTime=true&timeout=10s"
 func (c *MySQLConfig) ParseMySQLConnString(connString string) MySQLConfig {
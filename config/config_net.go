@@ -0,0 +1,21 @@
+package config
+
+// NetConfig holds the settings of outbound requests made by scripts
+type NetConfig struct {
+	Egress EgressConfig `yaml:"egress"`
+	// CertDir holds the certificate files scripts may name in the tls
+	// options of net.fetch; when empty scripts cannot use certificate files
+	CertDir string `yaml:"certDir,omitempty"`
+}
+
+// EgressConfig restricts the destinations of net.fetch. Rules are host
+// names (api.example.com, *.example.com), IPs or CIDRs, optionally with a
+// port (api.example.com:8443, 10.0.0.0/8:443, [fd00::1]:80).
+type EgressConfig struct {
+	Enable       bool                `yaml:"enable"`
+	Allow        []string            `yaml:"allow,omitempty"`        // 非空时只允许访问匹配的目标；匹配的私有地址视为例外放行
+	Deny         []string            `yaml:"deny,omitempty"`         // 始终拒绝，优先于 allow
+	AllowPrivate bool                `yaml:"allowPrivate,omitempty"` // 允许访问回环、内网、链路本地（含云元数据）等地址
+	Ports        []int               `yaml:"ports,omitempty"`        // 允许的端口，为空不限制
+	Capabilities map[string][]string `yaml:"capabilities,omitempty"` // 能力名 -> 额外放行的规则，脚本在元数据 capabilities 中声明
+}
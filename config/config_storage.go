@@ -0,0 +1,13 @@
+package config
+
+const (
+	STORAGE_BACKEND_REDIS  = "redis"
+	STORAGE_BACKEND_MEMORY = "memory"
+)
+
+// StorageConfig selects the key-value store behind util.RedisData / util.RedisConfig
+type StorageConfig struct {
+	Backend          string `yaml:"backend,omitempty"`          // redis or memory
+	SnapshotDir      string `yaml:"snapshotDir,omitempty"`      // memory: snapshot files <dir>/db<N>.json, empty disables snapshots
+	SnapshotInterval int    `yaml:"snapshotInterval,omitempty"` // memory: seconds between snapshots of changed data
+}
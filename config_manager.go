@@ -0,0 +1,290 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	cfg "main/config"
+	"main/util/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ConfigManager handles HTTP requests for the configuration center
+type ConfigManager struct {
+}
+
+func NewConfigManager() *ConfigManager {
+	return &ConfigManager{}
+}
+
+// GetHealth handles GET /health/config
+// Responds 503 while the configuration is not loaded, the source is
+// unreachable or some data IDs are served from the local snapshot
+func (h *ConfigManager) GetHealth(c *gin.Context) {
+	health := config.GetConfigHealth()
+	status := http.StatusOK
+	if !health.Healthy() {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, gin.H{
+		"healthy":       health.Healthy(),
+		"provider":      health.Provider,
+		"ready":         health.Ready,
+		"connected":     health.Connected,
+		"stale":         health.Stale,
+		"lastSync":      health.LastSync,
+		"lastChange":    health.LastChange,
+		"lastError":     health.LastError,
+		"droppedEvents": droppedConfigEvents.Load(),
+	})
+}
+
+// GetValidation handles GET /config/validation?dataId=
+// Returns the latest validation report of every data ID, or of one data ID
+func (h *ConfigManager) GetValidation(c *gin.Context) {
+	if dataId := c.Query("dataId"); dataId != "" {
+		report, ok := config.GetValidationReport(dataId)
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "No validation report for " + dataId,
+			})
+			return
+		}
+		c.JSON(http.StatusOK, report)
+		return
+	}
+
+	reports := config.GetValidationReports()
+	valid := true
+	for _, report := range reports {
+		if !report.Accepted || report.HasErrors() {
+			valid = false
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"valid":   valid,
+		"reports": reports,
+	})
+}
+
+// ReconcileMirror handles POST /config/mirror/reconcile?dryRun=true
+// Syncs the Redis config mirror with the loaded configuration and reports
+// the fields it changed, or would change in a dry run
+func (h *ConfigManager) ReconcileMirror(c *gin.Context) {
+	dryRun, _ := strconv.ParseBool(c.Query("dryRun"))
+	report, err := reconcileConfigMirror(dryRun)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":  err.Error(),
+			"report": report,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// PublishRequest is the body of the publish endpoints. MD5 is the md5 of the
+// content the edit is based on; publishing fails with 409 if it changed.
+// User (or the X-User header) is recorded in the device change history.
+type PublishRequest struct {
+	User       string                   `json:"user"`
+	Content    string                   `json:"content"`
+	MD5        string                   `json:"md5"`
+	ParentId   string                   `json:"parentId"`
+	DataId     string                   `json:"dataId"`
+	Device     *config.DeviceConfig     `json:"device"`
+	DeviceType *config.DeviceTypeConfig `json:"deviceType"`
+}
+
+// ConfigPage handles GET /config
+func (h *ConfigManager) ConfigPage(c *gin.Context) {
+	c.HTML(http.StatusOK, "config.html", gin.H{
+		"AppTitle": cfg.CONFIG.App.Title,
+	})
+}
+
+// ListData handles GET /config/data
+func (h *ConfigManager) ListData(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"data": config.ListConfigDocuments(),
+	})
+}
+
+// GetData handles GET /config/data/:dataId?parent=
+func (h *ConfigManager) GetData(c *gin.Context) {
+	doc, err := config.GetConfigDocument(c.Param("dataId"), c.Query("parent"))
+	if err != nil {
+		publishError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, doc)
+}
+
+// PublishData handles PUT /config/data/:dataId
+func (h *ConfigManager) PublishData(c *gin.Context) {
+	var req PublishRequest
+	if !bindPublishRequest(c, &req) {
+		return
+	}
+	doc, err := config.PublishConfig(c.Param("dataId"), req.ParentId, req.Content, req.MD5, requestUser(c, &req))
+	publishResult(c, doc, err)
+}
+
+// DeleteData handles DELETE /config/data/:dataId?parent=&md5=
+func (h *ConfigManager) DeleteData(c *gin.Context) {
+	dataId := c.Param("dataId")
+	if err := config.DeleteConfig(dataId, c.Query("parent"), c.Query("md5")); err != nil {
+		publishError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Config deleted",
+		"dataId":  dataId,
+	})
+}
+
+// SaveDevice handles PUT /config/devices/:name
+// Body: {"device": {...}, "dataId": "devices-a.json", "md5": "..."}; dataId is
+// only needed for new devices
+func (h *ConfigManager) SaveDevice(c *gin.Context) {
+	var req PublishRequest
+	if !bindPublishRequest(c, &req) {
+		return
+	}
+	if req.Device == nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Field 'device' is required",
+		})
+		return
+	}
+	req.Device.Name = c.Param("name")
+	doc, err := config.SaveDevice(req.Device, req.DataId, req.MD5, requestUser(c, &req))
+	publishResult(c, doc, err)
+}
+
+// DeleteDevice handles DELETE /config/devices/:name?md5=
+func (h *ConfigManager) DeleteDevice(c *gin.Context) {
+	doc, err := config.RemoveDevice(c.Param("name"), c.Query("md5"), requestUser(c, nil))
+	publishResult(c, doc, err)
+}
+
+// SaveDeviceType handles PUT /config/device-types/:name
+// Body: {"deviceType": {...}, "md5": "..."}
+func (h *ConfigManager) SaveDeviceType(c *gin.Context) {
+	var req PublishRequest
+	if !bindPublishRequest(c, &req) {
+		return
+	}
+	if req.DeviceType == nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Field 'deviceType' is required",
+		})
+		return
+	}
+	doc, err := config.SaveDeviceType(c.Param("name"), req.DeviceType, req.MD5, requestUser(c, &req))
+	publishResult(c, doc, err)
+}
+
+// DeleteDeviceType handles DELETE /config/device-types/:name?md5=
+func (h *ConfigManager) DeleteDeviceType(c *gin.Context) {
+	doc, err := config.RemoveDeviceType(c.Param("name"), c.Query("md5"), requestUser(c, nil))
+	publishResult(c, doc, err)
+}
+
+// SaveSubConfig returns PUT handlers for protocol and dictionary CSVs
+func (h *ConfigManager) SaveSubConfig(handler string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req PublishRequest
+		if !bindPublishRequest(c, &req) {
+			return
+		}
+		doc, err := config.PublishSubConfig(handler, c.Param("name"), req.Content, req.MD5, requestUser(c, &req))
+		publishResult(c, doc, err)
+	}
+}
+
+// DeleteSubConfig returns DELETE handlers for protocol and dictionary CSVs
+func (h *ConfigManager) DeleteSubConfig(handler string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param("name")
+		if err := config.DeleteSubConfig(handler, name, c.Query("md5")); err != nil {
+			publishError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Config deleted",
+			"dataId":  name,
+		})
+	}
+}
+
+func bindPublishRequest(c *gin.Context, req *PublishRequest) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body: " + err.Error(),
+		})
+		return false
+	}
+	return true
+}
+
+// requestUser returns the X-User header, the user of the body or the client IP
+func requestUser(c *gin.Context, req *PublishRequest) string {
+	if user := c.GetHeader("X-User"); user != "" {
+		return user
+	}
+	if req != nil && req.User != "" {
+		return req.User
+	}
+	return c.ClientIP()
+}
+
+func publishResult(c *gin.Context, doc *config.ConfigDocument, err error) {
+	if err != nil {
+		publishError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, doc)
+}
+
+func publishError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, config.ErrConfigInvalid):
+		status = http.StatusBadRequest
+	case errors.Is(err, config.ErrConfigNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, config.ErrConfigConflict):
+		status = http.StatusConflict
+	case errors.Is(err, config.ErrPublishNotSupported):
+		status = http.StatusNotImplemented
+	}
+	c.JSON(status, gin.H{
+		"error": err.Error(),
+	})
+}
+
+func SetupConfigRoutes(router *gin.Engine, manager *ConfigManager) {
+	router.GET("/health/config", manager.GetHealth)
+
+	configGroup := router.Group("/config")
+	{
+		configGroup.GET("", manager.ConfigPage)
+		configGroup.GET("/validation", manager.GetValidation)
+		configGroup.POST("/mirror/reconcile", manager.ReconcileMirror)
+		configGroup.GET("/data", manager.ListData)
+		configGroup.GET("/data/:dataId", manager.GetData)
+		configGroup.PUT("/data/:dataId", manager.PublishData)
+		configGroup.DELETE("/data/:dataId", manager.DeleteData)
+		configGroup.PUT("/devices/:name", manager.SaveDevice)
+		configGroup.DELETE("/devices/:name", manager.DeleteDevice)
+		configGroup.PUT("/device-types/:name", manager.SaveDeviceType)
+		configGroup.DELETE("/device-types/:name", manager.DeleteDeviceType)
+		configGroup.PUT("/protocols/:name", manager.SaveSubConfig(config.HANDLER_CSV_PROTOCOL))
+		configGroup.DELETE("/protocols/:name", manager.DeleteSubConfig(config.HANDLER_CSV_PROTOCOL))
+		configGroup.PUT("/dicts/:name", manager.SaveSubConfig(config.HANDLER_CSV_DICT))
+		configGroup.DELETE("/dicts/:name", manager.DeleteSubConfig(config.HANDLER_CSV_DICT))
+	}
+}
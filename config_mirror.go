@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+
+	"main/util"
+	"main/util/config"
+)
+
+const (
+	MIRROR_ADD    = "add"
+	MIRROR_UPDATE = "update"
+	MIRROR_REMOVE = "remove"
+)
+
+// MirrorChange is a field of the Redis mirror that was (or would be) changed
+type MirrorChange struct {
+	Key    string `json:"key"`
+	Field  string `json:"field"`
+	Action string `json:"action"`
+}
+
+// MirrorReport is the result of a reconciliation pass
+type MirrorReport struct {
+	DryRun  bool           `json:"dryRun"`
+	Checked int            `json:"checked"`
+	Changes []MirrorChange `json:"changes"`
+}
+
+// desiredConfigMirror computes the Redis mirror of the loaded configuration
+func desiredConfigMirror() (map[string]map[string]string, error) {
+	desired := map[string]map[string]string{
+		REDIS_DEVICE_TYPE:     {},
+		REDIS_DEVICE_PROTOCOL: config.GetAllProtocolConfig(),
+		REDIS_DICT:            config.GetAllDictionaryConfig(),
+	}
+
+	for _, device := range config.GetAllDeviceConfig() {
+		data, err := json.Marshal(device)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal device %s: %w", device.Name, err)
+		}
+		key := REDIS_DEVICE_PREFIX + getRealDeviceType(device.Name)
+		if desired[key] == nil {
+			desired[key] = make(map[string]string)
+		}
+		desired[key][device.Name] = string(data)
+	}
+	for _, typeConfig := range config.GetAllDeviceTypeConfig() {
+		data, err := json.Marshal(typeConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal device type %s: %w", typeConfig.TypeName, err)
+		}
+		desired[REDIS_DEVICE_TYPE][typeConfig.TypeName] = string(data)
+	}
+	return desired, nil
+}
+
+// reconcileConfigMirror brings the Redis mirror in line with the loaded
+// configuration: missing and drifted fields are written, orphans removed.
+// Fields of device_<type> hashes are only removed if they hold a device.
+func reconcileConfigMirror(dryRun bool) (*MirrorReport, error) {
+	if !config.CheckConfigReady() {
+		return nil, fmt.Errorf("configuration is not ready")
+	}
+	if util.RedisData == nil {
+		return nil, fmt.Errorf("redis is not connected")
+	}
+	desired, err := desiredConfigMirror()
+	if err != nil {
+		return nil, err
+	}
+
+	// 已有的 device_<type> 哈希也要检查，清理已不存在的类型
+	err = util.RedisData.ScanAll(REDIS_DEVICE_PREFIX+"*", 100, "hash", func(key string) bool {
+		if desired[key] == nil {
+			desired[key] = make(map[string]string)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan device hashes: %w", err)
+	}
+
+	report := &MirrorReport{DryRun: dryRun, Changes: make([]MirrorChange, 0)}
+	keys := make([]string, 0, len(desired))
+	for key := range desired {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		actual, err := util.RedisData.HGetAll(key)
+		if err != nil {
+			return report, fmt.Errorf("failed to read %s: %w", key, err)
+		}
+		want := desired[key]
+		report.Checked += len(want)
+
+		var changes []MirrorChange
+		values := make(map[string]interface{})
+		for field, value := range want {
+			current, exists := actual[field]
+			if !exists {
+				changes = append(changes, MirrorChange{Key: key, Field: field, Action: MIRROR_ADD})
+			} else if current != value {
+				changes = append(changes, MirrorChange{Key: key, Field: field, Action: MIRROR_UPDATE})
+			} else {
+				continue
+			}
+			values[field] = value
+		}
+		var orphans []string
+		for field, current := range actual {
+			if _, ok := want[field]; ok {
+				continue
+			}
+			if key != REDIS_DEVICE_TYPE && key != REDIS_DEVICE_PROTOCOL && key != REDIS_DICT && !isMirroredDevice(field, current) {
+				continue
+			}
+			orphans = append(orphans, field)
+			changes = append(changes, MirrorChange{Key: key, Field: field, Action: MIRROR_REMOVE})
+		}
+		sort.Slice(changes, func(i, j int) bool {
+			return changes[i].Field < changes[j].Field
+		})
+		report.Changes = append(report.Changes, changes...)
+
+		if dryRun {
+			continue
+		}
+		if len(values) > 0 {
+			if err := util.RedisData.HMSet(key, values); err != nil {
+				return report, fmt.Errorf("failed to update %s: %w", key, err)
+			}
+		}
+		if len(orphans) > 0 {
+			if _, err := util.RedisData.HDelFields(key, orphans...); err != nil {
+				return report, fmt.Errorf("failed to clean %s: %w", key, err)
+			}
+		}
+	}
+
+	log.Printf("Config mirror reconciled: %d entries checked, %d changed (dry run: %v)", report.Checked, len(report.Changes), dryRun)
+	return report, nil
+}
+
+// isMirroredDevice reports whether a hash field holds a device written by onDeviceUpdate
+func isMirroredDevice(field, value string) bool {
+	var device config.DeviceConfig
+	if err := json.Unmarshal([]byte(value), &device); err != nil {
+		return false
+	}
+	return device.Name == field
+}
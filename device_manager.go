@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	cfg "main/config"
+	"main/util/history"
+
+	"github.com/gin-gonic/gin"
+)
+
+// initHistoryStore creates the device value history store if enabled
+func initHistoryStore(historyConfig *cfg.HistoryConfig) error {
+	if !historyConfig.Enable {
+		log.Printf("History store is disabled")
+		return nil
+	}
+	return history.Initialize(historyConfig)
+}
+
+// DeviceManager handles HTTP requests for device data
+type DeviceManager struct {
+}
+
+func NewDeviceManager() *DeviceManager {
+	return &DeviceManager{}
+}
+
+// GetHistory handles GET /devices/:name/history?key=&from=&to=&step=
+func (h *DeviceManager) GetHistory(c *gin.Context) {
+	device := c.Param("name")
+	key := c.Query("key")
+	if key == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Query parameter 'key' is required",
+		})
+		return
+	}
+
+	to, err := history.ParseTime(c.Query("to"), time.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Invalid 'to': %v", err),
+		})
+		return
+	}
+	from, err := history.ParseTime(c.Query("from"), to.Add(-time.Hour))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Invalid 'from': %v", err),
+		})
+		return
+	}
+	step, err := history.ParseStep(c.Query("step"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Invalid 'step': %v", err),
+		})
+		return
+	}
+
+	result, err := history.Query(device, key, from, to, step)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to query history: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"device":    device,
+		"key":       key,
+		"from":      from.UnixMilli(),
+		"to":        to.UnixMilli(),
+		"data":      result.Data(),
+		"truncated": result.Truncated,
+	})
+}
+
+// GetChanges handles GET /devices/:name/changes?limit=
+// Returns the configuration change history of the device, newest first
+func (h *DeviceManager) GetChanges(c *gin.Context) {
+	device := c.Param("name")
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	changes, err := getDeviceChanges(device, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"device":  device,
+		"changes": changes,
+	})
+}
+
+func SetupDeviceRoutes(router *gin.Engine, manager *DeviceManager) {
+	deviceGroup := router.Group("/devices")
+	{
+		deviceGroup.GET("/:name/history", manager.GetHistory)
+		deviceGroup.GET("/:name/changes", manager.GetChanges)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"main/util/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DictManager handles HTTP requests for dictionaries
+type DictManager struct {
+}
+
+func NewDictManager() *DictManager {
+	return &DictManager{}
+}
+
+// ListDicts handles GET /dicts
+func (h *DictManager) ListDicts(c *gin.Context) {
+	names := config.GetDictionaryNames()
+	sort.Strings(names)
+	c.JSON(http.StatusOK, gin.H{
+		"dicts": names,
+	})
+}
+
+// GetDict handles GET /dicts/:name?column=value
+// Query parameters are used as a row filter
+func (h *DictManager) GetDict(c *gin.Context) {
+	name := c.Param("name")
+	table, ok := config.GetDictionaryTable(name)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": fmt.Sprintf("Dictionary '%s' not found", name),
+		})
+		return
+	}
+
+	filter := make(map[string]string)
+	for key, value := range c.Request.URL.Query() {
+		filter[key] = value[0]
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"name":       table.Name,
+		"columns":    table.Columns,
+		"keyColumns": table.KeyColumns,
+		"rows":       table.Filter(filter),
+		"errors":     table.Errors,
+	})
+}
+
+// LookupDict handles GET /dicts/:name/:key, composite keys are joined by "|"
+func (h *DictManager) LookupDict(c *gin.Context) {
+	name := c.Param("name")
+	table, ok := config.GetDictionaryTable(name)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": fmt.Sprintf("Dictionary '%s' not found", name),
+		})
+		return
+	}
+
+	key := c.Param("key")
+	row, ok := table.Lookup(strings.Split(key, config.DICT_KEY_SEPARATOR)...)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": fmt.Sprintf("Key '%s' not found in dictionary '%s'", key, name),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, row)
+}
+
+func SetupDictRoutes(router *gin.Engine, manager *DictManager) {
+	dictGroup := router.Group("/dicts")
+	{
+		dictGroup.GET("", manager.ListDicts)
+		dictGroup.GET("/:name", manager.GetDict)
+		dictGroup.GET("/:name/:key", manager.LookupDict)
+	}
+}
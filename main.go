@@ -5,8 +5,13 @@ import (
 	"log"
 	cfg "main/config"
 	"main/util"
+	"main/util/alarm"
 	"main/util/config"
+	"main/util/migrate"
+	"main/util/mysql"
+	"main/util/net"
 	"net/http"
+	"os"
 
 	"github.com/gin-gonic/gin"
 )
@@ -23,21 +28,57 @@ func main() {
 		DeviceTypeUpdateHandler: onDeviceTypeUpdate,
 		ProtocolUpdateHandler:   onProtocolUpdate,
 		DictionaryUpdateHandler: onDictionaryUpdate,
+		ConfigEventHandler:      onConfigEvent,
+		ScriptConfigHandler:     onScriptConfig,
+		DeviceChangeHandler:     onDeviceChange,
 	})
 
-	err := util.InitRedisClient()
+	err := util.InitStorage()
 	if err != nil {
-		log.Printf("Warning: Failed to connect to Redis: %v", err)
+		log.Printf("Warning: Failed to initialize storage: %v", err)
 		return
 	}
+	defer util.CloseStorage()
+
+	if err := net.Initialize(&cfg.CONFIG.Net); err != nil {
+		log.Printf("Warning: Invalid egress policy, using the default: %v", err)
+	}
+
+	if err := mysql.Initialize(); err != nil {
+		log.Printf("Warning: Failed to initialize MySQL: %v", err)
+	}
+
+	// app migrate status|up|rollback，执行完退出
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		initScriptPool(&scriptInitOnce, cfg.CONFIG.Script.GroupName)
+		code := runMigrateCommand(os.Args[2:])
+		util.CloseStorage()
+		os.Exit(code)
+	}
 
 	if _, err := initializeDeviceConfigs(); err != nil {
 		fmt.Printf("Error loading device configs: %v\n", err)
 		return
 	}
 
+	if _, err := reconcileConfigMirror(false); err != nil {
+		log.Printf("Warning: Failed to reconcile config mirror: %v", err)
+	}
+
 	initScriptPool(&scriptInitOnce, cfg.CONFIG.Script.GroupName)
 
+	if err := initMigrator(&cfg.CONFIG.Migration); err != nil {
+		log.Printf("Warning: Failed to run migrations: %v", err)
+	}
+
+	if err := initAlarmEngine(&cfg.CONFIG.Alarm); err != nil {
+		log.Printf("Warning: Failed to initialize alarm engine: %v", err)
+	}
+
+	if err := initHistoryStore(&cfg.CONFIG.History); err != nil {
+		log.Printf("Warning: Failed to initialize history store: %v", err)
+	}
+
 	// Initialize web server if enabled
 	var httpServer *http.Server
 	var webConfig = cfg.CONFIG.Web
@@ -60,6 +101,17 @@ func main() {
 			SetupScriptsRoutes(router, &fileConfig, SCRIPT_MANAGER)
 		}
 
+		if alarm.ALARM_ENGINE != nil {
+			SetupAlarmRoutes(router, NewAlarmManager(alarm.ALARM_ENGINE))
+		}
+		SetupDeviceRoutes(router, NewDeviceManager())
+		SetupDictRoutes(router, NewDictManager())
+		SetupConfigRoutes(router, NewConfigManager())
+		SetupMySQLRoutes(router, NewMySQLManager())
+		if migrate.MIGRATOR != nil {
+			SetupMigrationRoutes(router, NewMigrationManager(migrate.MIGRATOR))
+		}
+
 		router.GET("/", func(c *gin.Context) {
 			c.HTML(http.StatusOK, "index.html", gin.H{
 				"ScriptEndpoint": scriptConfig.Endpoint,
@@ -88,9 +140,9 @@ func main() {
 
 func initializeDeviceConfigs() ([]*config.DeviceConfig, error) {
 	var deviceConfigs []*config.DeviceConfig
-	// Initialize Nacos client and listener
-	if err := config.InitNacos(onConfigChange, &cfg.CONFIG.Nacos); err != nil {
-		log.Println("Failed to initialize Nacos client. Exiting.", err)
+	// Initialize configuration provider and listener
+	if err := initConfigProvider(&cfg.CONFIG.Provider); err != nil {
+		log.Printf("Failed to initialize %s configuration provider. Exiting. %v", cfg.CONFIG.Provider.Type, err)
 		return nil, err
 	}
 
@@ -105,3 +157,17 @@ func initializeDeviceConfigs() ([]*config.DeviceConfig, error) {
 
 	return deviceConfigs, nil
 }
+
+// initConfigProvider loads configuration from Nacos, a local directory or Redis
+func initConfigProvider(providerConfig *config.ProviderConfig) error {
+	switch providerConfig.Type {
+	case config.PROVIDER_FILE:
+		return config.InitFileProvider(onConfigChange, providerConfig, &cfg.CONFIG.Nacos)
+	case config.PROVIDER_REDIS:
+		return config.InitRedisProvider(onConfigChange, util.RedisConfig, providerConfig, &cfg.CONFIG.Nacos)
+	case config.PROVIDER_NACOS, "":
+		return config.InitNacos(onConfigChange, &cfg.CONFIG.Nacos)
+	default:
+		return fmt.Errorf("unknown configuration provider %s", providerConfig.Type)
+	}
+}
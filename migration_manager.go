@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+
+	cfg "main/config"
+	"main/util/migrate"
+
+	"github.com/gin-gonic/gin"
+)
+
+// initMigrator creates the migrator over the migration directory and the
+// migration scripts of the script store; with migration.auto pending
+// migrations are applied right away
+func initMigrator(migrationConfig *cfg.MigrationConfig) error {
+	if err := migrate.Initialize(migrationConfig, listMigrationScripts, runMigrationScript); err != nil {
+		return err
+	}
+	if !migrationConfig.Auto {
+		return nil
+	}
+	steps, err := migrate.MIGRATOR.Up("", "", false)
+	if len(steps) > 0 {
+		log.Printf("Applied %d migration(s) at startup", len(steps))
+	}
+	return err
+}
+
+// listMigrationScripts returns the scripts whose metadata declares a migration
+func listMigrationScripts() ([]*migrate.Unit, error) {
+	names, err := scriptPool.Cache.ListScripts()
+	if err != nil {
+		return nil, err
+	}
+	var units []*migrate.Unit
+	for _, name := range names {
+		meta, ok := scriptPool.Cache.GetMeta(name)
+		if !ok || meta == nil || meta.Migration == nil {
+			continue
+		}
+		if meta.Migration.Version == "" {
+			log.Printf("Migration script '%s' has no version, skipping", name)
+			continue
+		}
+		code, err := scriptPool.Cache.GetScript(name)
+		if err != nil {
+			return nil, err
+		}
+		units = append(units, migrate.NewScriptUnit(meta.Migration.DB, meta.Migration.Version, name, code))
+	}
+	return units, nil
+}
+
+// runMigrationScript runs up(db) or down(db) of a JS migration, db being the
+// mysql.db() handle of the migrated database
+func runMigrationScript(unit *migrate.Unit, direction string) error {
+	name := fmt.Sprintf("migration:%s:%s", unit.Database, unit.Version)
+	database, _ := json.Marshal(unit.Database)
+	code := unit.Code + fmt.Sprintf(`
+;(function () {
+	if (typeof %[1]s !== "function") {
+		throw new Error("migration has no %[1]s(db) function");
+	}
+	%[1]s(mysql.db(%[2]s));
+})();`, direction, database)
+
+	if err := scriptPool.SetScript(name, code); err != nil {
+		return err
+	}
+	if _, err := scriptPool.RunScript(name, nil); err != nil {
+		return err
+	}
+	return nil
+}
+
+// MigrationManager handles HTTP requests for schema migrations
+type MigrationManager struct {
+	migrator *migrate.Migrator
+}
+
+func NewMigrationManager(migrator *migrate.Migrator) *MigrationManager {
+	return &MigrationManager{migrator: migrator}
+}
+
+// Status handles GET /migrations?db=
+func (h *MigrationManager) Status(c *gin.Context) {
+	status, err := h.migrator.Status(c.Query("db"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"migrations": status,
+	})
+}
+
+// Up handles POST /migrations/up?db=&to=&dryRun=true
+// Applies pending migrations, up to version to when given
+func (h *MigrationManager) Up(c *gin.Context) {
+	dryRun, _ := strconv.ParseBool(c.Query("dryRun"))
+	steps, err := h.migrator.Up(c.Query("db"), c.Query("to"), dryRun)
+	h.respond(c, steps, err)
+}
+
+// Rollback handles POST /migrations/rollback?db=&steps=1&to=&dryRun=true
+// Reverts the last steps migrations, or all those after version to
+func (h *MigrationManager) Rollback(c *gin.Context) {
+	dryRun, _ := strconv.ParseBool(c.Query("dryRun"))
+	count, _ := strconv.Atoi(c.DefaultQuery("steps", "1"))
+	steps, err := h.migrator.Rollback(c.Query("db"), count, c.Query("to"), dryRun)
+	h.respond(c, steps, err)
+}
+
+func (h *MigrationManager) respond(c *gin.Context, steps []migrate.Step, err error) {
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+			"steps": steps,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"steps": steps,
+	})
+}
+
+func SetupMigrationRoutes(router *gin.Engine, manager *MigrationManager) {
+	migrationGroup := router.Group("/migrations")
+	{
+		migrationGroup.GET("", manager.Status)
+		migrationGroup.POST("/up", manager.Up)
+		migrationGroup.POST("/rollback", manager.Rollback)
+	}
+}
+
+// runMigrateCommand handles the migrate subcommand:
+//
+//	app migrate status|up|rollback [-db name] [-to version] [-steps n] [-dry-run]
+//
+// and prints the result as JSON. Returns the process exit code.
+func runMigrateCommand(args []string) int {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	db := flags.String("db", "", "named database, all databases with migrations if empty")
+	to := flags.String("to", "", "target version")
+	count := flags.Int("steps", 1, "number of migrations to roll back")
+	dryRun := flags.Bool("dry-run", false, "print the statements without running them")
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: migrate status|up|rollback [flags]")
+		flags.PrintDefaults()
+		return 2
+	}
+	command := args[0]
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if err := migrate.Initialize(&cfg.CONFIG.Migration, listMigrationScripts, runMigrationScript); err != nil {
+		fmt.Fprintf(os.Stderr, "migrate: %v\n", err)
+		return 1
+	}
+
+	var result interface{}
+	var err error
+	switch command {
+	case "status":
+		result, err = migrate.MIGRATOR.Status(*db)
+	case "up":
+		result, err = migrate.MIGRATOR.Up(*db, *to, *dryRun)
+	case "rollback":
+		result, err = migrate.MIGRATOR.Rollback(*db, *count, *to, *dryRun)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown migrate command %q, expected status, up or rollback\n", command)
+		return 2
+	}
+
+	output, _ := json.MarshalIndent(result, "", "  ")
+	fmt.Println(string(output))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migrate %s failed: %v\n", command, err)
+		return 1
+	}
+	return 0
+}
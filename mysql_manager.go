@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"main/util/mysql"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MySQLManager handles HTTP requests for the MySQL databases
+type MySQLManager struct{}
+
+func NewMySQLManager() *MySQLManager {
+	return &MySQLManager{}
+}
+
+// Stats handles GET /mysql/stats
+// Returns the connection pool statistics of every database and its replicas
+func (h *MySQLManager) Stats(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"databases": mysql.Stats(),
+	})
+}
+
+// Statements handles GET /mysql/statements?db=&script=&sort=total&limit=50
+// Returns per-statement counts, latency percentiles and errors; sort is one
+// of total, count, avg, max, p95, p99 or errors
+func (h *MySQLManager) Statements(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	c.JSON(http.StatusOK, gin.H{
+		"statements": mysql.STATEMENT_STATS.Statements(c.Query("db"), c.Query("script"), c.Query("sort"), limit),
+		"dropped":    mysql.STATEMENT_STATS.Dropped(),
+	})
+}
+
+// ResetStatements handles DELETE /mysql/statements
+func (h *MySQLManager) ResetStatements(c *gin.Context) {
+	mysql.STATEMENT_STATS.Reset()
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Statement statistics reset",
+	})
+}
+
+// SlowQueries handles GET /mysql/slow
+// Returns the most recent slow queries, newest first
+func (h *MySQLManager) SlowQueries(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"queries": mysql.STATEMENT_STATS.SlowQueries(),
+	})
+}
+
+func SetupMySQLRoutes(router *gin.Engine, manager *MySQLManager) {
+	mysqlGroup := router.Group("/mysql")
+	{
+		mysqlGroup.GET("/stats", manager.Stats)
+		mysqlGroup.GET("/statements", manager.Statements)
+		mysqlGroup.DELETE("/statements", manager.ResetStatements)
+		mysqlGroup.GET("/slow", manager.SlowQueries)
+	}
+}
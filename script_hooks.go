@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"main/util/config"
+	"sync/atomic"
+)
+
+// 配置变更事件按顺序投递给订阅脚本，避免阻塞 Nacos 回调
+var configEventQueue = make(chan *config.ConfigEvent, 1024)
+
+// droppedConfigEvents counts the events dropped because the queue was full
+var droppedConfigEvents atomic.Uint64
+
+// scriptPoolReady is set once scriptPool is built, config callbacks running
+// on other goroutines check it before touching the pool
+var scriptPoolReady atomic.Bool
+
+// startConfigEvents is called by initScriptPool once the pool exists. Events
+// of the initial configuration load come before that and are dropped, the
+// scripts see that configuration when they run.
+func startConfigEvents() {
+	scriptPoolReady.Store(true)
+	go dispatchConfigEvents()
+}
+
+// onConfigEvent queues a configuration change for the subscribed scripts
+func onConfigEvent(event *config.ConfigEvent) {
+	if !scriptPoolReady.Load() {
+		return
+	}
+	select {
+	case configEventQueue <- event:
+	default:
+		dropped := droppedConfigEvents.Add(1)
+		log.Printf("Config event queue is full, dropping %s %s (%d dropped so far)", event.Type, event.Name, dropped)
+	}
+}
+
+func dispatchConfigEvents() {
+	for event := range configEventQueue {
+		subscribers := scriptPool.Cache.FindSubscribers(event.Type)
+		if len(subscribers) == 0 {
+			continue
+		}
+
+		params := map[string]interface{}{
+			"event": configEventToMap(event),
+		}
+		for _, name := range subscribers {
+			if _, err := executeJavaScript(name, params); err != nil {
+				log.Printf("Script hook '%s' failed for %s %s: %v", name, event.Type, event.Name, err)
+			}
+		}
+	}
+}
+
+// configEventToMap converts the event into plain values for scripts
+func configEventToMap(event *config.ConfigEvent) map[string]interface{} {
+	result := make(map[string]interface{})
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Failed to marshal config event %s: %v", event.Type, err)
+		return result
+	}
+	json.Unmarshal(data, &result)
+	return result
+}
+
+// onScriptConfig runs the script bound to a data ID through the script handler
+func onScriptConfig(name, dataId, data string) error {
+	if !scriptPoolReady.Load() {
+		log.Printf("Script pool not ready, config %s for script '%s' is kept as raw config only", dataId, name)
+		return nil
+	}
+	_, err := executeJavaScript(name, map[string]interface{}{
+		"dataId": dataId,
+		"data":   data,
+	})
+	return err
+}
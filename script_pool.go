@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"log"
 	"main/util"
-	"main/util/mysql"
 	"main/util/script"
 	"sync"
 )
@@ -23,10 +22,36 @@ func initScriptPool(once *sync.Once, poolName string) {
 		scriptPool.Inject("console.error", script.Console_error)
 
 		// Inject Redis functions
+		scriptPool.Inject("redis.use", script.Redis_use)
 		scriptPool.Inject("redis.set", script.Redis_set)
 		scriptPool.Inject("redis.get", script.Redis_get)
+		scriptPool.Inject("redis.del", script.Redis_del)
+		scriptPool.Inject("redis.exists", script.Redis_exists)
+		scriptPool.Inject("redis.expire", script.Redis_expire)
+		scriptPool.Inject("redis.persist", script.Redis_persist)
+		scriptPool.Inject("redis.ttl", script.Redis_ttl)
+		scriptPool.Inject("redis.incr", script.Redis_incr)
+		scriptPool.Inject("redis.decr", script.Redis_decr)
+		scriptPool.Inject("redis.command", script.Redis_command)
+
+		// Inject Redis hash operations
 		scriptPool.Inject("redis.keys", script.Redis_keys)
 		scriptPool.Inject("redis.hgetall", script.Redis_hgetall)
+		scriptPool.Inject("redis.hget", script.Redis_hget)
+		scriptPool.Inject("redis.hset", script.Redis_hset)
+		scriptPool.Inject("redis.hmget", script.Redis_hmget)
+		scriptPool.Inject("redis.hmset", script.Redis_hmset)
+		scriptPool.Inject("redis.hdel", script.Redis_hdel)
+		scriptPool.Inject("redis.hincr", script.Redis_hincr)
+
+		// Inject Redis list operations
+		scriptPool.Inject("redis.lpush", script.Redis_lpush)
+		scriptPool.Inject("redis.rpush", script.Redis_rpush)
+		scriptPool.Inject("redis.lpop", script.Redis_lpop)
+		scriptPool.Inject("redis.rpop", script.Redis_rpop)
+		scriptPool.Inject("redis.lrange", script.Redis_lrange)
+		scriptPool.Inject("redis.llen", script.Redis_llen)
+		scriptPool.Inject("redis.ltrim", script.Redis_ltrim)
 
 		// Inject Redis set operations
 		scriptPool.Inject("redis.sadd", script.Redis_sadd)
@@ -34,21 +59,64 @@ func initScriptPool(once *sync.Once, poolName string) {
 		scriptPool.Inject("redis.scard", script.Redis_scard)
 		scriptPool.Inject("redis.smembers", script.Redis_smembers)
 
-		// Inject MySQL functions
-		if mysql.MYSQL_CLIENT != nil {
-			scriptPool.Inject("mysql.query", script.MySQL_query)
-			scriptPool.Inject("mysql.exec", script.MySQL_exec)
-			scriptPool.Inject("mysql.queryRow", script.MySQL_queryRow)
-			scriptPool.Inject("mysql.transaction", script.MySQL_transaction)
-		}
+		// Inject Redis sorted set operations
+		scriptPool.Inject("redis.zadd", script.Redis_zadd)
+		scriptPool.Inject("redis.zrem", script.Redis_zrem)
+		scriptPool.Inject("redis.zscore", script.Redis_zscore)
+		scriptPool.Inject("redis.zincrby", script.Redis_zincrby)
+		scriptPool.Inject("redis.zcard", script.Redis_zcard)
+		scriptPool.Inject("redis.zrange", script.Redis_zrange)
+		scriptPool.Inject("redis.zrangebyscore", script.Redis_zrangebyscore)
+
+		// Inject Redis scan, scripting, batching and pub/sub
+		scriptPool.Inject("redis.scan", script.Redis_scan)
+		scriptPool.Inject("redis.eval", script.Redis_eval)
+		scriptPool.Inject("redis.pipeline", script.Redis_pipeline)
+		scriptPool.Inject("redis.multi", script.Redis_multi)
+		scriptPool.Inject("redis.transaction", script.Redis_multi)
+		scriptPool.Inject("redis.publish", script.Redis_publish)
+
+		// Inject MySQL functions (calls fail with an error if MySQL is not configured)
+		scriptPool.Inject("mysql.db", script.MySQL_db)
+		scriptPool.Inject("mysql.query", script.MySQL_query)
+		scriptPool.Inject("mysql.exec", script.MySQL_exec)
+		scriptPool.Inject("mysql.queryRow", script.MySQL_queryRow)
+		scriptPool.Inject("mysql.each", script.MySQL_each)
+		scriptPool.Inject("mysql.cursor", script.MySQL_cursor)
+		scriptPool.Inject("mysql.page", script.MySQL_page)
+		scriptPool.Inject("mysql.table", script.MySQL_table)
+		scriptPool.Inject("mysql.transaction", script.MySQL_transaction)
 
 		// Inject Net functions
 		scriptPool.Inject("net.fetch", script.Net_fetch)
 
+		// Inject Alarm functions
+		scriptPool.Inject("alarm.evaluate", script.Alarm_evaluate)
+		scriptPool.Inject("alarm.list", script.Alarm_list)
+		scriptPool.Inject("alarm.ack", script.Alarm_ack)
+
+		// Inject Device functions
+		scriptPool.Inject("device.record", script.Device_record)
+		scriptPool.Inject("device.history", script.Device_history)
+
+		// Inject Config functions (read-only)
+		scriptPool.Inject("config.devices", script.Config_devices)
+		scriptPool.Inject("config.device", script.Config_device)
+		scriptPool.Inject("config.deviceType", script.Config_deviceType)
+		scriptPool.Inject("config.registers", script.Config_registers)
+		scriptPool.Inject("config.dict", script.Config_dict)
+		scriptPool.Inject("config.raw", script.Config_raw)
+
+		// Inject Dictionary functions
+		scriptPool.Inject("dict.lookup", script.Dict_lookup)
+		scriptPool.Inject("dict.rows", script.Dict_rows)
+		scriptPool.Inject("dict.reverse", script.Dict_reverse)
+
 		// Inject Sys functions
 		scriptPool.Inject("sys.command", script.Sys_command)
 
 		log.Println("Script pool initialized with injected functions")
+		startConfigEvents()
 	})
 }
 
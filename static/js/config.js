@@ -0,0 +1,224 @@
+// Wait for DOM to be fully loaded
+document.addEventListener('DOMContentLoaded', function() {
+    // DOM Elements
+    const configList = document.getElementById('config-list');
+    const configNameInput = document.getElementById('config-name');
+    const reloadBtn = document.getElementById('reload-btn');
+    const newConfigBtn = document.getElementById('new-config-btn');
+    const saveBtn = document.getElementById('save-btn');
+    const deleteBtn = document.getElementById('delete-btn');
+    const modal = document.getElementById('modal');
+    const closeModal = document.querySelector('.close');
+    const newConfigParent = document.getElementById('new-config-parent');
+    const newConfigNameInput = document.getElementById('new-config-name');
+    const createConfigBtn = document.getElementById('create-config-btn');
+
+    // Global variables
+    let editor;
+    let configs = [];
+    // Currently edited data id: {dataId, parentId, md5}
+    let current = null;
+
+    // Initialize Monaco Editor
+    require(['vs/editor/editor.main'], function() {
+        editor = monaco.editor.create(document.getElementById('editor'), {
+            value: '',
+            language: 'json',
+            theme: 'vs-dark',
+            automaticLayout: true,
+            fontSize: 14,
+            lineNumbers: 'on',
+            scrollBeyondLastLine: true
+        });
+
+        // Modifier+S for Publish
+        window.addEventListener('keydown', function(e) {
+            const modifierKey = e.ctrlKey || e.metaKey;
+            if (modifierKey && (e.key === 's' || e.key === 'S') && current) {
+                e.preventDefault();
+                e.stopPropagation();
+                saveBtn.click();
+                return false;
+            }
+        }, true);
+
+        loadConfigList();
+    });
+
+    // API Functions
+    async function request(url, options) {
+        const response = await fetch(url, options);
+        const data = await response.json();
+        if (!response.ok) {
+            throw new Error(data.error || `HTTP error! Status: ${response.status}`);
+        }
+        return data;
+    }
+
+    async function loadConfigList() {
+        try {
+            const data = await request('/config/data');
+            configs = data.data || [];
+            renderConfigList();
+        } catch (error) {
+            showNotification(`加载失败: ${error.message}`, 'error');
+        }
+    }
+
+    async function loadConfig(item) {
+        try {
+            const parent = item.parentId ? `?parent=${encodeURIComponent(item.parentId)}` : '';
+            const doc = await request(`/config/data/${encodeURIComponent(item.dataId)}${parent}`);
+            current = { dataId: doc.dataId, parentId: doc.parentId || '', md5: doc.md5 };
+            configNameInput.value = doc.dataId;
+            monaco.editor.setModelLanguage(editor.getModel(), languageOf(doc.dataId));
+            editor.setValue(doc.content);
+            saveBtn.disabled = false;
+            deleteBtn.disabled = !current.parentId;
+            markActive();
+        } catch (error) {
+            showNotification(`加载失败: ${item.dataId} ${error.message}`, 'error');
+        }
+    }
+
+    async function publishConfig() {
+        if (!current) return;
+        try {
+            const doc = await request(`/config/data/${encodeURIComponent(current.dataId)}`, {
+                method: 'PUT',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({
+                    content: editor.getValue(),
+                    md5: current.md5,
+                    parentId: current.parentId
+                })
+            });
+            current.md5 = doc.md5;
+            showNotification(`发布成功: ${doc.dataId}`, 'success');
+            if (!configs.some(item => item.dataId === doc.dataId)) {
+                await loadConfigList();
+            }
+        } catch (error) {
+            showNotification(`发布失败: ${error.message}`, 'error');
+        }
+    }
+
+    async function deleteConfig() {
+        if (!current || !current.parentId) return;
+        if (!confirm(`确定删除 ${current.dataId} ?`)) return;
+        try {
+            const query = `?parent=${encodeURIComponent(current.parentId)}&md5=${encodeURIComponent(current.md5)}`;
+            await request(`/config/data/${encodeURIComponent(current.dataId)}${query}`, { method: 'DELETE' });
+            showNotification(`删除成功: ${current.dataId}`, 'success');
+            clearEditor();
+            await loadConfigList();
+        } catch (error) {
+            showNotification(`删除失败: ${error.message}`, 'error');
+        }
+    }
+
+    // UI Functions
+    function languageOf(dataId) {
+        if (dataId.endsWith('.json')) return 'json';
+        if (dataId.endsWith('.yaml') || dataId.endsWith('.yml')) return 'yaml';
+        return 'plaintext';
+    }
+
+    function renderConfigList() {
+        configList.innerHTML = '';
+        if (configs.length === 0) {
+            const emptyItem = document.createElement('li');
+            emptyItem.textContent = 'No data ids available';
+            emptyItem.classList.add('empty-list');
+            configList.appendChild(emptyItem);
+            return;
+        }
+
+        configs.forEach(item => {
+            const li = document.createElement('li');
+            li.textContent = item.parentId ? `　${item.dataId}` : item.dataId;
+            li.title = item.handler;
+            li.dataset.dataId = item.dataId;
+            li.addEventListener('click', () => loadConfig(item));
+            configList.appendChild(li);
+        });
+        markActive();
+    }
+
+    function markActive() {
+        document.querySelectorAll('#config-list li').forEach(li => {
+            li.classList.toggle('active', !!current && li.dataset.dataId === current.dataId);
+        });
+    }
+
+    function clearEditor() {
+        current = null;
+        configNameInput.value = '';
+        editor.setValue('');
+        saveBtn.disabled = true;
+        deleteBtn.disabled = true;
+        markActive();
+    }
+
+    function showNotification(message, type = 'info') {
+        let toastContainer = document.querySelector('.toast-container');
+        if (!toastContainer) {
+            toastContainer = document.createElement('div');
+            toastContainer.className = 'toast-container';
+            document.body.appendChild(toastContainer);
+        }
+
+        const toast = document.createElement('div');
+        toast.className = `toast ${type}`;
+        const messageEl = document.createElement('span');
+        messageEl.textContent = message;
+        toast.appendChild(messageEl);
+        toastContainer.appendChild(toast);
+
+        setTimeout(() => {
+            if (toast.parentNode) {
+                toast.parentNode.removeChild(toast);
+            }
+        }, 5000);
+    }
+
+    // Event Listeners
+    reloadBtn.addEventListener('click', loadConfigList);
+    saveBtn.addEventListener('click', publishConfig);
+    deleteBtn.addEventListener('click', deleteConfig);
+
+    newConfigBtn.addEventListener('click', () => {
+        newConfigParent.innerHTML = '';
+        configs.filter(item => !item.parentId && item.dataId.endsWith('-root.json')).forEach(item => {
+            const option = document.createElement('option');
+            option.value = item.dataId;
+            option.textContent = `${item.dataId} (${item.handler})`;
+            newConfigParent.appendChild(option);
+        });
+        newConfigNameInput.value = '';
+        modal.style.display = 'block';
+    });
+
+    closeModal.addEventListener('click', () => {
+        modal.style.display = 'none';
+    });
+
+    window.addEventListener('click', (event) => {
+        if (event.target === modal) {
+            modal.style.display = 'none';
+        }
+    });
+
+    createConfigBtn.addEventListener('click', () => {
+        const dataId = newConfigNameInput.value.trim();
+        if (!dataId || !newConfigParent.value) return;
+        current = { dataId: dataId, parentId: newConfigParent.value, md5: '' };
+        configNameInput.value = dataId;
+        monaco.editor.setModelLanguage(editor.getModel(), languageOf(dataId));
+        editor.setValue(dataId.endsWith('.json') ? '[]' : '');
+        saveBtn.disabled = false;
+        deleteBtn.disabled = true;
+        modal.style.display = 'none';
+        markActive();
+    });
+});
@@ -0,0 +1,326 @@
+package alarm
+
+/**
+ * 告警引擎:
+ * 1. Initialize() 根据配置编译规则，并从 Redis 恢复活动告警
+ * 2. Evaluate() 对设备数据求值，产生 raised / cleared 状态迁移
+ * 3. Acknowledge() 确认告警
+ * 每次状态迁移都会调用 TransitionHandler（通常为处理脚本）
+ */
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"main/config"
+	"main/util"
+	uconfig "main/util/config"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/puzpuzpuz/xsync/v4"
+)
+
+const (
+	STATE_RAISED       = "raised"
+	STATE_ACKNOWLEDGED = "acknowledged"
+	STATE_CLEARED      = "cleared"
+
+	REDIS_ALARM_ACTIVE  = "ALARM_ACTIVE"
+	REDIS_ALARM_HISTORY = "ALARM_HISTORY"
+)
+
+// Alarm is a single alarm instance, identified by device and rule
+type Alarm struct {
+	ID         string      `json:"id"`
+	Rule       string      `json:"rule"`
+	Device     string      `json:"device"`
+	DeviceType string      `json:"deviceType,omitempty"`
+	Key        string      `json:"key,omitempty"`
+	Severity   string      `json:"severity"`
+	Message    string      `json:"message,omitempty"`
+	State      string      `json:"state"`
+	Value      interface{} `json:"value"`
+	Count      int         `json:"count"`
+	RaisedAt   time.Time   `json:"raisedAt"`
+	UpdatedAt  time.Time   `json:"updatedAt"`
+	AckedAt    *time.Time  `json:"ackedAt,omitempty"`
+	AckedBy    string      `json:"ackedBy,omitempty"`
+	ClearedAt  *time.Time  `json:"clearedAt,omitempty"`
+	Handler    string      `json:"-"`
+}
+
+// ToMap converts the alarm into a plain map for scripts
+func (a *Alarm) ToMap() map[string]interface{} {
+	data, _ := json.Marshal(a)
+	result := make(map[string]interface{})
+	json.Unmarshal(data, &result)
+	return result
+}
+
+// clone copies the alarm so it can be read outside the engine lock
+func (a *Alarm) clone() *Alarm {
+	c := *a
+	return &c
+}
+
+// TransitionHandler is called after an alarm changes state
+type TransitionHandler func(alarm *Alarm, transition string)
+
+type Engine struct {
+	rules   []*Rule
+	active  *xsync.Map[string, *Alarm]
+	store   util.KVStore
+	history int
+	handler TransitionHandler
+	mu      sync.Mutex
+}
+
+var ALARM_ENGINE *Engine
+
+// Initialize creates the global alarm engine from configuration
+func Initialize(cfg *config.AlarmConfig, store util.KVStore) error {
+	engine, err := NewEngine(cfg, store)
+	if err != nil {
+		return err
+	}
+	ALARM_ENGINE = engine
+	return nil
+}
+
+func NewEngine(cfg *config.AlarmConfig, store util.KVStore) (*Engine, error) {
+	engine := &Engine{
+		active:  xsync.NewMap[string, *Alarm](),
+		store:   store,
+		history: cfg.History,
+	}
+	if engine.history <= 0 {
+		engine.history = 1000
+	}
+	for _, ruleConfig := range cfg.Rules {
+		rule, err := NewRule(ruleConfig)
+		if err != nil {
+			return nil, err
+		}
+		if rule.Handler == "" {
+			rule.Handler = cfg.Handler
+		}
+		engine.rules = append(engine.rules, rule)
+	}
+	engine.load()
+	log.Printf("Alarm engine initialized with %d rules, %d active alarms", len(engine.rules), engine.active.Size())
+	return engine, nil
+}
+
+func (e *Engine) SetTransitionHandler(handler TransitionHandler) {
+	e.handler = handler
+}
+
+// 从 Redis 恢复活动告警
+func (e *Engine) load() {
+	if e.store == nil {
+		return
+	}
+	entries, err := e.store.HGetAll(REDIS_ALARM_ACTIVE)
+	if err != nil {
+		log.Printf("Failed to load active alarms: %v", err)
+		return
+	}
+	for id, data := range entries {
+		var alarm Alarm
+		if err := json.Unmarshal([]byte(data), &alarm); err != nil {
+			log.Printf("Ignore invalid alarm %s: %v", id, err)
+			continue
+		}
+		for _, rule := range e.rules {
+			if rule.Name == alarm.Rule {
+				alarm.Handler = rule.Handler
+				break
+			}
+		}
+		e.active.Store(id, &alarm)
+	}
+}
+
+// Evaluate runs every matching rule against the device values and returns
+// copies of the alarms that changed state
+func (e *Engine) Evaluate(device string, values map[string]interface{}) []*Alarm {
+	deviceType := ""
+	if deviceConfig := uconfig.GetDeviceConfig(device); deviceConfig != nil {
+		deviceType = deviceConfig.Type
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := time.Now()
+	var transitions []*Alarm
+	for _, rule := range e.rules {
+		if !rule.Matches(device, deviceType) {
+			continue
+		}
+
+		id := device + ":" + rule.Name
+		current, isActive := e.active.Load(id)
+		raised, value, ok := rule.Check(device, values, isActive)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case raised && !isActive:
+			alarm := &Alarm{
+				ID:         id,
+				Rule:       rule.Name,
+				Device:     device,
+				DeviceType: deviceType,
+				Key:        rule.Key,
+				Severity:   rule.Severity,
+				Message:    formatMessage(rule.Message, device, rule.Key, value),
+				State:      STATE_RAISED,
+				Value:      value,
+				Count:      1,
+				RaisedAt:   now,
+				UpdatedAt:  now,
+				Handler:    rule.Handler,
+			}
+			e.active.Store(id, alarm)
+			e.persist(alarm)
+			transitions = append(transitions, alarm.clone())
+			e.notify(alarm, STATE_RAISED)
+		case raised && isActive:
+			// 重复触发只更新现值，不产生新的告警
+			current.Value = value
+			current.Count++
+			current.UpdatedAt = now
+			e.persist(current)
+		case !raised && isActive:
+			current.Value = value
+			current.State = STATE_CLEARED
+			current.UpdatedAt = now
+			current.ClearedAt = &now
+			e.active.Delete(id)
+			e.archive(current)
+			transitions = append(transitions, current.clone())
+			e.notify(current, STATE_CLEARED)
+		}
+	}
+	return transitions
+}
+
+// Acknowledge marks an active alarm as acknowledged and returns a copy of it
+func (e *Engine) Acknowledge(id string, user string) (*Alarm, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	alarm, ok := e.active.Load(id)
+	if !ok {
+		return nil, fmt.Errorf("alarm %s not found", id)
+	}
+	if alarm.State == STATE_ACKNOWLEDGED {
+		return alarm.clone(), nil
+	}
+
+	now := time.Now()
+	alarm.State = STATE_ACKNOWLEDGED
+	alarm.AckedAt = &now
+	alarm.AckedBy = user
+	alarm.UpdatedAt = now
+	e.persist(alarm)
+	e.notify(alarm, STATE_ACKNOWLEDGED)
+	return alarm.clone(), nil
+}
+
+// List returns copies of the active alarms, optionally filtered by state
+// and device
+func (e *Engine) List(state string, device string) []*Alarm {
+	alarms := make([]*Alarm, 0)
+	// 告警在锁内被修改，复制后再返回给调用方序列化
+	e.mu.Lock()
+	e.active.Range(func(id string, alarm *Alarm) bool {
+		if (state == "" || alarm.State == state) && (device == "" || alarm.Device == device) {
+			alarms = append(alarms, alarm.clone())
+		}
+		return true
+	})
+	e.mu.Unlock()
+	sort.Slice(alarms, func(i, j int) bool {
+		return alarms[i].RaisedAt.After(alarms[j].RaisedAt)
+	})
+	return alarms
+}
+
+// History returns the most recent finished alarms
+func (e *Engine) History(limit int) ([]*Alarm, error) {
+	if e.store == nil {
+		return []*Alarm{}, nil
+	}
+	if limit <= 0 || limit > e.history {
+		limit = e.history
+	}
+	entries, err := e.store.LRange(REDIS_ALARM_HISTORY, 0, int64(limit-1))
+	if err != nil {
+		return nil, err
+	}
+	alarms := make([]*Alarm, 0, len(entries))
+	for _, data := range entries {
+		var alarm Alarm
+		if err := json.Unmarshal([]byte(data), &alarm); err == nil {
+			alarms = append(alarms, &alarm)
+		}
+	}
+	return alarms, nil
+}
+
+func (e *Engine) persist(alarm *Alarm) {
+	if e.store == nil {
+		return
+	}
+	data, err := json.Marshal(alarm)
+	if err != nil {
+		log.Printf("Failed to marshal alarm %s: %v", alarm.ID, err)
+		return
+	}
+	if err := e.store.SetHValue(REDIS_ALARM_ACTIVE, alarm.ID, string(data)); err != nil {
+		log.Printf("Failed to persist alarm %s: %v", alarm.ID, err)
+	}
+}
+
+func (e *Engine) archive(alarm *Alarm) {
+	if e.store == nil {
+		return
+	}
+	if err := e.store.HDel(REDIS_ALARM_ACTIVE, alarm.ID); err != nil {
+		log.Printf("Failed to remove alarm %s: %v", alarm.ID, err)
+	}
+	data, err := json.Marshal(alarm)
+	if err != nil {
+		return
+	}
+	if _, err := e.store.LPush(REDIS_ALARM_HISTORY, string(data)); err != nil {
+		log.Printf("Failed to archive alarm %s: %v", alarm.ID, err)
+		return
+	}
+	e.store.LTrim(REDIS_ALARM_HISTORY, 0, int64(e.history-1))
+}
+
+func (e *Engine) notify(alarm *Alarm, transition string) {
+	log.Printf("Alarm %s %s: %s", alarm.ID, transition, alarm.Message)
+	if e.handler != nil {
+		e.handler(alarm, transition)
+	}
+}
+
+// 支持 {device} {key} {value} 占位符
+func formatMessage(message, device, key string, value interface{}) string {
+	if message == "" {
+		return fmt.Sprintf("%s %s = %v", device, key, value)
+	}
+	return strings.NewReplacer(
+		"{device}", device,
+		"{key}", key,
+		"{value}", fmt.Sprintf("%v", value),
+	).Replace(message)
+}
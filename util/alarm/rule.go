@@ -0,0 +1,141 @@
+package alarm
+
+import (
+	"fmt"
+	"log"
+	"main/config"
+	"main/util/strings"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+const (
+	RULE_THRESHOLD = "threshold"
+	RULE_BIT       = "bit"
+	RULE_EXPR      = "expr"
+
+	// RULE_EXPR_TIMEOUT bounds an expression, it runs under the engine lock
+	RULE_EXPR_TIMEOUT = 100 * time.Millisecond
+)
+
+// Rule is a compiled alarm rule
+type Rule struct {
+	config.AlarmRuleConfig
+	Kind    string
+	program *goja.Program
+}
+
+// NewRule validates a rule definition and compiles its expression if any
+func NewRule(cfg config.AlarmRuleConfig) (*Rule, error) {
+	if cfg.Name == "" {
+		return nil, fmt.Errorf("alarm rule requires a name")
+	}
+	if cfg.Severity == "" {
+		cfg.Severity = "warning"
+	}
+
+	rule := &Rule{AlarmRuleConfig: cfg}
+	switch {
+	case cfg.Expr != "":
+		prog, err := goja.Compile(cfg.Name, cfg.Expr, true)
+		if err != nil {
+			return nil, fmt.Errorf("compile alarm rule %q failed: %w", cfg.Name, err)
+		}
+		rule.Kind = RULE_EXPR
+		rule.program = prog
+	case cfg.Bit != nil:
+		if cfg.Key == "" {
+			return nil, fmt.Errorf("alarm rule %q: bit condition requires a key", cfg.Name)
+		}
+		rule.Kind = RULE_BIT
+	case cfg.High != nil || cfg.Low != nil:
+		if cfg.Key == "" {
+			return nil, fmt.Errorf("alarm rule %q: threshold condition requires a key", cfg.Name)
+		}
+		rule.Kind = RULE_THRESHOLD
+	default:
+		return nil, fmt.Errorf("alarm rule %q has no condition", cfg.Name)
+	}
+	return rule, nil
+}
+
+// Matches reports whether the rule applies to the given device
+func (r *Rule) Matches(device, deviceType string) bool {
+	if r.Device != "" && r.Device != device {
+		return false
+	}
+	if r.DeviceType != "" && r.DeviceType != deviceType {
+		return false
+	}
+	return true
+}
+
+// Check evaluates the rule condition. active is the current alarm state, used
+// by the deadband so that a value hovering around a limit does not flap.
+// ok is false when the values do not contain what the rule needs.
+func (r *Rule) Check(device string, values map[string]interface{}, active bool) (raised bool, value interface{}, ok bool) {
+	if r.Key != "" {
+		if value, ok = values[r.Key]; !ok {
+			return false, nil, false
+		}
+	}
+
+	switch r.Kind {
+	case RULE_THRESHOLD:
+		v, ok := strings.ToFloat(value)
+		if !ok {
+			return false, value, false
+		}
+		return r.checkThreshold(v, active), value, true
+	case RULE_BIT:
+		v, ok := strings.ToFloat(value)
+		if !ok {
+			return false, value, false
+		}
+		bit := (int64(v) >> uint(*r.Bit)) & 1
+		return bit == int64(r.BitValue), value, true
+	case RULE_EXPR:
+		raised, err := r.checkExpr(device, value, values)
+		if err != nil {
+			return false, value, false
+		}
+		return raised, value, true
+	}
+	return false, value, false
+}
+
+func (r *Rule) checkThreshold(v float64, active bool) bool {
+	deadband := 0.0
+	if active {
+		deadband = r.Deadband
+	}
+	if r.High != nil && v > *r.High-deadband {
+		return true
+	}
+	if r.Low != nil && v < *r.Low+deadband {
+		return true
+	}
+	return false
+}
+
+// 表达式在独立运行时中执行，保证并发安全；超时中断，避免死循环卡住告警引擎
+func (r *Rule) checkExpr(device string, value interface{}, values map[string]interface{}) (bool, error) {
+	rt := goja.New()
+	rt.Set("device", device)
+	rt.Set("key", r.Key)
+	rt.Set("value", value)
+	rt.Set("values", values)
+	timer := time.AfterFunc(RULE_EXPR_TIMEOUT, func() {
+		rt.Interrupt(fmt.Sprintf("timed out after %v", RULE_EXPR_TIMEOUT))
+	})
+	defer timer.Stop()
+	v, err := rt.RunProgram(r.program)
+	if err != nil {
+		if _, ok := err.(*goja.InterruptedError); ok {
+			log.Printf("Alarm rule %q on %s: %v", r.Name, device, err)
+		}
+		return false, fmt.Errorf("alarm rule %q: %w", r.Name, err)
+	}
+	return v.ToBoolean(), nil
+}
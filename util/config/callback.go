@@ -2,12 +2,37 @@ package config
 
 type ConfigChangeHandler func(id string)
 
+const (
+	EVENT_DEVICE_ADD         = "device.add"
+	EVENT_DEVICE_UPDATE      = "device.update"
+	EVENT_DEVICE_REMOVE      = "device.remove"
+	EVENT_DEVICE_TYPE_ADD    = "deviceType.add"
+	EVENT_DEVICE_TYPE_UPDATE = "deviceType.update"
+	EVENT_DEVICE_TYPE_REMOVE = "deviceType.remove"
+	EVENT_PROTOCOL_UPDATE    = "protocol.update"
+	EVENT_DICT_UPDATE        = "dict.update"
+)
+
+// ConfigEvent describes a configuration change with the previous and new
+// values; Old is nil for additions and New is nil for removals
+type ConfigEvent struct {
+	Type    string        `json:"type"`
+	Name    string        `json:"name"`
+	Old     interface{}   `json:"old"`
+	New     interface{}   `json:"new"`
+	Changes []FieldChange `json:"changes,omitempty"` // changed fields of device updates
+	User    string        `json:"user,omitempty"`
+}
+
 type ConfigChangeHandlers struct {
 	DeviceRemoveHandler     func(string)
 	DeviceUpdateHandler     func(*DeviceConfig)
 	DeviceTypeUpdateHandler func(*DeviceTypeConfig)
 	ProtocolUpdateHandler   func(string, string)
 	DictionaryUpdateHandler func(string, string)
+	ConfigEventHandler      func(*ConfigEvent)
+	ScriptConfigHandler     func(script, dataId, data string) error
+	DeviceChangeHandler     func(*DeviceChange)
 }
 
 var CONFIG_CHANGE_HANDLERS = ConfigChangeHandlers{}
@@ -15,3 +40,18 @@ var CONFIG_CHANGE_HANDLERS = ConfigChangeHandlers{}
 func SetConfigChangeHandlers(handlers ConfigChangeHandlers) {
 	CONFIG_CHANGE_HANDLERS = handlers
 }
+
+func fireConfigEvent(eventType, name string, oldValue, newValue interface{}) {
+	dispatchConfigEvent(&ConfigEvent{
+		Type: eventType,
+		Name: name,
+		Old:  oldValue,
+		New:  newValue,
+	})
+}
+
+func dispatchConfigEvent(event *ConfigEvent) {
+	if CONFIG_CHANGE_HANDLERS.ConfigEventHandler != nil {
+		CONFIG_CHANGE_HANDLERS.ConfigEventHandler(event)
+	}
+}
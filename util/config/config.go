@@ -7,4 +7,28 @@ type NacosConfig struct {
 	Namespace  string `yaml:"namespace,omitempty"`
 	Group      string `yaml:"group,omitempty"`
 	LogDir     string `yaml:"log_dir,omitempty"`
+	// Servers lists additional cluster members as "host:port"
+	Servers     []string `yaml:"servers,omitempty"`
+	ContextPath string   `yaml:"context_path,omitempty"` // default /nacos
+	Username    string   `yaml:"username,omitempty"`
+	Password    string   `yaml:"password,omitempty"`
+	AccessKey   string   `yaml:"access_key,omitempty"`
+	SecretKey   string   `yaml:"secret_key,omitempty"`
+	// SnapshotDir keeps the last good configuration, used when Nacos is
+	// unreachable at startup; default <log_dir>/snapshot
+	SnapshotDir       string `yaml:"snapshot_dir,omitempty"`
+	ReconnectInterval int    `yaml:"reconnect_interval,omitempty"` // seconds
+	// DataIds lists the data IDs to subscribe to; the built-in four are used when empty
+	DataIds []DataIdConfig `yaml:"data_ids,omitempty"`
+}
+
+// DataIdConfig binds a data ID to a configuration handler. Data IDs ending
+// with "-root.json" hold a JSON list of sub data IDs, which inherit the
+// group, namespace and handler of the root.
+type DataIdConfig struct {
+	DataId    string `yaml:"data_id"`
+	Group     string `yaml:"group,omitempty"`
+	Namespace string `yaml:"namespace,omitempty"`
+	Handler   string `yaml:"handler"`
+	Script    string `yaml:"script,omitempty"` // script run by the script handler
 }
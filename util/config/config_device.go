@@ -64,34 +64,80 @@ func (d *DeviceConfiguration) UpdateDeviceConfig(dataId, configData string) erro
 		}
 	}
 
+	// 与本 data ID 上一版本比较，只对真正变化的设备触发更新
+	previousConfigs := make(map[string]*DeviceConfig)
+	if previousConfig, ok := d.DataIdConfigMap.Load(dataId); ok {
+		if previousDeviceConfig, err := d.parseDeviceConfigs(dataId, previousConfig); err == nil && previousDeviceConfig != nil {
+			previousConfigs = buildDeviceConfigMap(previousDeviceConfig)
+		}
+	}
+	user := takePublisher(dataId, configData)
+	// 首次加载（含快照回放）不是变更，不触发事件也不记录变更历史
+	initialLoad := !CheckConfigReady()
+
 	for _, config := range *deviceConfigs {
+		previous, existed := previousConfigs[config.Name]
+		if !existed {
+			// 从其他 data ID 移动过来的设备
+			previous, existed = d.DataIdDeviceMap.Load(config.Name)
+		}
 		d.DataIdDeviceMap.Store(config.Name, config)
+
+		var changes []FieldChange
+		if existed {
+			if changes = DiffDeviceConfig(previous, config); len(changes) == 0 {
+				continue
+			}
+		}
 		// 设备配置更新
 		if CONFIG_CHANGE_HANDLERS.DeviceUpdateHandler != nil {
 			CONFIG_CHANGE_HANDLERS.DeviceUpdateHandler(config)
 		}
+		if initialLoad {
+			continue
+		}
+		if existed {
+			fireDeviceChange(DEVICE_CHANGE_UPDATE, config.Name, dataId, user, previous, config, changes)
+		} else {
+			fireDeviceChange(DEVICE_CHANGE_ADD, config.Name, dataId, user, nil, config, nil)
+		}
 	}
 
 	deviceConfigMap := buildDeviceConfigMap(deviceConfigs)
-
-	if previousConfig, ok := d.DataIdConfigMap.Load(dataId); ok {
-		if previousDeviceConfig, err := d.parseDeviceConfigs(dataId, previousConfig); err == nil && previousDeviceConfig != nil {
-			for _, cfg := range *previousDeviceConfig {
-				if _, ok := deviceConfigMap[cfg.Name]; !ok {
-					d.DataIdDeviceMap.Delete(cfg.Name)
-					// 设备配置删除
-					if CONFIG_CHANGE_HANDLERS.DeviceRemoveHandler != nil {
-						CONFIG_CHANGE_HANDLERS.DeviceRemoveHandler(cfg.Name)
-					}
-				}
-			}
+	for name, cfg := range previousConfigs {
+		if _, ok := deviceConfigMap[name]; ok || d.definedElsewhere(name, dataId) {
+			continue
+		}
+		d.DataIdDeviceMap.Delete(name)
+		// 设备配置删除
+		if CONFIG_CHANGE_HANDLERS.DeviceRemoveHandler != nil {
+			CONFIG_CHANGE_HANDLERS.DeviceRemoveHandler(name)
 		}
+		if initialLoad {
+			continue
+		}
+		fireDeviceChange(DEVICE_CHANGE_REMOVE, name, dataId, user, cfg, nil, nil)
 	}
 	d.DataIdConfigMap.Store(dataId, configData)
 
 	return nil
 }
 
+// definedElsewhere reports whether another data ID defines the device
+func (d *DeviceConfiguration) definedElsewhere(name, dataId string) bool {
+	found := false
+	d.DataIdConfigMap.Range(func(otherId string, content string) bool {
+		if otherId == dataId {
+			return true
+		}
+		if configs, err := d.parseDeviceConfigs(otherId, content); err == nil {
+			_, found = buildDeviceConfigMap(configs)[name]
+		}
+		return !found
+	})
+	return found
+}
+
 func buildDeviceConfigMap(deviceConfigs *[]*DeviceConfig) map[string]*DeviceConfig {
 	deviceConfigMap := make(map[string]*DeviceConfig)
 	if deviceConfigs != nil {
@@ -107,19 +153,21 @@ func UpdateDeviceConfig(dataId, configData string) error {
 }
 
 // Filter by debug device if specified
+// Devices whose type is not configured are left out. The type settings are
+// applied to copies, the configured devices are not modified.
 func FilterDeviceConfigs(configs []*DeviceConfig) []*DeviceConfig {
+	filtered := make([]*DeviceConfig, 0, len(configs))
 	for _, cfg := range configs {
 		typeConfig := GetDeviceTypeConfig(cfg.Type)
 		if typeConfig == nil {
 			log.Printf("Ignore \"%s\" for type not found", cfg.Name)
 			continue
 		}
+		device := *cfg
+		ApplyDeviceTypeConfig(&device)
+		filtered = append(filtered, &device)
 	}
-
-	for i := range configs {
-		ApplyDeviceTypeConfig(configs[i])
-	}
-	return configs
+	return filtered
 }
 
 func GetAllDeviceConfig() []*DeviceConfig {
@@ -141,5 +189,5 @@ func GetDeviceConfig(deviceId string) *DeviceConfig {
 }
 
 func (c *DeviceConfig) Compare(other *DeviceConfig) bool {
-	return c.Name == other.Name && c.Type == other.Type && c.Interval == other.Interval && c.SlaveID == other.SlaveID && c.Tags == other.Tags && c.IP == other.IP && c.Port == other.Port
+	return len(DiffDeviceConfig(c, other)) == 0
 }
@@ -6,6 +6,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"reflect"
 	"sync/atomic"
 )
 
@@ -76,8 +77,8 @@ func LoadDeviceTypeConfigs() error {
 // GetDeviceTypeConfig returns the configuration for a specific device type
 // If the device type is not found, returns nil
 func GetDeviceTypeConfig(deviceType string) *DeviceTypeConfig {
-	cfg := DEVICE_TYPES_CONFIG.DeviceTypes.Load().(*DeviceTypesConfig)
-	if cfg == nil {
+	cfg, ok := DEVICE_TYPES_CONFIG.DeviceTypes.Load().(*DeviceTypesConfig)
+	if !ok || cfg == nil {
 		log.Printf("Device type config not initialized")
 		return nil
 	}
@@ -91,6 +92,9 @@ func GetDeviceTypeConfig(deviceType string) *DeviceTypeConfig {
 // This applies the type settings to the device config, overriding any existing settings
 func ApplyDeviceTypeConfig(deviceConfig *DeviceConfig) {
 	typeConfig := GetDeviceTypeConfig(deviceConfig.Type)
+	if typeConfig == nil {
+		return
+	}
 
 	// Always apply the interval from device type configuration
 	deviceConfig.Interval = typeConfig.Interval
@@ -101,6 +105,9 @@ func ApplyDeviceTypeConfig(deviceConfig *DeviceConfig) {
 // If the key is not found or config is empty, returns the default value
 func GetDeviceTypeConfigValue(deviceType string, key string, defaultValue string) string {
 	typeConfig := GetDeviceTypeConfig(deviceType)
+	if typeConfig == nil {
+		return defaultValue
+	}
 	return GetPropertyValue(typeConfig.Config, key, defaultValue)
 }
 
@@ -109,6 +116,9 @@ func GetDeviceTypeConfigValue(deviceType string, key string, defaultValue string
 // If the key is not found or tags is empty, returns the default value
 func GetDeviceTypeTagValue(deviceType string, key string, defaultValue string) string {
 	typeConfig := GetDeviceTypeConfig(deviceType)
+	if typeConfig == nil {
+		return defaultValue
+	}
 	return GetPropertyValue(typeConfig.Tags, key, defaultValue)
 }
 
@@ -116,6 +126,7 @@ func UpdateDeviceTypeConfig(dataId, configData string) error {
 	if cfg, err := DEVICE_TYPES_CONFIG.parseDeviceTypeConfig(dataId, configData); err != nil {
 		return err
 	} else {
+		previous, _ := DEVICE_TYPES_CONFIG.DeviceTypes.Load().(*DeviceTypesConfig)
 		DEVICE_TYPES_CONFIG.DeviceTypes.Store(cfg)
 		// 设备类型配置更新
 		if CONFIG_CHANGE_HANDLERS.DeviceTypeUpdateHandler != nil {
@@ -123,12 +134,17 @@ func UpdateDeviceTypeConfig(dataId, configData string) error {
 				CONFIG_CHANGE_HANDLERS.DeviceTypeUpdateHandler(typeConfig)
 			}
 		}
+		fireDeviceTypeEvents(previous, cfg)
 		return nil
 	}
 }
 
 func GetAllDeviceTypeConfig() []*DeviceTypeConfig {
-	deviceTypeMap := DEVICE_TYPES_CONFIG.DeviceTypes.Load().(*DeviceTypesConfig).DeviceTypes
+	cfg, ok := DEVICE_TYPES_CONFIG.DeviceTypes.Load().(*DeviceTypesConfig)
+	if !ok || cfg == nil {
+		return []*DeviceTypeConfig{}
+	}
+	deviceTypeMap := cfg.DeviceTypes
 	// to []*DeviceTypeConfig
 	configs := make([]*DeviceTypeConfig, 0, len(deviceTypeMap))
 	for _, config := range deviceTypeMap {
@@ -136,3 +152,25 @@ func GetAllDeviceTypeConfig() []*DeviceTypeConfig {
 	}
 	return configs
 }
+
+func fireDeviceTypeEvents(previous, current *DeviceTypesConfig) {
+	var previousTypes map[string]*DeviceTypeConfig
+	if previous != nil {
+		previousTypes = previous.DeviceTypes
+	}
+	for name, typeConfig := range current.DeviceTypes {
+		if old, ok := previousTypes[name]; ok {
+			// 每次推送都会重新解析全部类型，只通知内容有变化的类型
+			if !reflect.DeepEqual(old, typeConfig) {
+				fireConfigEvent(EVENT_DEVICE_TYPE_UPDATE, name, old, typeConfig)
+			}
+		} else {
+			fireConfigEvent(EVENT_DEVICE_TYPE_ADD, name, nil, typeConfig)
+		}
+	}
+	for name, old := range previousTypes {
+		if _, ok := current.DeviceTypes[name]; !ok {
+			fireConfigEvent(EVENT_DEVICE_TYPE_REMOVE, name, old, nil)
+		}
+	}
+}
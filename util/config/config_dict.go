@@ -1,11 +1,14 @@
 package config
 
 import (
+	"log"
+
 	"github.com/puzpuzpuz/xsync/v4"
 )
 
 type DictionaryConfiguration struct {
 	DictionaryMap xsync.Map[string, string]
+	TableMap      xsync.Map[string, *DictionaryTable]
 }
 
 var (
@@ -15,15 +18,52 @@ var (
 func NewDictionaryConfiguration() *DictionaryConfiguration {
 	configuration := &DictionaryConfiguration{
 		DictionaryMap: *xsync.NewMap[string, string](),
+		TableMap:      *xsync.NewMap[string, *DictionaryTable](),
 	}
 	return configuration
 }
 
 func UpdateDictionaryConfig(csvName, data string) error {
+	table, err := ParseDictionary(csvName, data)
+	if err != nil {
+		return err
+	}
+	for _, rowErr := range table.Errors {
+		log.Printf("Dictionary %s row %d: %s", csvName, rowErr.Row, rowErr.Message)
+	}
+
+	previous, _ := DICTIONARY_CONFIG.TableMap.Load(csvName)
+	previousData, existed := DICTIONARY_CONFIG.DictionaryMap.Load(csvName)
 	DICTIONARY_CONFIG.DictionaryMap.Store(csvName, data)
+	DICTIONARY_CONFIG.TableMap.Store(csvName, table)
 	// 协议配置更新
 	if CONFIG_CHANGE_HANDLERS.DictionaryUpdateHandler != nil {
 		CONFIG_CHANGE_HANDLERS.DictionaryUpdateHandler(csvName, data)
 	}
+	if !existed || previousData != data {
+		fireConfigEvent(EVENT_DICT_UPDATE, csvName, previous, table)
+	}
 	return nil
 }
+
+func GetDictionaryConfig(csvName string) (string, bool) {
+	return DICTIONARY_CONFIG.DictionaryMap.Load(csvName)
+}
+
+// GetAllDictionaryConfig returns the raw CSV of every dictionary by name
+func GetAllDictionaryConfig() map[string]string {
+	return xsync.ToPlainMap(&DICTIONARY_CONFIG.DictionaryMap)
+}
+
+func GetDictionaryTable(csvName string) (*DictionaryTable, bool) {
+	return DICTIONARY_CONFIG.TableMap.Load(csvName)
+}
+
+func GetDictionaryNames() []string {
+	names := make([]string, 0)
+	DICTIONARY_CONFIG.TableMap.Range(func(name string, _ *DictionaryTable) bool {
+		names = append(names, name)
+		return true
+	})
+	return names
+}
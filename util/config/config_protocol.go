@@ -1,6 +1,8 @@
 package config
 
 import (
+	"reflect"
+
 	"github.com/puzpuzpuz/xsync/v4"
 )
 
@@ -25,12 +27,21 @@ func UpdateProtocolConfig(csvName, data string) error {
 	if modbusRegisters, err := ParseModbusProtocol(data); err != nil {
 		return err
 	} else {
+		previous, _ := PROTOCOL_CONFIG.ModbusRegisterMap.Load(csvName)
 		PROTOCOL_CONFIG.ProtocolMap.Store(csvName, data)
 		PROTOCOL_CONFIG.ModbusRegisterMap.Store(csvName, modbusRegisters)
 		// 协议配置更新
 		if CONFIG_CHANGE_HANDLERS.ProtocolUpdateHandler != nil {
 			CONFIG_CHANGE_HANDLERS.ProtocolUpdateHandler(csvName, data)
 		}
+		if previous == nil || !reflect.DeepEqual(previous, modbusRegisters) {
+			fireConfigEvent(EVENT_PROTOCOL_UPDATE, csvName, previous, modbusRegisters)
+		}
 		return nil
 	}
 }
+
+// GetAllProtocolConfig returns the raw CSV of every protocol by name
+func GetAllProtocolConfig() map[string]string {
+	return xsync.ToPlainMap(&PROTOCOL_CONFIG.ProtocolMap)
+}
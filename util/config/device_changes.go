@@ -0,0 +1,116 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/puzpuzpuz/xsync/v4"
+)
+
+const (
+	DEVICE_CHANGE_ADD    = "add"
+	DEVICE_CHANGE_UPDATE = "update"
+	DEVICE_CHANGE_REMOVE = "remove"
+)
+
+// FieldChange is a single changed field, named by its json name
+type FieldChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+// DeviceChange records who changed a device, when and what
+type DeviceChange struct {
+	Device  string        `json:"device"`
+	Action  string        `json:"action"` // add, update or remove
+	DataId  string        `json:"dataId"`
+	User    string        `json:"user"` // API publisher, or the provider for edits made in the config center
+	Time    time.Time     `json:"time"`
+	Changes []FieldChange `json:"changes,omitempty"`
+}
+
+type publishAttribution struct {
+	md5  string
+	user string
+}
+
+// pendingPublishers remembers who published a data ID through the API until
+// the change comes back from the source
+var pendingPublishers = xsync.NewMap[string, publishAttribution]()
+
+// DiffDeviceConfig compares two device configs field by field
+func DiffDeviceConfig(old, new *DeviceConfig) []FieldChange {
+	changes := make([]FieldChange, 0)
+	oldValue := reflect.ValueOf(old).Elem()
+	newValue := reflect.ValueOf(new).Elem()
+	for i := 0; i < oldValue.NumField(); i++ {
+		field := oldValue.Type().Field(i)
+		before := oldValue.Field(i).Interface()
+		after := newValue.Field(i).Interface()
+		if before == after {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" {
+			name = field.Name
+		}
+		changes = append(changes, FieldChange{Field: name, Old: before, New: after})
+	}
+	return changes
+}
+
+func notePublisher(dataId, content, user string) {
+	if user == "" {
+		return
+	}
+	pendingPublishers.Store(dataId, publishAttribution{md5: contentMD5(content), user: user})
+}
+
+// takePublisher returns who published the content of a data ID
+func takePublisher(dataId, content string) string {
+	if attribution, ok := pendingPublishers.LoadAndDelete(dataId); ok && attribution.md5 == contentMD5(content) {
+		return attribution.user
+	}
+	if CONFIG_PROVIDER != nil {
+		return CONFIG_PROVIDER.Name
+	}
+	return ""
+}
+
+func fireDeviceChange(action, name, dataId, user string, oldValue, newValue *DeviceConfig, changes []FieldChange) {
+	change := &DeviceChange{
+		Device:  name,
+		Action:  action,
+		DataId:  dataId,
+		User:    user,
+		Time:    time.Now(),
+		Changes: changes,
+	}
+	if CONFIG_CHANGE_HANDLERS.DeviceChangeHandler != nil {
+		CONFIG_CHANGE_HANDLERS.DeviceChangeHandler(change)
+	}
+
+	eventType := EVENT_DEVICE_UPDATE
+	switch action {
+	case DEVICE_CHANGE_ADD:
+		eventType = EVENT_DEVICE_ADD
+	case DEVICE_CHANGE_REMOVE:
+		eventType = EVENT_DEVICE_REMOVE
+	}
+	event := &ConfigEvent{
+		Type:    eventType,
+		Name:    name,
+		Changes: changes,
+		User:    user,
+	}
+	// 保持 nil 而不是 typed nil，脚本中得到 null
+	if oldValue != nil {
+		event.Old = oldValue
+	}
+	if newValue != nil {
+		event.New = newValue
+	}
+	dispatchConfigEvent(event)
+}
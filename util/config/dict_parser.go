@@ -0,0 +1,281 @@
+package config
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const DICT_KEY_SEPARATOR = "|"
+
+// 字典列类型，在表头中以 "列名:类型" 声明，未声明为 string
+const (
+	DICT_TYPE_STRING = "string"
+	DICT_TYPE_INT    = "int"
+	DICT_TYPE_FLOAT  = "float"
+	DICT_TYPE_BOOL   = "bool"
+)
+
+// DictionaryRowError describes a row that could not be loaded
+type DictionaryRowError struct {
+	Row     int    `json:"row"` // 1-based line number, the header is row 1
+	Message string `json:"message"`
+}
+
+// DictionaryTable is a dictionary CSV parsed into typed rows.
+//
+// The first row is the header. Columns whose header starts with "*" form the
+// (composite) key; without marked columns the first column is the key. A
+// column may declare its type as "name:int", "name:float" or "name:bool",
+// e.g. "*code,name,level:int,enabled:bool".
+type DictionaryTable struct {
+	Name       string                   `json:"name"`
+	Columns    []string                 `json:"columns"`
+	Types      map[string]string        `json:"types"`
+	KeyColumns []string                 `json:"keyColumns"`
+	Rows       []map[string]interface{} `json:"rows"`
+	Errors     []DictionaryRowError     `json:"errors,omitempty"`
+	index      map[string]int
+}
+
+// ParseDictionary parses a dictionary CSV. Invalid rows are skipped and
+// reported in Errors; an error is returned only when there is no header.
+func ParseDictionary(name, content string) (*DictionaryTable, error) {
+	if strings.TrimSpace(content) == "" {
+		return &DictionaryTable{Name: name, Types: make(map[string]string), Rows: make([]map[string]interface{}, 0), index: make(map[string]int)}, nil
+	}
+
+	reader := newCSVReader(content)
+	reader.FieldsPerRecord = -1
+
+	headers, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("dictionary %s has no header: %v", name, err)
+	}
+
+	table := &DictionaryTable{
+		Name:  name,
+		Types: make(map[string]string),
+		Rows:  make([]map[string]interface{}, 0),
+		index: make(map[string]int),
+	}
+	for _, h := range headers {
+		column := strings.TrimSpace(h)
+		key := strings.HasPrefix(column, "*")
+		column = strings.TrimSpace(strings.TrimPrefix(column, "*"))
+		columnType := DICT_TYPE_STRING
+		if i := strings.LastIndex(column, ":"); i >= 0 {
+			columnType = strings.ToLower(strings.TrimSpace(column[i+1:]))
+			column = strings.TrimSpace(column[:i])
+			switch columnType {
+			case DICT_TYPE_STRING, DICT_TYPE_INT, DICT_TYPE_FLOAT, DICT_TYPE_BOOL:
+			default:
+				return nil, fmt.Errorf("dictionary %s column %s has unknown type %q", name, column, columnType)
+			}
+		}
+		if key {
+			table.KeyColumns = append(table.KeyColumns, column)
+		}
+		table.Columns = append(table.Columns, column)
+		table.Types[column] = columnType
+	}
+	if len(table.KeyColumns) == 0 && len(table.Columns) > 0 {
+		table.KeyColumns = []string{table.Columns[0]}
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			line := 0
+			if parseErr, ok := err.(*csv.ParseError); ok {
+				line = parseErr.StartLine
+			}
+			table.addError(line, err.Error())
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+
+		// Skip comment lines that start with // or #
+		first := strings.TrimSpace(record[0])
+		if strings.HasPrefix(first, "//") || strings.HasPrefix(first, "#") {
+			continue
+		}
+		if len(record) == 1 && first == "" {
+			continue
+		}
+		if len(record) != len(table.Columns) {
+			table.addError(line, fmt.Sprintf("expected %d columns, got %d", len(table.Columns), len(record)))
+			continue
+		}
+
+		row := make(map[string]interface{}, len(table.Columns))
+		var cellErr error
+		for i, column := range table.Columns {
+			if row[column], cellErr = parseDictValue(table.Types[column], strings.TrimSpace(record[i])); cellErr != nil {
+				cellErr = fmt.Errorf("column %s: %v", column, cellErr)
+				break
+			}
+		}
+		if cellErr != nil {
+			table.addError(line, cellErr.Error())
+			continue
+		}
+
+		key := table.rowKey(row)
+		if strings.Trim(key, DICT_KEY_SEPARATOR) == "" {
+			table.addError(line, "empty key")
+			continue
+		}
+		if _, exists := table.index[key]; exists {
+			table.addError(line, fmt.Sprintf("duplicate key %q", key))
+			continue
+		}
+		table.index[key] = len(table.Rows)
+		table.Rows = append(table.Rows, row)
+	}
+
+	return table, nil
+}
+
+func (t *DictionaryTable) addError(row int, message string) {
+	t.Errors = append(t.Errors, DictionaryRowError{Row: row, Message: message})
+}
+
+func (t *DictionaryTable) rowKey(row map[string]interface{}) string {
+	parts := make([]string, len(t.KeyColumns))
+	for i, column := range t.KeyColumns {
+		parts[i] = formatDictValue(row[column])
+	}
+	return strings.Join(parts, DICT_KEY_SEPARATOR)
+}
+
+// parseDictValue converts a cell to its column type; empty cells of typed
+// columns are nil
+func parseDictValue(columnType, value string) (interface{}, error) {
+	if columnType == DICT_TYPE_STRING || columnType == "" {
+		return value, nil
+	}
+	if value == "" {
+		return nil, nil
+	}
+	switch columnType {
+	case DICT_TYPE_INT:
+		v, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid int %q", value)
+		}
+		return v, nil
+	case DICT_TYPE_FLOAT:
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid float %q", value)
+		}
+		return v, nil
+	case DICT_TYPE_BOOL:
+		v, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bool %q", value)
+		}
+		return v, nil
+	}
+	return value, nil
+}
+
+// formatDictValue is the canonical string form of a typed cell, used for
+// keys and filters so that "01" and "1" of an int column are equal
+func formatDictValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return fmt.Sprint(value)
+}
+
+// canonical returns the canonical form of a value given for column, false
+// when it is not a valid value of the column's type
+func (t *DictionaryTable) canonical(column, value string) (string, bool) {
+	typed, err := parseDictValue(t.Types[column], strings.TrimSpace(value))
+	if err != nil {
+		return "", false
+	}
+	return formatDictValue(typed), true
+}
+
+// Lookup finds a row by key; composite keys are given as parts or joined by
+// "|". The row is a copy.
+func (t *DictionaryTable) Lookup(keys ...string) (map[string]interface{}, bool) {
+	if len(keys) == 1 && len(t.KeyColumns) > 1 {
+		keys = strings.Split(keys[0], DICT_KEY_SEPARATOR)
+	}
+	if len(keys) == len(t.KeyColumns) {
+		canonical := make([]string, len(keys))
+		for i, column := range t.KeyColumns {
+			key, ok := t.canonical(column, keys[i])
+			if !ok {
+				return nil, false
+			}
+			canonical[i] = key
+		}
+		keys = canonical
+	}
+	i, ok := t.index[strings.Join(keys, DICT_KEY_SEPARATOR)]
+	if !ok {
+		return nil, false
+	}
+	return cloneRow(t.Rows[i]), true
+}
+
+// Reverse returns copies of the rows whose column equals the given value
+func (t *DictionaryTable) Reverse(column, value string) []map[string]interface{} {
+	return t.Filter(map[string]string{column: value})
+}
+
+// Filter returns copies of the rows matching every column/value pair;
+// values are compared according to the column type
+func (t *DictionaryTable) Filter(filter map[string]string) []map[string]interface{} {
+	rows := make([]map[string]interface{}, 0)
+	expected := make(map[string]string, len(filter))
+	for column, value := range filter {
+		canonical, ok := t.canonical(column, value)
+		if !ok {
+			return rows
+		}
+		expected[column] = canonical
+	}
+	for _, row := range t.Rows {
+		matched := true
+		for column, value := range expected {
+			if formatDictValue(row[column]) != value {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			rows = append(rows, cloneRow(row))
+		}
+	}
+	return rows
+}
+
+// cloneRow copies a row so callers (scripts in particular) cannot modify
+// the shared table
+func cloneRow(row map[string]interface{}) map[string]interface{} {
+	clone := make(map[string]interface{}, len(row))
+	for column, value := range row {
+		clone[column] = value
+	}
+	return clone
+}
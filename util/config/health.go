@@ -0,0 +1,118 @@
+package config
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// ConfigHealth reports whether the loaded configuration is fresh
+type ConfigHealth struct {
+	Provider   string    `json:"provider"`
+	Ready      bool      `json:"ready"`
+	Connected  bool      `json:"connected"`
+	Stale      []string  `json:"stale"`      // data IDs served from a local snapshot
+	LastSync   time.Time `json:"lastSync"`   // last successful read from the source
+	LastChange time.Time `json:"lastChange"` // last configuration change applied
+	LastError  string    `json:"lastError,omitempty"`
+}
+
+func (h *ConfigHealth) Healthy() bool {
+	return h.Ready && h.Connected && len(h.Stale) == 0
+}
+
+// HealthReporter is implemented by sources that track their connection state
+type HealthReporter interface {
+	Health() ConfigHealth
+}
+
+// sourceHealth tracks the connection state of a configuration source
+type sourceHealth struct {
+	mu        sync.Mutex
+	connected bool
+	lastSync  time.Time
+	lastError string
+	stale     map[string]struct{}
+}
+
+func (h *sourceHealth) setConnected(err error) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err != nil {
+		h.connected = false
+		h.lastError = err.Error()
+		return false
+	}
+	h.connected = true
+	return true
+}
+
+func (h *sourceHealth) isConnected() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.connected
+}
+
+func (h *sourceHealth) synced(key string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastSync = time.Now()
+	delete(h.stale, key)
+}
+
+func (h *sourceHealth) markStale(key string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.stale == nil {
+		h.stale = make(map[string]struct{})
+	}
+	h.stale[key] = struct{}{}
+}
+
+func (h *sourceHealth) isStale(key string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, ok := h.stale[key]
+	return ok
+}
+
+func (h *sourceHealth) hasStale() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.stale) > 0
+}
+
+func (h *sourceHealth) report() ConfigHealth {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	stale := make([]string, 0, len(h.stale))
+	for key := range h.stale {
+		stale = append(stale, key)
+	}
+	sort.Strings(stale)
+	return ConfigHealth{
+		Connected: h.connected,
+		Stale:     stale,
+		LastSync:  h.lastSync,
+		LastError: h.lastError,
+	}
+}
+
+// GetConfigHealth returns the freshness of the configuration of CONFIG_PROVIDER
+func GetConfigHealth() ConfigHealth {
+	provider := CONFIG_PROVIDER
+	if provider == nil {
+		return ConfigHealth{Stale: []string{}, LastError: "configuration provider not initialized"}
+	}
+
+	health := ConfigHealth{Connected: true, Stale: []string{}}
+	if reporter, ok := provider.Source.(HealthReporter); ok {
+		health = reporter.Health()
+	}
+	health.Provider = provider.Name
+	health.Ready = CheckConfigReady()
+	if lastChange, ok := provider.lastChange.Load().(time.Time); ok {
+		health.LastChange = lastChange
+	}
+	return health
+}
@@ -11,21 +11,21 @@ import (
 )
 
 type ModbusRegisterBitConfig struct {
-	Bit  int
-	Key  string
-	Name string
+	Bit  int    `json:"bit"`
+	Key  string `json:"key"`
+	Name string `json:"name"`
 }
 
 type ModbusRegister struct {
-	Name     string
-	Key      string
-	Address  uint16
-	Length   uint16
-	Type     int
-	Function int
-	Scale    float64
-	Unit     string
-	Bits     []ModbusRegisterBitConfig
+	Name     string                    `json:"name"`
+	Key      string                    `json:"key"`
+	Address  uint16                    `json:"address"`
+	Length   uint16                    `json:"length"`
+	Type     int                       `json:"type"`
+	Function int                       `json:"function"`
+	Scale    float64                   `json:"scale"`
+	Unit     string                    `json:"unit,omitempty"`
+	Bits     []ModbusRegisterBitConfig `json:"bits,omitempty"`
 }
 
 const (
@@ -41,7 +41,8 @@ const (
 	MT_FLOAT = 2
 )
 
-func ParseModbusProtocol(content string) ([]ModbusRegister, error) {
+// newCSVReader creates a CSV reader that skips a leading UTF-8 BOM
+func newCSVReader(content string) *csv.Reader {
 	strReader := strings.NewReader(content)
 	br := bufio.NewReader(strReader)
 
@@ -54,11 +55,30 @@ func ParseModbusProtocol(content string) ([]ModbusRegister, error) {
 
 	reader := csv.NewReader(br)
 	reader.TrimLeadingSpace = true
+	return reader
+}
+
+func ParseModbusProtocol(content string) ([]ModbusRegister, error) {
+	registers, report := ValidateModbusProtocol("", content)
+	if registers == nil {
+		return nil, report.Err()
+	}
+	return registers, nil
+}
+
+// ValidateModbusProtocol parses a protocol CSV and reports invalid rows,
+// invalid field values and duplicate register keys / addresses. Invalid rows
+// are skipped; registers is nil when the header cannot be read.
+func ValidateModbusProtocol(dataId, content string) ([]ModbusRegister, *ValidationReport) {
+	report := NewValidationReport(dataId)
+	reader := newCSVReader(content)
+	reader.FieldsPerRecord = -1
 
 	// Read the header
 	headers, err := reader.Read()
 	if err != nil {
-		return nil, err
+		report.Error(1, "", "invalid header: %v", err)
+		return nil, report
 	}
 
 	// Create field index mapping
@@ -67,23 +87,37 @@ func ParseModbusProtocol(content string) ([]ModbusRegister, error) {
 		idx[strings.ToLower(strings.TrimSpace(h))] = i
 	}
 
-	var result []ModbusRegister
+	result := make([]ModbusRegister, 0)
+	rows := make([]int, 0)
 	for {
 		record, err := reader.Read()
 		if err == io.EOF {
 			break
 		}
-		if err != nil || len(record) < 4 {
+		if err != nil {
+			line := 0
+			if parseErr, ok := err.(*csv.ParseError); ok {
+				line = parseErr.StartLine
+			}
+			report.Error(line, "", "%v", err)
 			continue
 		}
+		line, _ := reader.FieldPos(0)
 
 		// Clean whitespace from each field
 		for i := range record {
 			record[i] = strings.TrimSpace(record[i])
 		}
 
-		// Skip comment lines that start with // or #
-		if len(record) > 0 && (strings.HasPrefix(record[0], "//") || strings.HasPrefix(record[0], "#")) {
+		// Skip comment and empty lines
+		if strings.HasPrefix(record[0], "//") || strings.HasPrefix(record[0], "#") {
+			continue
+		}
+		if len(record) == 1 && record[0] == "" {
+			continue
+		}
+		if len(record) < 4 {
+			report.Error(line, "", "expected at least 4 columns, got %d", len(record))
 			continue
 		}
 
@@ -92,76 +126,120 @@ func ParseModbusProtocol(content string) ([]ModbusRegister, error) {
 			if !ok || i >= len(record) {
 				return ""
 			}
-			return strings.TrimSpace(record[i])
+			return record[i]
 		}
 		getType := func(field string) int {
-			i, ok := idx[field]
-			if !ok || i >= len(record) {
-				return MT_NONE
-			}
-			str := strings.TrimSpace(record[i])
+			str := get(field)
 			switch str {
-			case "":
-				return MT_INT
-			case "int":
+			case "", "int":
 				return MT_INT
 			case "float":
 				return MT_FLOAT
 			default:
-				fmt.Printf("Invalid type: %s\n", str)
+				report.Error(line, field, "invalid type %q, expected int or float", str)
 				return MT_NONE
 			}
 		}
 		getFunction := func(field string) int {
-			i, ok := idx[field]
-			if !ok || i >= len(record) {
-				return MF_NONE
-			}
-			str := strings.TrimSpace(record[i])
+			str := get(field)
 			switch str {
-			case "":
+			case "", "hold":
 				return MF_HOLD
 			case "coil":
 				return MF_COIL
 			case "input":
 				return MF_INPUT
-			case "hold":
-				return MF_HOLD
 			default:
-				fmt.Printf("Invalid function: %s\n", str)
-				return MT_NONE
+				report.Error(line, field, "invalid function %q, expected hold, input or coil", str)
+				return MF_NONE
 			}
 		}
-
-		address, _ := strconv.Atoi(get("address"))
-		length, _ := strconv.Atoi(get("length"))
+		getInt := func(field string, max int) int {
+			str := get(field)
+			if str == "" {
+				return 0
+			}
+			value, err := strconv.Atoi(str)
+			if err != nil || value < 0 || value > max {
+				report.Error(line, field, "invalid %s %q", field, str)
+				return 0
+			}
+			return value
+		}
 
 		scale := 1.0
 		if s := get("scale"); s != "" {
-			scale, _ = strconv.ParseFloat(s, 64)
+			if scale, err = strconv.ParseFloat(s, 64); err != nil {
+				report.Error(line, "scale", "invalid scale %q", s)
+			}
 		}
 
 		r := ModbusRegister{
 			Name:     get("name"),
 			Key:      get("key"),
-			Address:  uint16(address),
-			Length:   uint16(length),
+			Address:  uint16(getInt("address", 65535)),
+			Length:   uint16(getInt("length", 125)),
 			Type:     getType("type"),
 			Function: getFunction("function"),
 			Scale:    scale,
 			Unit:     get("unit"),
 		}
+		if r.Key == "" {
+			report.Warning(line, "key", "register has no key, a generated key is used")
+		}
 
 		// Parse bit definitions
 		if bits := get("bits"); bits != "" {
 			r.Bits = parseBits(bits)
+			if len(r.Bits) != len(strings.Split(bits, ";")) {
+				report.Warning(line, "bits", "some bit definitions are invalid, expected bit:key:name")
+			}
 		}
 
 		result = append(result, r)
+		rows = append(rows, line)
 	}
 
 	result = applyDefaults(result)
-	return result, nil
+	checkDuplicateRegisters(result, rows, report)
+	return result, report
+}
+
+// checkDuplicateRegisters reports duplicate keys, and duplicate or
+// overlapping addresses within the same function
+func checkDuplicateRegisters(regs []ModbusRegister, rows []int, report *ValidationReport) {
+	keys := make(map[string]int)
+	for i, r := range regs {
+		if previous, exists := keys[r.Key]; exists {
+			report.Error(rows[i], "key", "duplicate key %s, also on row %d", r.Key, rows[previous])
+			continue
+		}
+		keys[r.Key] = i
+	}
+
+	for i := range regs {
+		for j := 0; j < i; j++ {
+			a, b := regs[j], regs[i]
+			if a.Function != b.Function {
+				continue
+			}
+			if a.Address == b.Address {
+				report.Error(rows[i], "address", "duplicate address %d of %s, also used by %s on row %d", b.Address, b.Key, a.Key, rows[j])
+				break
+			}
+			if int(b.Address) < int(a.Address)+registerSpan(a) && int(a.Address) < int(b.Address)+registerSpan(b) {
+				report.Warning(rows[i], "address", "address %d of %s overlaps %s on row %d", b.Address, b.Key, a.Key, rows[j])
+				break
+			}
+		}
+	}
+}
+
+func registerSpan(r ModbusRegister) int {
+	if r.Length == 0 {
+		return 1
+	}
+	return int(r.Length)
 }
 
 // 解析 "0:bit_key:bit_name;1:..." 格式的位配置字段
@@ -1,13 +1,15 @@
 package config
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"net"
+	"net/http"
+	"strconv"
 	"strings"
 	"sync"
-	"sync/atomic"
+	"time"
 
 	"github.com/puzpuzpuz/xsync/v4"
 
@@ -18,217 +20,293 @@ import (
 )
 
 const (
-	ROOT_CONFIG_SUFFIX = "-root.json"
+	NACOS_DEFAULT_CONTEXT_PATH       = "/nacos"
+	NACOS_DEFAULT_RECONNECT_INTERVAL = 10
+	NACOS_HEALTH_PATH                = "/v1/console/health/liveness"
 )
 
-type ConfigChangeCallback func(dataId, data, parentId string) error
-
+// NacosClient is the Nacos configuration source, with one config client per
+// namespace. Configuration accepted by its handler is saved to a local
+// snapshot, which is served when Nacos is unreachable; a background probe
+// resyncs once it is back.
 type NacosClient struct {
-	Namespace            string
-	Group                string
-	ConfigClient         config_client.IConfigClient
-	Callback             ConfigChangeCallback
-	SubscribedSubDataIds xsync.Map[string, *xsync.Map[string, struct{}]]
-	Initialized          atomic.Value
-	Mu                   sync.Mutex
+	Namespace     string
+	Group         string
+	ConfigClient  config_client.IConfigClient
+	ConfigClients xsync.Map[string, config_client.IConfigClient] // by namespace
+	Config        *NacosConfig
+	OnReconnect   func() error
+
+	snapshot      *FileSource
+	health        sourceHealth
+	probeURLs     []string
+	reconnectOnce sync.Once
 }
 
 var nacosClient *NacosClient = NewNacosClient()
 
 func NewNacosClient() *NacosClient {
-	client := &NacosClient{
-		SubscribedSubDataIds: *xsync.NewMap[string, *xsync.Map[string, struct{}]](),
-		Mu:                   sync.Mutex{},
+	return &NacosClient{
+		ConfigClients: *xsync.NewMap[string, config_client.IConfigClient](),
 	}
-	client.Initialized.Store(false)
-	return client
 }
 
-func (nc *NacosClient) CreateClient(callback ConfigChangeCallback, nacosConfig *NacosConfig) error {
-	nc.Callback = callback
+func (nc *NacosClient) CreateClient(nacosConfig *NacosConfig) error {
+	nc.Config = nacosConfig
 	nc.Namespace = nacosConfig.Namespace
 	nc.Group = nacosConfig.Group
 
+	snapshotDir := nacosConfig.SnapshotDir
+	if snapshotDir == "" {
+		snapshotDir = nacosConfig.LogDir + "/snapshot"
+	}
+	nc.snapshot = &FileSource{Dir: snapshotDir}
+
+	contextPath := nacosConfig.ContextPath
+	if contextPath == "" {
+		contextPath = NACOS_DEFAULT_CONTEXT_PATH
+	}
+	nc.probeURLs = nil
+	for _, server := range nacosServerConfigs(nacosConfig) {
+		address := net.JoinHostPort(server.IpAddr, strconv.FormatUint(server.Port, 10))
+		nc.probeURLs = append(nc.probeURLs, "http://"+address+contextPath+NACOS_HEALTH_PATH)
+	}
+	if ok := nc.health.setConnected(nc.probe()); !ok {
+		log.Printf("Warning: Nacos is unreachable, falling back to the local snapshot in %s", snapshotDir)
+	}
+
+	var err error
+	nc.ConfigClient, err = nc.GetClient(nc.Namespace)
+	return err
+}
+
+// nacosServerConfigs combines server_addr/port with the servers list
+func nacosServerConfigs(nacosConfig *NacosConfig) []constant.ServerConfig {
+	var addresses []string
+	if nacosConfig.ServerAddr != "" {
+		addresses = append(addresses, net.JoinHostPort(nacosConfig.ServerAddr, strconv.FormatUint(nacosConfig.Port, 10)))
+	}
+	addresses = append(addresses, nacosConfig.Servers...)
+
+	serverConfigs := make([]constant.ServerConfig, 0, len(addresses))
+	for _, address := range addresses {
+		host, portStr, err := net.SplitHostPort(strings.TrimSpace(address))
+		port, perr := strconv.ParseUint(portStr, 10, 64)
+		if err != nil || perr != nil {
+			host, port = strings.TrimSpace(address), nacosConfig.Port
+		}
+		serverConfigs = append(serverConfigs, constant.ServerConfig{
+			IpAddr:      host,
+			Port:        port,
+			ContextPath: nacosConfig.ContextPath,
+		})
+	}
+	return serverConfigs
+}
+
+// GetClient returns the config client of a namespace, creating it on first use
+func (nc *NacosClient) GetClient(namespace string) (config_client.IConfigClient, error) {
+	if client, ok := nc.ConfigClients.Load(namespace); ok {
+		return client, nil
+	}
+
+	nacosConfig := nc.Config
 	clientConfig := constant.ClientConfig{
-		NamespaceId:         nc.Namespace,
+		NamespaceId:         namespace,
 		TimeoutMs:           5000,
 		NotLoadCacheAtStart: true,
 		LogDir:              nacosConfig.LogDir + "/log",
 		CacheDir:            nacosConfig.LogDir + "/cache",
 		LogLevel:            "debug",
+		Username:            nacosConfig.Username,
+		Password:            nacosConfig.Password,
+		AccessKey:           nacosConfig.AccessKey,
+		SecretKey:           nacosConfig.SecretKey,
+		ContextPath:         nacosConfig.ContextPath,
 	}
-	serverConfigs := []constant.ServerConfig{
-		{
-			IpAddr: nacosConfig.ServerAddr,
-			Port:   nacosConfig.Port,
-		},
+	serverConfigs := nacosServerConfigs(nacosConfig)
+	if len(serverConfigs) == 0 {
+		return nil, errors.New("no Nacos server configured")
 	}
 
-	var err error
-	nc.ConfigClient, err = clients.CreateConfigClient(map[string]interface{}{
+	client, err := clients.CreateConfigClient(map[string]interface{}{
 		"serverConfigs": serverConfigs,
 		"clientConfig":  clientConfig,
 	})
 	if err != nil {
 		msg := fmt.Sprintf("Failed to create Nacos client: %v", err)
-		return errors.New(msg)
+		return nil, errors.New(msg)
 	}
-	return nil
+	nc.ConfigClients.Store(namespace, client)
+	return client, nil
 }
 
-func (nc *NacosClient) SubscribeToDataIds(dataIds []string, userCallback ConfigChangeCallback, parentId string) {
-	for _, subId := range dataIds {
-		content, err := nc.ConfigClient.GetConfig(vo.ConfigParam{DataId: subId, Group: nc.Group})
-		if err != nil {
-			log.Printf("Failed to get initial config for sub-dataId %s: %v", subId, err)
-			continue
-		}
-		userCallback(subId, content, parentId)
-
-		subCallback := func(namespace, group, dataId, data string) {
-			userCallback(dataId, data, parentId)
-		}
-
-		if err := nc.ConfigClient.ListenConfig(vo.ConfigParam{DataId: subId, Group: nc.Group, OnChange: subCallback}); err != nil {
-			log.Printf("Failed to listen for config changes for sub-dataId %s: %v", subId, err)
-		} else {
-			var subscribedSubDataIds *xsync.Map[string, struct{}]
-			var ok bool
-			if subscribedSubDataIds, ok = nc.SubscribedSubDataIds.Load(parentId); !ok {
-				subscribedSubDataIds = xsync.NewMap[string, struct{}]()
-				nc.SubscribedSubDataIds.Store(parentId, subscribedSubDataIds)
-			}
-			subscribedSubDataIds.Store(subId, struct{}{})
-		}
+func (nc *NacosClient) GetConfig(binding *DataIdConfig, dataId string) (string, error) {
+	client, err := nc.GetClient(binding.Namespace)
+	if err != nil {
+		return "", err
 	}
-}
-
-func (nc *NacosClient) UnsubscribeFromDataIds(parentId string, dataIds []string) {
-	for _, subId := range dataIds {
-		if err := nc.ConfigClient.CancelListenConfig(vo.ConfigParam{DataId: subId, Group: nc.Group}); err != nil {
-			log.Printf("Failed to cancel listener for %s: %v", subId, err)
-		} else {
-			log.Printf("Cancelled listener for %s", subId)
-
-			var subscribedSubIds *xsync.Map[string, struct{}]
-			var ok bool
-			if subscribedSubIds, ok = nc.SubscribedSubDataIds.Load(parentId); !ok {
-				subscribedSubIds = xsync.NewMap[string, struct{}]()
-				nc.SubscribedSubDataIds.Store(parentId, subscribedSubIds)
-			}
-			subscribedSubIds.Delete(subId)
+	key := watchKey(binding, dataId)
+	content, err := client.GetConfig(vo.ConfigParam{DataId: dataId, Group: binding.Group})
+	if err != nil {
+		nc.health.setConnected(err)
+		snapshot, serr := nc.snapshot.GetConfig(binding, dataId)
+		if serr != nil || !nc.snapshot.Exists(binding, dataId) {
+			return "", err
 		}
+		log.Printf("Warning: using snapshot of %s: %v", key, err)
+		nc.health.markStale(key)
+		return snapshot, nil
 	}
-}
 
-func (nc *NacosClient) ProcessSubConfig(dataId, data string, userCallback ConfigChangeCallback) {
-	nc.Mu.Lock()
-	defer nc.Mu.Unlock()
-
-	var subDataIds []string
-	if data != "" {
-		if err := json.Unmarshal([]byte(data), &subDataIds); err != nil {
-			log.Printf("Failed to unmarshal sub dataId list from %s: %v", dataId, err)
-			return
-		}
-	} else {
-		log.Printf("Warning: configuration for %s is empty. No sub-data-ids to process.", dataId)
+	// 连接断开时 SDK 会返回其本地缓存，此时内容视为过期，不写入快照
+	if !nc.health.isConnected() {
+		nc.health.markStale(key)
+		return content, nil
 	}
+	nc.health.synced(key)
+	return content, nil
+}
 
-	if len(subDataIds) == 0 {
-		log.Printf("root configuration '%s' does not contain any sub-data-ids", dataId)
+func (nc *NacosClient) ListenConfig(binding *DataIdConfig, dataId string, onChange func(data string)) error {
+	client, err := nc.GetClient(binding.Namespace)
+	if err != nil {
+		return err
 	}
+	return client.ListenConfig(vo.ConfigParam{
+		DataId: dataId,
+		Group:  binding.Group,
+		OnChange: func(namespace, group, dataId, data string) {
+			nc.health.synced(watchKey(binding, dataId))
+			onChange(data)
+		},
+	})
+}
 
-	newSubDataIdsSet := make(map[string]struct{})
-	for _, subId := range subDataIds {
-		newSubDataIdsSet[subId] = struct{}{}
+// SaveSnapshot keeps content accepted by its handler as the fallback; content
+// served from the snapshot or the SDK cache while disconnected is skipped
+func (nc *NacosClient) SaveSnapshot(binding *DataIdConfig, dataId, content string) {
+	key := watchKey(binding, dataId)
+	if nc.health.isStale(key) {
+		return
 	}
-
-	var toUnsubscribe, toSubscribe []string
-
-	var subscribedSubDataIds *xsync.Map[string, struct{}]
-	var ok bool
-	if subscribedSubDataIds, ok = nc.SubscribedSubDataIds.Load(dataId); !ok {
-		subscribedSubDataIds = xsync.NewMap[string, struct{}]()
-		nc.SubscribedSubDataIds.Store(dataId, subscribedSubDataIds)
+	if err := nc.snapshot.Save(binding, dataId, content); err != nil {
+		log.Printf("Failed to save snapshot of %s: %v", key, err)
 	}
+}
 
-	subscribed := xsync.ToPlainMap(subscribedSubDataIds)
-	for oldSubId := range subscribed {
-		if _, exists := newSubDataIdsSet[oldSubId]; !exists {
-			toUnsubscribe = append(toUnsubscribe, oldSubId)
-		}
+func (nc *NacosClient) CancelListenConfig(binding *DataIdConfig, dataId string) error {
+	client, err := nc.GetClient(binding.Namespace)
+	if err != nil {
+		return err
 	}
+	return client.CancelListenConfig(vo.ConfigParam{DataId: dataId, Group: binding.Group})
+}
 
-	for subId := range newSubDataIdsSet {
-		if _, exists := subscribedSubDataIds.Load(subId); !exists {
-			toSubscribe = append(toSubscribe, subId)
-		}
+func (nc *NacosClient) PublishConfig(binding *DataIdConfig, dataId string, content string) error {
+	client, err := nc.GetClient(binding.Namespace)
+	if err != nil {
+		return err
 	}
-
-	if len(toUnsubscribe) > 0 {
-		nc.UnsubscribeFromDataIds(dataId, toUnsubscribe)
+	ok, err := client.PublishConfig(vo.ConfigParam{DataId: dataId, Group: binding.Group, Content: content})
+	if err != nil {
+		return err
 	}
-	if len(toSubscribe) > 0 {
-		nc.SubscribeToDataIds(toSubscribe, userCallback, dataId)
+	if !ok {
+		return fmt.Errorf("nacos rejected publishing %s", dataId)
 	}
+	// 快照在变更推送回来并被处理器接受后保存
+	return nil
 }
 
-func InitNacos(callback ConfigChangeCallback, nacosConfig *NacosConfig) error {
-	err := nacosClient.CreateClient(callback, nacosConfig)
+func (nc *NacosClient) DeleteConfig(binding *DataIdConfig, dataId string) error {
+	client, err := nc.GetClient(binding.Namespace)
 	if err != nil {
 		return err
 	}
-
-	// 没有子级配置项的回调处理
-	noneRootConfigCallback := func(namespace, group, dataId, data string) {
-		log.Println("non root config ", dataId)
-		callback(dataId, data, "")
-		log.Println("end for non root config ", dataId)
+	ok, err := client.DeleteConfig(vo.ConfigParam{DataId: dataId, Group: binding.Group})
+	if err != nil {
+		return err
 	}
-
-	// 有子级配置项，需要进一步订阅子主题变更
-	rootConfigCallback := func(namespace, group, dataId, data string) {
-		nacosClient.ProcessSubConfig(dataId, data, callback)
+	if !ok {
+		return fmt.Errorf("nacos rejected deleting %s", dataId)
 	}
+	nc.snapshot.Delete(binding, dataId)
+	return nil
+}
 
-	for _, dataId := range STARTUP_CONFIG_DATA_IDS {
-		content, err := nacosClient.ConfigClient.GetConfig(vo.ConfigParam{DataId: dataId, Group: nacosClient.Group})
-		if err != nil {
-			return fmt.Errorf("failed to get initial root config for %s: %v", dataId, err)
-		}
-
-		listenCallback := noneRootConfigCallback
-		if isRootDataId(dataId) {
-			listenCallback = rootConfigCallback
-			log.Println("-- Processing root config for", dataId)
-			rootConfigCallback(nacosConfig.Namespace, nacosConfig.Group, dataId, content)
-			log.Println("-- Processed root config for", dataId)
-		} else {
-			log.Println("-- Processing non-root config for", dataId)
-			callback(dataId, content, "")
-			log.Println("-- Processed non-root config for", dataId)
-		}
+func (nc *NacosClient) Health() ConfigHealth {
+	return nc.health.report()
+}
 
-		err = nacosClient.ConfigClient.ListenConfig(vo.ConfigParam{
-			DataId:   dataId,
-			Group:    nacosConfig.Group,
-			OnChange: listenCallback,
-		})
+// probe checks whether any Nacos server of the cluster is reachable; any
+// response other than a server error counts, auth may guard the endpoint
+func (nc *NacosClient) probe() error {
+	if len(nc.probeURLs) == 0 {
+		return errors.New("no Nacos server configured")
+	}
+	client := http.Client{Timeout: 3 * time.Second}
+	var lastErr error
+	for _, url := range nc.probeURLs {
+		resp, err := client.Get(url)
 		if err != nil {
-			return fmt.Errorf("failed to listen for root config changes for %s: %v", dataId, err)
+			lastErr = err
+			continue
 		}
+		resp.Body.Close()
+		if resp.StatusCode < http.StatusInternalServerError {
+			return nil
+		}
+		lastErr = fmt.Errorf("%s returned %s", url, resp.Status)
 	}
-
-	log.Println("Nacos configuration intialized")
-	nacosClient.Initialized.Store(true)
-	return nil
+	return lastErr
 }
 
-func isRootDataId(dataId string) bool {
-	return strings.HasSuffix(dataId, ROOT_CONFIG_SUFFIX)
+// startReconnect probes Nacos periodically and calls OnReconnect when it
+// comes back, or while some data IDs are still served from the snapshot
+func (nc *NacosClient) startReconnect() {
+	interval := nc.Config.ReconnectInterval
+	if interval <= 0 {
+		interval = NACOS_DEFAULT_RECONNECT_INTERVAL
+	}
+	nc.reconnectOnce.Do(func() {
+		go func() {
+			ticker := time.NewTicker(time.Duration(interval) * time.Second)
+			defer ticker.Stop()
+			for range ticker.C {
+				wasConnected := nc.health.isConnected()
+				if !nc.health.setConnected(nc.probe()) {
+					if wasConnected {
+						log.Printf("Warning: lost connection to Nacos: %s", nc.health.report().LastError)
+					}
+					continue
+				}
+				if wasConnected && !nc.health.hasStale() {
+					continue
+				}
+				log.Printf("Nacos is reachable, resyncing configuration")
+				if nc.OnReconnect != nil {
+					if err := nc.OnReconnect(); err != nil {
+						log.Printf("Failed to resync configuration from Nacos: %v", err)
+					}
+				}
+			}
+		}()
+	})
 }
 
-func CheckConfigReady() bool {
-	return nacosClient.Initialized.Load().(bool)
+// InitNacos loads configuration from Nacos and listens for changes. When
+// Nacos is unreachable the local snapshot is used and the client keeps
+// reconnecting in the background.
+func InitNacos(callback ConfigChangeCallback, nacosConfig *NacosConfig) error {
+	if err := nacosClient.CreateClient(nacosConfig); err != nil {
+		return err
+	}
+	provider := NewConfigProvider(PROVIDER_NACOS, nacosClient)
+	nacosClient.OnReconnect = provider.Resync
+	if err := InitConfigProvider(provider, callback, nacosConfig); err != nil {
+		return err
+	}
+	nacosClient.startReconnect()
+	return nil
 }
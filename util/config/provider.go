@@ -0,0 +1,323 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/puzpuzpuz/xsync/v4"
+)
+
+const (
+	ROOT_CONFIG_SUFFIX = "-root.json"
+
+	PROVIDER_NACOS = "nacos"
+	PROVIDER_FILE  = "file"
+	PROVIDER_REDIS = "redis"
+)
+
+type ConfigChangeCallback func(dataId, data, parentId string) error
+
+// ProviderConfig selects where configuration is loaded from
+type ProviderConfig struct {
+	Type     string `yaml:"type,omitempty"`     // nacos, file or redis
+	Dir      string `yaml:"dir,omitempty"`      // root directory of the file provider
+	Interval int    `yaml:"interval,omitempty"` // polling interval in seconds for file / redis
+}
+
+// ConfigSource is the storage a configuration provider reads from
+type ConfigSource interface {
+	GetConfig(binding *DataIdConfig, dataId string) (string, error)
+	ListenConfig(binding *DataIdConfig, dataId string, onChange func(data string)) error
+	CancelListenConfig(binding *DataIdConfig, dataId string) error
+}
+
+// SnapshotSource is implemented by sources that keep a local snapshot of
+// the configuration; only content accepted by its handler is saved, so the
+// snapshot never serves a rejected version
+type SnapshotSource interface {
+	SaveSnapshot(binding *DataIdConfig, dataId, content string)
+}
+
+// ConfigPublisher is implemented by sources that configuration can be written back to
+type ConfigPublisher interface {
+	PublishConfig(binding *DataIdConfig, dataId string, content string) error
+	DeleteConfig(binding *DataIdConfig, dataId string) error
+}
+
+// ConfigProvider loads the bound data IDs from a source, expands root data
+// IDs into their sub data IDs and keeps the subscriptions in sync
+type ConfigProvider struct {
+	Name                 string
+	Source               ConfigSource
+	Callback             ConfigChangeCallback
+	SubscribedSubDataIds xsync.Map[string, *xsync.Map[string, struct{}]]
+	Initialized          atomic.Value
+	Mu                   sync.Mutex
+	bindings             []*DataIdConfig
+	delivered            *xsync.Map[string, string] // MD5 of the last content delivered, by data ID
+	lastChange           atomic.Value
+}
+
+var CONFIG_PROVIDER *ConfigProvider
+
+func NewConfigProvider(name string, source ConfigSource) *ConfigProvider {
+	provider := &ConfigProvider{
+		Name:                 name,
+		Source:               source,
+		SubscribedSubDataIds: *xsync.NewMap[string, *xsync.Map[string, struct{}]](),
+		Mu:                   sync.Mutex{},
+		delivered:            xsync.NewMap[string, string](),
+	}
+	provider.Initialized.Store(false)
+	return provider
+}
+
+// InitConfigProvider loads every bound data ID from the source and listens
+// for changes; the provider becomes the global CONFIG_PROVIDER
+func InitConfigProvider(provider *ConfigProvider, callback ConfigChangeCallback, nacosConfig *NacosConfig) error {
+	CONFIG_PROVIDER = provider
+	provider.Callback = callback
+
+	bindings := GetDataIdConfigs(nacosConfig)
+	if err := SetDataIdConfigs(bindings, nacosConfig); err != nil {
+		return err
+	}
+
+	for _, dataId := range bindings {
+		binding, _ := GetDataIdConfig(dataId.DataId)
+		provider.bindings = append(provider.bindings, binding)
+		content, err := provider.Source.GetConfig(binding, binding.DataId)
+		if err != nil {
+			return fmt.Errorf("failed to get initial root config for %s: %v", binding.DataId, err)
+		}
+
+		if isRootDataId(binding.DataId) {
+			// 有子级配置项，需要进一步订阅子主题变更
+			log.Println("-- Processing root config for", binding.DataId)
+			provider.processRoot(binding, content)
+			log.Println("-- Processed root config for", binding.DataId)
+		} else {
+			log.Println("-- Processing non-root config for", binding.DataId)
+			provider.deliver(binding.DataId, content, "")
+			log.Println("-- Processed non-root config for", binding.DataId)
+		}
+
+		if err := provider.Source.ListenConfig(binding, binding.DataId, provider.listenCallback(binding)); err != nil {
+			return fmt.Errorf("failed to listen for root config changes for %s: %v", binding.DataId, err)
+		}
+	}
+
+	log.Printf("Configuration intialized from %s", provider.Name)
+	provider.Initialized.Store(true)
+	return nil
+}
+
+func (p *ConfigProvider) listenCallback(binding *DataIdConfig) func(data string) {
+	if isRootDataId(binding.DataId) {
+		return func(data string) {
+			p.processRoot(binding, data)
+		}
+	}
+	// 没有子级配置项的回调处理
+	return func(data string) {
+		log.Println("non root config ", binding.DataId)
+		p.deliver(binding.DataId, data, "")
+		log.Println("end for non root config ", binding.DataId)
+	}
+}
+
+// deliver passes a configuration change to the provider callback and
+// snapshots it once accepted
+func (p *ConfigProvider) deliver(dataId, data, parentId string) error {
+	p.delivered.Store(dataId, contentMD5(data))
+	p.lastChange.Store(time.Now())
+	if err := p.Callback(dataId, data, parentId); err != nil {
+		return err
+	}
+	rootId := parentId
+	if rootId == "" {
+		rootId = dataId
+	}
+	if binding, ok := GetDataIdConfig(rootId); ok {
+		p.saveSnapshot(binding, dataId, data)
+	}
+	return nil
+}
+
+// processRoot applies the sub data ID list of a root data ID and snapshots
+// it once accepted
+func (p *ConfigProvider) processRoot(binding *DataIdConfig, data string) {
+	if err := p.ProcessSubConfig(binding, data, p.deliver); err == nil {
+		p.saveSnapshot(binding, binding.DataId, data)
+	}
+}
+
+func (p *ConfigProvider) saveSnapshot(binding *DataIdConfig, dataId, data string) {
+	if source, ok := p.Source.(SnapshotSource); ok {
+		source.SaveSnapshot(binding, dataId, data)
+	}
+}
+
+// deliverIfChanged skips content identical to what was last delivered
+func (p *ConfigProvider) deliverIfChanged(dataId, data, parentId string) error {
+	if sum, ok := p.delivered.Load(dataId); ok && sum == contentMD5(data) {
+		return nil
+	}
+	log.Printf("Config %s changed while disconnected, applying", dataId)
+	return p.deliver(dataId, data, parentId)
+}
+
+// Resync re-reads every bound data ID after the source reconnects, applies
+// content that changed in the meantime and re-subscribes all sub data IDs
+func (p *ConfigProvider) Resync() error {
+	for _, binding := range p.bindings {
+		content, err := p.Source.GetConfig(binding, binding.DataId)
+		if err != nil {
+			return fmt.Errorf("failed to resync %s: %v", binding.DataId, err)
+		}
+		p.Source.CancelListenConfig(binding, binding.DataId)
+		if err := p.Source.ListenConfig(binding, binding.DataId, p.listenCallback(binding)); err != nil {
+			return fmt.Errorf("failed to listen for config changes for %s: %v", binding.DataId, err)
+		}
+
+		if !isRootDataId(binding.DataId) {
+			p.deliverIfChanged(binding.DataId, content, "")
+			continue
+		}
+
+		// 先按最新列表增删订阅，再重新订阅已有的子配置
+		p.processRoot(binding, content)
+		for subId := range xsync.ToPlainMap(p.subscribedSubDataIds(binding.DataId)) {
+			if err := p.resubscribe(binding, subId, binding.DataId); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (p *ConfigProvider) resubscribe(binding *DataIdConfig, subId, parentId string) error {
+	content, err := p.Source.GetConfig(binding, subId)
+	if err != nil {
+		return fmt.Errorf("failed to resync %s: %v", subId, err)
+	}
+	p.Source.CancelListenConfig(binding, subId)
+	if err := p.Source.ListenConfig(binding, subId, p.subCallback(subId, parentId, p.deliver)); err != nil {
+		return fmt.Errorf("failed to listen for config changes for %s: %v", subId, err)
+	}
+	p.deliverIfChanged(subId, content, parentId)
+	return nil
+}
+
+func (p *ConfigProvider) subCallback(subId, parentId string, userCallback ConfigChangeCallback) func(data string) {
+	return func(data string) {
+		userCallback(subId, data, parentId)
+	}
+}
+
+func (p *ConfigProvider) SubscribeToDataIds(binding *DataIdConfig, dataIds []string, userCallback ConfigChangeCallback, parentId string) {
+	for _, subId := range dataIds {
+		content, err := p.Source.GetConfig(binding, subId)
+		if err != nil {
+			log.Printf("Failed to get initial config for sub-dataId %s: %v", subId, err)
+			continue
+		}
+		userCallback(subId, content, parentId)
+
+		if err := p.Source.ListenConfig(binding, subId, p.subCallback(subId, parentId, userCallback)); err != nil {
+			log.Printf("Failed to listen for config changes for sub-dataId %s: %v", subId, err)
+		} else {
+			p.subscribedSubDataIds(parentId).Store(subId, struct{}{})
+		}
+	}
+}
+
+func (p *ConfigProvider) UnsubscribeFromDataIds(binding *DataIdConfig, parentId string, dataIds []string) {
+	for _, subId := range dataIds {
+		if err := p.Source.CancelListenConfig(binding, subId); err != nil {
+			log.Printf("Failed to cancel listener for %s: %v", subId, err)
+		} else {
+			log.Printf("Cancelled listener for %s", subId)
+			p.subscribedSubDataIds(parentId).Delete(subId)
+		}
+	}
+}
+
+func (p *ConfigProvider) subscribedSubDataIds(parentId string) *xsync.Map[string, struct{}] {
+	subscribed, _ := p.SubscribedSubDataIds.LoadOrCompute(parentId, func() (*xsync.Map[string, struct{}], bool) {
+		return xsync.NewMap[string, struct{}](), false
+	})
+	return subscribed
+}
+
+// ProcessSubConfig subscribes to the sub data IDs listed by a root data ID
+// and drops the ones no longer listed; an invalid list is rejected
+func (p *ConfigProvider) ProcessSubConfig(binding *DataIdConfig, data string, userCallback ConfigChangeCallback) error {
+	p.Mu.Lock()
+	defer p.Mu.Unlock()
+
+	dataId := binding.DataId
+	var subDataIds []string
+	if data != "" {
+		if err := json.Unmarshal([]byte(data), &subDataIds); err != nil {
+			log.Printf("Failed to unmarshal sub dataId list from %s: %v", dataId, err)
+			report := NewValidationReport(dataId)
+			report.Handler = binding.Handler
+			report.Accepted = false
+			report.Error(0, "", "root config must be a JSON list of data ids: %v", err)
+			storeValidationReport(report)
+			return report.Err()
+		}
+	} else {
+		log.Printf("Warning: configuration for %s is empty. No sub-data-ids to process.", dataId)
+	}
+
+	if len(subDataIds) == 0 {
+		log.Printf("root configuration '%s' does not contain any sub-data-ids", dataId)
+	}
+
+	newSubDataIdsSet := make(map[string]struct{})
+	for _, subId := range subDataIds {
+		newSubDataIdsSet[subId] = struct{}{}
+	}
+
+	var toUnsubscribe, toSubscribe []string
+
+	subscribedSubDataIds := p.subscribedSubDataIds(dataId)
+	subscribed := xsync.ToPlainMap(subscribedSubDataIds)
+	for oldSubId := range subscribed {
+		if _, exists := newSubDataIdsSet[oldSubId]; !exists {
+			toUnsubscribe = append(toUnsubscribe, oldSubId)
+		}
+	}
+
+	for subId := range newSubDataIdsSet {
+		if _, exists := subscribedSubDataIds.Load(subId); !exists {
+			toSubscribe = append(toSubscribe, subId)
+		}
+	}
+
+	if len(toUnsubscribe) > 0 {
+		p.UnsubscribeFromDataIds(binding, dataId, toUnsubscribe)
+	}
+	if len(toSubscribe) > 0 {
+		p.SubscribeToDataIds(binding, toSubscribe, userCallback, dataId)
+	}
+	return nil
+}
+
+func isRootDataId(dataId string) bool {
+	return strings.HasSuffix(dataId, ROOT_CONFIG_SUFFIX)
+}
+
+func CheckConfigReady() bool {
+	if CONFIG_PROVIDER == nil {
+		return false
+	}
+	return CONFIG_PROVIDER.Initialized.Load().(bool)
+}
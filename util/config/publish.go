@@ -0,0 +1,504 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/puzpuzpuz/xsync/v4"
+)
+
+var (
+	ErrConfigInvalid       = errors.New("invalid configuration")
+	ErrConfigNotFound      = errors.New("configuration not found")
+	ErrConfigConflict      = errors.New("configuration was modified concurrently, reload and retry")
+	ErrPublishNotSupported = errors.New("configuration provider does not support publishing")
+	// ErrConfigMD5Required is a conflict: changing existing content needs its md5
+	ErrConfigMD5Required = fmt.Errorf("%w: md5 of the current content is required", ErrConfigConflict)
+)
+
+// publishLocks serializes the md5 check and the write of a data ID on this
+// node. The Nacos SDK in use has no CAS publish and the file and Redis
+// sources have no compare-and-set either, so writers on other nodes or
+// edits made directly in the source can still race with the check.
+var publishLocks = xsync.NewMap[string, *sync.Mutex]()
+
+// lockDataId holds the publish lock of a data ID until the returned func is called
+func lockDataId(binding *DataIdConfig, dataId string) func() {
+	mu, _ := publishLocks.LoadOrCompute(watchKey(binding, dataId), func() (*sync.Mutex, bool) {
+		return &sync.Mutex{}, false
+	})
+	mu.Lock()
+	return mu.Unlock
+}
+
+// ConfigDocument is the content of a data ID as stored in the source. MD5 is
+// passed back on publish to detect concurrent modification.
+type ConfigDocument struct {
+	DataId   string `json:"dataId"`
+	ParentId string `json:"parentId,omitempty"`
+	Handler  string `json:"handler"`
+	Content  string `json:"content"`
+	MD5      string `json:"md5"`
+}
+
+// ListConfigDocuments lists every bound data ID followed by its sub data IDs
+func ListConfigDocuments() []ConfigDocument {
+	provider := CONFIG_PROVIDER
+	if provider == nil {
+		return []ConfigDocument{}
+	}
+	docs := make([]ConfigDocument, 0)
+	for _, binding := range provider.bindings {
+		docs = append(docs, ConfigDocument{DataId: binding.DataId, Handler: binding.Handler})
+		if !isRootDataId(binding.DataId) {
+			continue
+		}
+		subIds := xsync.ToPlainMap(provider.subscribedSubDataIds(binding.DataId))
+		for _, subId := range sortedKeys(subIds) {
+			docs = append(docs, ConfigDocument{DataId: subId, ParentId: binding.DataId, Handler: binding.Handler})
+		}
+	}
+	return docs
+}
+
+// GetConfigDocument reads the current content of a data ID from the source
+func GetConfigDocument(dataId, parentId string) (*ConfigDocument, error) {
+	provider, binding, parentId, err := resolveBinding(dataId, parentId)
+	if err != nil {
+		return nil, err
+	}
+	content, err := provider.Source.GetConfig(binding, dataId)
+	if err != nil {
+		return nil, err
+	}
+	return &ConfigDocument{
+		DataId:   dataId,
+		ParentId: parentId,
+		Handler:  binding.Handler,
+		Content:  content,
+		MD5:      contentMD5(content),
+	}, nil
+}
+
+// PublishConfig validates content with the handler of the data ID and
+// writes it to the source. New sub data IDs are added to the root list.
+// expectedMD5 must match the current content and may only be empty when the
+// data ID has no content yet; user is recorded in the change history of the
+// devices it changes.
+func PublishConfig(dataId, parentId, content, expectedMD5, user string) (*ConfigDocument, error) {
+	provider, binding, parentId, err := resolveBinding(dataId, parentId)
+	if err != nil {
+		return nil, err
+	}
+	publisher, ok := provider.Source.(ConfigPublisher)
+	if !ok {
+		return nil, ErrPublishNotSupported
+	}
+
+	if err := validateConfig(binding, dataId, parentId, content); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrConfigInvalid, err)
+	}
+	unlock := lockDataId(binding, dataId)
+	if err := checkMD5(provider, binding, dataId, expectedMD5); err != nil {
+		unlock()
+		return nil, err
+	}
+	notePublisher(dataId, content, user)
+	err = publisher.PublishConfig(binding, dataId, content)
+	unlock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to publish %s: %w", dataId, err)
+	}
+	log.Printf("Published config %s by %s", dataId, user)
+
+	if parentId != "" {
+		err := updateRootList(provider, binding, func(subIds []string) ([]string, bool) {
+			for _, subId := range subIds {
+				if subId == dataId {
+					return subIds, false
+				}
+			}
+			return append(subIds, dataId), true
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &ConfigDocument{
+		DataId:   dataId,
+		ParentId: parentId,
+		Handler:  binding.Handler,
+		Content:  content,
+		MD5:      contentMD5(content),
+	}, nil
+}
+
+// DeleteConfig removes a sub data ID from its root list and the source.
+// Data IDs bound in config.yaml cannot be deleted.
+func DeleteConfig(dataId, parentId, expectedMD5 string) error {
+	provider, binding, parentId, err := resolveBinding(dataId, parentId)
+	if err != nil {
+		return err
+	}
+	if parentId == "" {
+		return fmt.Errorf("%w: %s is bound in the configuration file and cannot be deleted", ErrConfigInvalid, dataId)
+	}
+	publisher, ok := provider.Source.(ConfigPublisher)
+	if !ok {
+		return ErrPublishNotSupported
+	}
+	unlock := lockDataId(binding, dataId)
+	defer unlock()
+	if err := checkMD5(provider, binding, dataId, expectedMD5); err != nil {
+		return err
+	}
+
+	err = updateRootList(provider, binding, func(subIds []string) ([]string, bool) {
+		result := make([]string, 0, len(subIds))
+		for _, subId := range subIds {
+			if subId != dataId {
+				result = append(result, subId)
+			}
+		}
+		return result, len(result) != len(subIds)
+	})
+	if err != nil {
+		return err
+	}
+	if err := publisher.DeleteConfig(binding, dataId); err != nil {
+		return fmt.Errorf("failed to delete %s: %w", dataId, err)
+	}
+	log.Printf("Deleted config %s", dataId)
+	return nil
+}
+
+// resolveBinding finds the binding of a data ID; sub data IDs use the
+// binding of their root
+func resolveBinding(dataId, parentId string) (*ConfigProvider, *DataIdConfig, string, error) {
+	provider := CONFIG_PROVIDER
+	if provider == nil {
+		return nil, nil, "", errors.New("configuration provider not initialized")
+	}
+	if dataId == "" {
+		return nil, nil, "", fmt.Errorf("%w: data id is required", ErrConfigInvalid)
+	}
+	if err := checkDataId(dataId); err != nil {
+		return nil, nil, "", fmt.Errorf("%w: %v", ErrConfigInvalid, err)
+	}
+	if parentId == "" {
+		if binding, ok := GetDataIdConfig(dataId); ok {
+			return provider, binding, "", nil
+		}
+		parentId = provider.findParent(dataId)
+		if parentId == "" {
+			return nil, nil, "", fmt.Errorf("%w: data id %s", ErrConfigNotFound, dataId)
+		}
+	}
+	binding, ok := GetDataIdConfig(parentId)
+	if !ok || !isRootDataId(parentId) {
+		return nil, nil, "", fmt.Errorf("%w: %s is not a root data id", ErrConfigInvalid, parentId)
+	}
+	return provider, binding, parentId, nil
+}
+
+// findParent returns the root data ID that lists the sub data ID
+func (p *ConfigProvider) findParent(dataId string) string {
+	parentId := ""
+	p.SubscribedSubDataIds.Range(func(rootId string, subIds *xsync.Map[string, struct{}]) bool {
+		if _, ok := subIds.Load(dataId); ok {
+			parentId = rootId
+			return false
+		}
+		return true
+	})
+	return parentId
+}
+
+// findRootBinding returns the first root data ID bound to a handler
+func (p *ConfigProvider) findRootBinding(handler string) (*DataIdConfig, bool) {
+	for _, binding := range p.bindings {
+		if binding.Handler == handler && isRootDataId(binding.DataId) {
+			return binding, true
+		}
+	}
+	return nil, false
+}
+
+func validateConfig(binding *DataIdConfig, dataId, parentId, content string) error {
+	if parentId == "" && isRootDataId(dataId) {
+		_, err := parseRootList(dataId, content)
+		return err
+	}
+	handler, ok := GetConfigHandler(binding.Handler)
+	if !ok {
+		return fmt.Errorf("unknown handler %q", binding.Handler)
+	}
+	if handler.Validate == nil {
+		return nil
+	}
+	return handler.Validate(dataId, content).Err()
+}
+
+func checkMD5(provider *ConfigProvider, binding *DataIdConfig, dataId, expectedMD5 string) error {
+	current, err := provider.Source.GetConfig(binding, dataId)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", dataId, err)
+	}
+	return matchMD5(current, expectedMD5)
+}
+
+// matchMD5 checks expectedMD5 against the current content; only a data ID
+// without content can be written without one
+func matchMD5(current, expectedMD5 string) error {
+	if expectedMD5 == "" {
+		if current == "" {
+			return nil
+		}
+		return ErrConfigMD5Required
+	}
+	if contentMD5(current) != expectedMD5 {
+		return ErrConfigConflict
+	}
+	return nil
+}
+
+func parseRootList(dataId, content string) ([]string, error) {
+	var subIds []string
+	if strings.TrimSpace(content) == "" {
+		return subIds, nil
+	}
+	if err := json.Unmarshal([]byte(content), &subIds); err != nil {
+		return nil, fmt.Errorf("%s must be a JSON list of data ids: %v", dataId, err)
+	}
+	for _, subId := range subIds {
+		if err := checkDataId(subId); err != nil {
+			return nil, fmt.Errorf("%s: %v", dataId, err)
+		}
+	}
+	return subIds, nil
+}
+
+// updateRootList rewrites the sub data ID list of a root data ID
+func updateRootList(provider *ConfigProvider, binding *DataIdConfig, modify func(subIds []string) ([]string, bool)) error {
+	unlock := lockDataId(binding, binding.DataId)
+	defer unlock()
+	content, err := provider.Source.GetConfig(binding, binding.DataId)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", binding.DataId, err)
+	}
+	subIds, err := parseRootList(binding.DataId, content)
+	if err != nil {
+		return err
+	}
+	subIds, changed := modify(subIds)
+	if !changed {
+		return nil
+	}
+	data, _ := json.MarshalIndent(subIds, "", "  ")
+	if err := provider.Source.(ConfigPublisher).PublishConfig(binding, binding.DataId, string(data)); err != nil {
+		return fmt.Errorf("failed to publish %s: %w", binding.DataId, err)
+	}
+	log.Printf("Published config %s", binding.DataId)
+	return nil
+}
+
+// SaveDevice adds or replaces a device in the device list data ID that
+// defines it; new devices need the data ID to be added to
+func SaveDevice(device *DeviceConfig, dataId, expectedMD5, user string) (*ConfigDocument, error) {
+	if device == nil || strings.TrimSpace(device.Name) == "" {
+		return nil, fmt.Errorf("%w: device name is required", ErrConfigInvalid)
+	}
+	existing := findDeviceDataId(device.Name)
+	if dataId == "" {
+		dataId = existing
+	}
+	if dataId == "" {
+		return nil, fmt.Errorf("%w: data id is required for new device %s", ErrConfigInvalid, device.Name)
+	}
+	if existing != "" && existing != dataId {
+		return nil, fmt.Errorf("%w: device %s is defined in %s", ErrConfigInvalid, device.Name, existing)
+	}
+	if GetDeviceTypeConfig(device.Type) == nil {
+		return nil, fmt.Errorf("%w: unknown device type %q", ErrConfigInvalid, device.Type)
+	}
+
+	return editDeviceList(dataId, expectedMD5, user, func(devices []*DeviceConfig) []*DeviceConfig {
+		for i, cfg := range devices {
+			if cfg.Name == device.Name {
+				devices[i] = device
+				return devices
+			}
+		}
+		return append(devices, device)
+	})
+}
+
+// RemoveDevice removes a device from the device list data ID that defines it
+func RemoveDevice(name, expectedMD5, user string) (*ConfigDocument, error) {
+	dataId := findDeviceDataId(name)
+	if dataId == "" {
+		return nil, fmt.Errorf("%w: device %s", ErrConfigNotFound, name)
+	}
+	return editDeviceList(dataId, expectedMD5, user, func(devices []*DeviceConfig) []*DeviceConfig {
+		result := make([]*DeviceConfig, 0, len(devices))
+		for _, cfg := range devices {
+			if cfg.Name != name {
+				result = append(result, cfg)
+			}
+		}
+		return result
+	})
+}
+
+func editDeviceList(dataId, expectedMD5, user string, edit func(devices []*DeviceConfig) []*DeviceConfig) (*ConfigDocument, error) {
+	provider := CONFIG_PROVIDER
+	if provider == nil {
+		return nil, errors.New("configuration provider not initialized")
+	}
+	parentId := provider.findParent(dataId)
+	if parentId == "" {
+		root, ok := provider.findRootBinding(HANDLER_DEVICE_LIST)
+		if !ok {
+			return nil, fmt.Errorf("%w: no device list root data id", ErrConfigNotFound)
+		}
+		parentId = root.DataId
+	}
+
+	doc, err := GetConfigDocument(dataId, parentId)
+	if err != nil {
+		return nil, err
+	}
+	if err := matchMD5(doc.Content, expectedMD5); err != nil {
+		return nil, err
+	}
+	devices, err := DEVICES_CONFIG.parseDeviceConfigs(dataId, doc.Content)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrConfigInvalid, err)
+	}
+	data, _ := json.MarshalIndent(edit(*devices), "", "  ")
+	return PublishConfig(dataId, parentId, string(data), doc.MD5, user)
+}
+
+// findDeviceDataId returns the device list data ID that defines a device
+func findDeviceDataId(name string) string {
+	found := ""
+	DEVICES_CONFIG.DataIdConfigMap.Range(func(dataId string, content string) bool {
+		devices, err := DEVICES_CONFIG.parseDeviceConfigs(dataId, content)
+		if err != nil {
+			return true
+		}
+		for _, cfg := range *devices {
+			if cfg.Name == name {
+				found = dataId
+				return false
+			}
+		}
+		return true
+	})
+	return found
+}
+
+// SaveDeviceType adds or replaces a device type in the device types data ID
+func SaveDeviceType(name string, typeConfig *DeviceTypeConfig, expectedMD5, user string) (*ConfigDocument, error) {
+	if strings.TrimSpace(name) == "" || typeConfig == nil {
+		return nil, fmt.Errorf("%w: device type name is required", ErrConfigInvalid)
+	}
+	return editDeviceTypes(expectedMD5, user, func(types map[string]interface{}) {
+		types[name] = typeConfig
+	})
+}
+
+// RemoveDeviceType removes a device type that no device uses
+func RemoveDeviceType(name, expectedMD5, user string) (*ConfigDocument, error) {
+	for _, device := range GetAllDeviceConfig() {
+		if device.Type == name {
+			return nil, fmt.Errorf("%w: device type %s is used by %s", ErrConfigInvalid, name, device.Name)
+		}
+	}
+	return editDeviceTypes(expectedMD5, user, func(types map[string]interface{}) {
+		delete(types, name)
+	})
+}
+
+// editDeviceTypes edits the device_types object, keeping other fields as is
+func editDeviceTypes(expectedMD5, user string, edit func(types map[string]interface{})) (*ConfigDocument, error) {
+	provider := CONFIG_PROVIDER
+	if provider == nil {
+		return nil, errors.New("configuration provider not initialized")
+	}
+	var binding *DataIdConfig
+	for _, b := range provider.bindings {
+		if b.Handler == HANDLER_DEVICE_TYPES && !isRootDataId(b.DataId) {
+			binding = b
+			break
+		}
+	}
+	if binding == nil {
+		return nil, fmt.Errorf("%w: no device types data id", ErrConfigNotFound)
+	}
+
+	doc, err := GetConfigDocument(binding.DataId, "")
+	if err != nil {
+		return nil, err
+	}
+	if err := matchMD5(doc.Content, expectedMD5); err != nil {
+		return nil, err
+	}
+	content := make(map[string]interface{})
+	if strings.TrimSpace(doc.Content) != "" {
+		if err := json.Unmarshal([]byte(doc.Content), &content); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrConfigInvalid, err)
+		}
+	}
+	types, _ := content["device_types"].(map[string]interface{})
+	if types == nil {
+		types = make(map[string]interface{})
+	}
+	edit(types)
+	content["device_types"] = types
+
+	data, _ := json.MarshalIndent(content, "", "  ")
+	return PublishConfig(binding.DataId, "", string(data), doc.MD5, user)
+}
+
+// PublishSubConfig publishes a sub data ID under the first root data ID
+// bound to the handler, e.g. a protocol or dictionary CSV
+func PublishSubConfig(handler, dataId, content, expectedMD5, user string) (*ConfigDocument, error) {
+	parentId, err := subConfigParent(handler, dataId)
+	if err != nil {
+		return nil, err
+	}
+	return PublishConfig(dataId, parentId, content, expectedMD5, user)
+}
+
+// DeleteSubConfig deletes a sub data ID bound to the handler
+func DeleteSubConfig(handler, dataId, expectedMD5 string) error {
+	parentId, err := subConfigParent(handler, dataId)
+	if err != nil {
+		return err
+	}
+	return DeleteConfig(dataId, parentId, expectedMD5)
+}
+
+func subConfigParent(handler, dataId string) (string, error) {
+	provider := CONFIG_PROVIDER
+	if provider == nil {
+		return "", errors.New("configuration provider not initialized")
+	}
+	if parentId := provider.findParent(dataId); parentId != "" {
+		if binding, ok := GetDataIdConfig(parentId); ok && binding.Handler != handler {
+			return "", fmt.Errorf("%w: %s belongs to %s", ErrConfigInvalid, dataId, parentId)
+		}
+		return parentId, nil
+	}
+	root, ok := provider.findRootBinding(handler)
+	if !ok {
+		return "", fmt.Errorf("%w: no root data id bound to %s", ErrConfigNotFound, handler)
+	}
+	return root.DataId, nil
+}
@@ -0,0 +1,217 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/puzpuzpuz/xsync/v4"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	HANDLER_DEVICE_LIST  = "device-list"
+	HANDLER_DEVICE_TYPES = "device-types"
+	HANDLER_CSV_PROTOCOL = "csv-protocol"
+	HANDLER_CSV_DICT     = "csv-dict"
+	HANDLER_RAW          = "raw"
+	HANDLER_SCRIPT       = "script"
+
+	EVENT_RAW_UPDATE = "raw.update"
+)
+
+// ConfigUpdateFunc applies the content of a (sub) data ID bound to a handler
+type ConfigUpdateFunc func(binding *DataIdConfig, dataId, data string) error
+
+// ConfigHandler is a named configuration kind that data IDs can be bound to
+type ConfigHandler struct {
+	Name   string
+	Update ConfigUpdateFunc
+	// Validate checks content before it is applied or published, optional.
+	// Updates whose report has errors are rejected.
+	Validate func(dataId, data string) *ValidationReport
+	// Device marks handlers that change devices or device types, after which
+	// the device configuration is re-applied
+	Device bool
+}
+
+var (
+	configHandlers = xsync.NewMap[string, *ConfigHandler]()
+	configBindings = xsync.NewMap[string, *DataIdConfig]()
+
+	// RAW_CONFIG holds parsed JSON / YAML content of data IDs bound to the raw handler
+	RAW_CONFIG = xsync.NewMap[string, interface{}]()
+)
+
+func init() {
+	RegisterConfigHandler(&ConfigHandler{
+		Name:   HANDLER_DEVICE_LIST,
+		Device: true,
+		Update: func(binding *DataIdConfig, dataId, data string) error {
+			return UpdateDeviceConfig(dataId, data)
+		},
+		Validate: validateDeviceConfig,
+	})
+	RegisterConfigHandler(&ConfigHandler{
+		Name:   HANDLER_DEVICE_TYPES,
+		Device: true,
+		Update: func(binding *DataIdConfig, dataId, data string) error {
+			return UpdateDeviceTypeConfig(dataId, data)
+		},
+		Validate: validateDeviceTypeConfig,
+	})
+	RegisterConfigHandler(&ConfigHandler{
+		Name: HANDLER_CSV_PROTOCOL,
+		Update: func(binding *DataIdConfig, dataId, data string) error {
+			return UpdateProtocolConfig(dataId, data)
+		},
+		Validate: func(dataId, data string) *ValidationReport {
+			_, report := ValidateModbusProtocol(dataId, data)
+			return report
+		},
+	})
+	RegisterConfigHandler(&ConfigHandler{
+		Name: HANDLER_CSV_DICT,
+		Update: func(binding *DataIdConfig, dataId, data string) error {
+			return UpdateDictionaryConfig(dataId, data)
+		},
+		Validate: validateDictionaryConfig,
+	})
+	RegisterConfigHandler(&ConfigHandler{
+		Name:   HANDLER_RAW,
+		Update: updateRawConfig,
+		Validate: func(dataId, data string) *ValidationReport {
+			report := NewValidationReport(dataId)
+			if _, err := parseRawConfig(dataId, data); err != nil {
+				report.Error(0, "", "%v", err)
+			}
+			return report
+		},
+	})
+	RegisterConfigHandler(&ConfigHandler{
+		Name:   HANDLER_SCRIPT,
+		Update: updateScriptConfig,
+	})
+}
+
+// RegisterConfigHandler adds or replaces a configuration handler
+func RegisterConfigHandler(handler *ConfigHandler) {
+	configHandlers.Store(handler.Name, handler)
+}
+
+func GetConfigHandler(name string) (*ConfigHandler, bool) {
+	return configHandlers.Load(name)
+}
+
+// SetDataIdConfigs replaces the data ID bindings, filling in the default
+// group and namespace
+func SetDataIdConfigs(bindings []DataIdConfig, nacosConfig *NacosConfig) error {
+	configBindings.Clear()
+	for i := range bindings {
+		binding := bindings[i]
+		if binding.DataId == "" {
+			return fmt.Errorf("data id binding #%d has no dataId", i)
+		}
+		if _, ok := GetConfigHandler(binding.Handler); !ok {
+			return fmt.Errorf("unknown handler %q for data id %s", binding.Handler, binding.DataId)
+		}
+		if binding.Handler == HANDLER_SCRIPT && binding.Script == "" {
+			return fmt.Errorf("data id %s is bound to the script handler without a script", binding.DataId)
+		}
+		if binding.Group == "" {
+			binding.Group = nacosConfig.Group
+		}
+		if binding.Namespace == "" {
+			binding.Namespace = nacosConfig.Namespace
+		}
+		configBindings.Store(binding.DataId, &binding)
+	}
+	return nil
+}
+
+// GetDataIdConfigs returns the bindings in configuration order
+func GetDataIdConfigs(nacosConfig *NacosConfig) []DataIdConfig {
+	if len(nacosConfig.DataIds) > 0 {
+		return nacosConfig.DataIds
+	}
+	return DEFAULT_DATA_ID_CONFIGS
+}
+
+// GetDataIdConfig returns the binding of a root (or standalone) data ID
+func GetDataIdConfig(dataId string) (*DataIdConfig, bool) {
+	return configBindings.Load(dataId)
+}
+
+// DispatchConfigChange routes a (sub) data ID change to the handler bound to
+// its root data ID and returns that handler
+func DispatchConfigChange(dataId, data, parentId string) (*ConfigHandler, error) {
+	rootId := parentId
+	if rootId == "" {
+		rootId = dataId
+	}
+	binding, ok := GetDataIdConfig(rootId)
+	if !ok {
+		return nil, fmt.Errorf("no handler bound to data id %s", rootId)
+	}
+	handler, ok := GetConfigHandler(binding.Handler)
+	if !ok {
+		return nil, fmt.Errorf("unknown handler %q for data id %s", binding.Handler, rootId)
+	}
+	if handler.Validate != nil {
+		report := handler.Validate(dataId, data)
+		report.Handler = handler.Name
+		if report.HasErrors() {
+			report.Accepted = false
+			storeValidationReport(report)
+			return handler, fmt.Errorf("rejected invalid config, keeping the previous version: %w", report.Err())
+		}
+		storeValidationReport(report)
+	}
+	if err := handler.Update(binding, dataId, data); err != nil {
+		return handler, fmt.Errorf("%s handler failed for %s: %w", handler.Name, dataId, err)
+	}
+	return handler, nil
+}
+
+// 解析 JSON / YAML 原始配置，按扩展名判断格式，未知扩展名时先尝试 JSON
+func parseRawConfig(dataId, data string) (interface{}, error) {
+	var value interface{}
+	if strings.TrimSpace(data) != "" {
+		var err error
+		if strings.HasSuffix(dataId, ".yaml") || strings.HasSuffix(dataId, ".yml") {
+			err = yaml.Unmarshal([]byte(data), &value)
+		} else if err = json.Unmarshal([]byte(data), &value); err != nil && !strings.HasSuffix(dataId, ".json") {
+			err = yaml.Unmarshal([]byte(data), &value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid raw config %s: %v", dataId, err)
+		}
+	}
+	return value, nil
+}
+
+func updateRawConfig(binding *DataIdConfig, dataId, data string) error {
+	value, err := parseRawConfig(dataId, data)
+	if err != nil {
+		return err
+	}
+
+	previous, _ := RAW_CONFIG.Load(dataId)
+	RAW_CONFIG.Store(dataId, value)
+	fireConfigEvent(EVENT_RAW_UPDATE, dataId, previous, value)
+	return nil
+}
+
+func updateScriptConfig(binding *DataIdConfig, dataId, data string) error {
+	RAW_CONFIG.Store(dataId, data)
+	if CONFIG_CHANGE_HANDLERS.ScriptConfigHandler == nil {
+		log.Printf("No script config handler, ignore %s", dataId)
+		return nil
+	}
+	return CONFIG_CHANGE_HANDLERS.ScriptConfigHandler(binding.Script, dataId, data)
+}
+
+func GetRawConfig(dataId string) (interface{}, bool) {
+	return RAW_CONFIG.Load(dataId)
+}
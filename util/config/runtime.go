@@ -17,9 +17,9 @@ const (
 	DATA_ID_DICT_CONFIG        = "dict-root.json"
 )
 
-var STARTUP_CONFIG_DATA_IDS = []string{
-	DATA_ID_DEVICE_CONFIG,
-	DATA_ID_DEVICE_TYPE_CONFIG,
-	DATA_ID_PROTOCOL_CONFIG,
-	DATA_ID_DICT_CONFIG,
+var DEFAULT_DATA_ID_CONFIGS = []DataIdConfig{
+	{DataId: DATA_ID_DEVICE_CONFIG, Handler: HANDLER_DEVICE_LIST},
+	{DataId: DATA_ID_DEVICE_TYPE_CONFIG, Handler: HANDLER_DEVICE_TYPES},
+	{DataId: DATA_ID_PROTOCOL_CONFIG, Handler: HANDLER_CSV_PROTOCOL},
+	{DataId: DATA_ID_DICT_CONFIG, Handler: HANDLER_CSV_DICT},
 }
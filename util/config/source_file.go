@@ -0,0 +1,120 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileSource reads configuration from a local directory laid out like
+// Nacos: <dir>/<namespace>/<group>/<dataId>. Root data IDs are JSON lists of
+// sub data ID file names in the same directory. Missing files are empty.
+type FileSource struct {
+	Dir     string
+	watcher *pollWatcher
+}
+
+func NewFileSource(dir string, interval int) *FileSource {
+	source := &FileSource{Dir: dir}
+	source.watcher = newPollWatcher(interval, source.GetConfig)
+	return source
+}
+
+// Path returns the file of a data ID; data IDs come from root lists and
+// must not escape the directory
+func (s *FileSource) Path(binding *DataIdConfig, dataId string) (string, error) {
+	if err := checkDataId(dataId); err != nil {
+		return "", err
+	}
+	return filepath.Join(s.Dir, binding.Namespace, binding.Group, dataId), nil
+}
+
+// checkDataId rejects data IDs that cannot be used as a file name
+func checkDataId(dataId string) error {
+	if dataId == "" || dataId == "." || strings.Contains(dataId, "..") || strings.ContainsAny(dataId, `/\`) {
+		return fmt.Errorf("invalid data id %q", dataId)
+	}
+	return nil
+}
+
+func (s *FileSource) GetConfig(binding *DataIdConfig, dataId string) (string, error) {
+	path, err := s.Path(binding, dataId)
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read config file: %w", err)
+	}
+	return string(data), nil
+}
+
+func (s *FileSource) ListenConfig(binding *DataIdConfig, dataId string, onChange func(data string)) error {
+	return s.watcher.listen(binding, dataId, onChange)
+}
+
+func (s *FileSource) CancelListenConfig(binding *DataIdConfig, dataId string) error {
+	return s.watcher.cancel(binding, dataId)
+}
+
+// InitFileProvider loads configuration from a local directory and polls it for changes
+func InitFileProvider(callback ConfigChangeCallback, providerConfig *ProviderConfig, nacosConfig *NacosConfig) error {
+	if providerConfig.Dir == "" {
+		return errors.New("file config provider requires a dir")
+	}
+	if _, err := os.Stat(providerConfig.Dir); err != nil {
+		return fmt.Errorf("config dir %s: %w", providerConfig.Dir, err)
+	}
+	source := NewFileSource(providerConfig.Dir, providerConfig.Interval)
+	return InitConfigProvider(NewConfigProvider(PROVIDER_FILE, source), callback, nacosConfig)
+}
+
+// Save writes the content of a data ID, creating directories as needed
+func (s *FileSource) Save(binding *DataIdConfig, dataId string, content string) error {
+	path, err := s.Path(binding, dataId)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(content), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s *FileSource) PublishConfig(binding *DataIdConfig, dataId string, content string) error {
+	return s.Save(binding, dataId, content)
+}
+
+func (s *FileSource) DeleteConfig(binding *DataIdConfig, dataId string) error {
+	return s.Delete(binding, dataId)
+}
+
+func (s *FileSource) Delete(binding *DataIdConfig, dataId string) error {
+	path, err := s.Path(binding, dataId)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (s *FileSource) Exists(binding *DataIdConfig, dataId string) bool {
+	path, err := s.Path(binding, dataId)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(path)
+	return err == nil
+}
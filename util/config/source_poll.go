@@ -0,0 +1,102 @@
+package config
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/puzpuzpuz/xsync/v4"
+)
+
+type watchEntry struct {
+	binding  *DataIdConfig
+	dataId   string
+	md5      string
+	onChange func(data string)
+}
+
+// pollWatcher implements ListenConfig for sources without change
+// notifications by periodically comparing the MD5 of every watched data ID
+type pollWatcher struct {
+	get      func(binding *DataIdConfig, dataId string) (string, error)
+	interval time.Duration
+	watches  *xsync.Map[string, *watchEntry]
+	mu       sync.Mutex // guards the md5 of entries and their replacement
+	once     sync.Once
+}
+
+func newPollWatcher(interval int, get func(binding *DataIdConfig, dataId string) (string, error)) *pollWatcher {
+	if interval <= 0 {
+		interval = 5
+	}
+	return &pollWatcher{
+		get:      get,
+		interval: time.Duration(interval) * time.Second,
+		watches:  xsync.NewMap[string, *watchEntry](),
+	}
+}
+
+func watchKey(binding *DataIdConfig, dataId string) string {
+	return binding.Namespace + "/" + binding.Group + "/" + dataId
+}
+
+func contentMD5(content string) string {
+	sum := md5.Sum([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func (w *pollWatcher) listen(binding *DataIdConfig, dataId string, onChange func(data string)) error {
+	content, err := w.get(binding, dataId)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	w.watches.Store(watchKey(binding, dataId), &watchEntry{
+		binding:  binding,
+		dataId:   dataId,
+		md5:      contentMD5(content),
+		onChange: onChange,
+	})
+	w.mu.Unlock()
+	w.once.Do(func() {
+		go w.run()
+	})
+	return nil
+}
+
+func (w *pollWatcher) cancel(binding *DataIdConfig, dataId string) error {
+	w.mu.Lock()
+	w.watches.Delete(watchKey(binding, dataId))
+	w.mu.Unlock()
+	return nil
+}
+
+func (w *pollWatcher) run() {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		w.watches.Range(func(key string, entry *watchEntry) bool {
+			content, err := w.get(entry.binding, entry.dataId)
+			if err != nil {
+				log.Printf("Failed to poll config %s: %v", key, err)
+				return true
+			}
+			// 读取期间条目可能已被取消或替换，此时忽略；回调在锁外执行，
+			// 因为它可能再次订阅（如根列表新增子 data ID）
+			sum := contentMD5(content)
+			w.mu.Lock()
+			current, ok := w.watches.Load(key)
+			changed := ok && current == entry && sum != entry.md5
+			if changed {
+				entry.md5 = sum
+			}
+			w.mu.Unlock()
+			if changed {
+				entry.onChange(content)
+			}
+			return true
+		})
+	}
+}
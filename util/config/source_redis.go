@@ -0,0 +1,67 @@
+package config
+
+import (
+	"errors"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const REDIS_CONFIG_PREFIX = "CONFIG:"
+
+// HashStore is the part of util.KVStore the Redis source needs (util.KVStore
+// cannot be imported here)
+type HashStore interface {
+	GetHValue(group string, key string) (string, error)
+	SetHValue(group string, key string, value string) error
+	HDel(group string, key string) error
+}
+
+// RedisSource reads configuration from Redis hashes named
+// CONFIG:<namespace>/<group>, with one field per data ID
+type RedisSource struct {
+	Client  HashStore
+	watcher *pollWatcher
+}
+
+func NewRedisSource(client HashStore, interval int) *RedisSource {
+	source := &RedisSource{Client: client}
+	source.watcher = newPollWatcher(interval, source.GetConfig)
+	return source
+}
+
+func (s *RedisSource) Key(binding *DataIdConfig) string {
+	return REDIS_CONFIG_PREFIX + binding.Namespace + "/" + binding.Group
+}
+
+func (s *RedisSource) GetConfig(binding *DataIdConfig, dataId string) (string, error) {
+	content, err := s.Client.GetHValue(s.Key(binding), dataId)
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	return content, err
+}
+
+func (s *RedisSource) PublishConfig(binding *DataIdConfig, dataId string, content string) error {
+	return s.Client.SetHValue(s.Key(binding), dataId, content)
+}
+
+func (s *RedisSource) DeleteConfig(binding *DataIdConfig, dataId string) error {
+	return s.Client.HDel(s.Key(binding), dataId)
+}
+
+func (s *RedisSource) ListenConfig(binding *DataIdConfig, dataId string, onChange func(data string)) error {
+	return s.watcher.listen(binding, dataId, onChange)
+}
+
+func (s *RedisSource) CancelListenConfig(binding *DataIdConfig, dataId string) error {
+	return s.watcher.cancel(binding, dataId)
+}
+
+// InitRedisProvider loads configuration from Redis and polls it for changes
+func InitRedisProvider(callback ConfigChangeCallback, client HashStore, providerConfig *ProviderConfig, nacosConfig *NacosConfig) error {
+	if client == nil {
+		return errors.New("redis config provider requires a redis client")
+	}
+	source := NewRedisSource(client, providerConfig.Interval)
+	return InitConfigProvider(NewConfigProvider(PROVIDER_REDIS, source), callback, nacosConfig)
+}
@@ -1,6 +1,9 @@
 package config
 
-import "strings"
+import (
+	"sort"
+	"strings"
+)
 
 // propertyString is a string in format "key1=val1,key2=val2,..."
 func ConstructPropertyMap(propertyString string) map[string]string {
@@ -42,3 +45,12 @@ func GetPropertyValue(propertyString string, key string, defaultValue string) st
 	}
 	return defaultValue
 }
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,212 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/puzpuzpuz/xsync/v4"
+)
+
+const (
+	SEVERITY_ERROR   = "error"
+	SEVERITY_WARNING = "warning"
+)
+
+// ValidationIssue is a problem found in the content of a data ID
+type ValidationIssue struct {
+	DataId   string `json:"dataId"`
+	Row      int    `json:"row,omitempty"` // CSV line, or 1-based item of a JSON list
+	Field    string `json:"field,omitempty"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// ValidationReport is the result of validating one update of a data ID.
+// Updates with errors are rejected and the previous version is kept.
+type ValidationReport struct {
+	DataId   string            `json:"dataId"`
+	Handler  string            `json:"handler"`
+	Time     time.Time         `json:"time"`
+	Accepted bool              `json:"accepted"`
+	Issues   []ValidationIssue `json:"issues"`
+}
+
+// VALIDATION_REPORTS holds the latest report of every data ID
+var VALIDATION_REPORTS = xsync.NewMap[string, *ValidationReport]()
+
+func NewValidationReport(dataId string) *ValidationReport {
+	return &ValidationReport{
+		DataId:   dataId,
+		Time:     time.Now(),
+		Accepted: true,
+		Issues:   make([]ValidationIssue, 0),
+	}
+}
+
+func (r *ValidationReport) Error(row int, field, format string, args ...interface{}) {
+	r.add(row, field, SEVERITY_ERROR, fmt.Sprintf(format, args...))
+}
+
+func (r *ValidationReport) Warning(row int, field, format string, args ...interface{}) {
+	r.add(row, field, SEVERITY_WARNING, fmt.Sprintf(format, args...))
+}
+
+func (r *ValidationReport) add(row int, field, severity, message string) {
+	r.Issues = append(r.Issues, ValidationIssue{
+		DataId:   r.DataId,
+		Row:      row,
+		Field:    field,
+		Severity: severity,
+		Message:  message,
+	})
+}
+
+func (r *ValidationReport) HasErrors() bool {
+	for _, issue := range r.Issues {
+		if issue.Severity == SEVERITY_ERROR {
+			return true
+		}
+	}
+	return false
+}
+
+// Err summarizes the errors of the report, nil if there are none
+func (r *ValidationReport) Err() error {
+	var messages []string
+	for _, issue := range r.Issues {
+		if issue.Severity != SEVERITY_ERROR {
+			continue
+		}
+		message := issue.Message
+		if issue.Field != "" {
+			message = issue.Field + ": " + message
+		}
+		if issue.Row > 0 {
+			message = fmt.Sprintf("row %d: %s", issue.Row, message)
+		}
+		messages = append(messages, message)
+	}
+	if len(messages) == 0 {
+		return nil
+	}
+	if len(messages) > 5 {
+		messages = append(messages[:5], fmt.Sprintf("and %d more", len(messages)-5))
+	}
+	return errors.New(r.DataId + ": " + strings.Join(messages, "; "))
+}
+
+func storeValidationReport(report *ValidationReport) {
+	VALIDATION_REPORTS.Store(report.DataId, report)
+}
+
+// GetValidationReports returns the latest report of every data ID
+func GetValidationReports() []*ValidationReport {
+	reports := make([]*ValidationReport, 0)
+	VALIDATION_REPORTS.Range(func(_ string, report *ValidationReport) bool {
+		reports = append(reports, report)
+		return true
+	})
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].DataId < reports[j].DataId
+	})
+	return reports
+}
+
+func GetValidationReport(dataId string) (*ValidationReport, bool) {
+	return VALIDATION_REPORTS.Load(dataId)
+}
+
+// validateDeviceConfig checks a device list: names must be set and unique
+// within the list, types should exist. A device also defined in another data
+// ID is only a warning, that is how a device is moved between data IDs.
+func validateDeviceConfig(dataId, data string) *ValidationReport {
+	report := NewValidationReport(dataId)
+	configs, err := DEVICES_CONFIG.parseDeviceConfigs(dataId, data)
+	if err != nil {
+		report.Error(0, "", "%v", err)
+		return report
+	}
+
+	names := make(map[string]int)
+	for i, cfg := range *configs {
+		row := i + 1
+		if cfg == nil || strings.TrimSpace(cfg.Name) == "" {
+			report.Error(row, "name", "device has no name")
+			continue
+		}
+		if previous, exists := names[cfg.Name]; exists {
+			report.Error(row, "name", "duplicate device %s, also defined in item %d", cfg.Name, previous)
+			continue
+		}
+		names[cfg.Name] = row
+		if owner := findDeviceDataId(cfg.Name); owner != "" && owner != dataId {
+			report.Warning(row, "name", "device %s is also defined in %s, it moves here and should be removed there", cfg.Name, owner)
+		}
+		if cfg.Type == "" {
+			report.Error(row, "type", "device %s has no type", cfg.Name)
+		} else if GetDeviceTypeConfig(cfg.Type) == nil {
+			report.Warning(row, "type", "unknown device type %s, device %s is ignored", cfg.Type, cfg.Name)
+		}
+		if cfg.Port < 0 || cfg.Port > 65535 {
+			report.Error(row, "port", "invalid port %d", cfg.Port)
+		}
+		if cfg.SlaveID < 0 || cfg.SlaveID > 247 {
+			report.Warning(row, "slave_id", "slave id %d out of range 0-247", cfg.SlaveID)
+		}
+		if cfg.Interval < 0 {
+			report.Error(row, "interval", "negative interval %d", cfg.Interval)
+		}
+	}
+	return report
+}
+
+// validateDeviceTypeConfig checks the device types and warns about types
+// removed while devices still use them
+func validateDeviceTypeConfig(dataId, data string) *ValidationReport {
+	report := NewValidationReport(dataId)
+	cfg, err := DEVICE_TYPES_CONFIG.parseDeviceTypeConfig(dataId, data)
+	if err != nil {
+		report.Error(0, "", "%v", err)
+		return report
+	}
+
+	for _, name := range sortedKeys(cfg.DeviceTypes) {
+		typeConfig := cfg.DeviceTypes[name]
+		if typeConfig == nil {
+			report.Error(0, name, "device type %s is empty", name)
+			continue
+		}
+		if typeConfig.Interval < 0 {
+			report.Error(0, name+".interval", "negative interval %d", typeConfig.Interval)
+		}
+		if typeConfig.Timeout < 0 {
+			report.Error(0, name+".timeout", "negative timeout %d", typeConfig.Timeout)
+		}
+		if typeConfig.Retries < 0 {
+			report.Error(0, name+".retries", "negative retries %d", typeConfig.Retries)
+		}
+	}
+	for _, device := range GetAllDeviceConfig() {
+		if _, ok := cfg.DeviceTypes[device.Type]; !ok {
+			report.Warning(0, "device_types", "device type %s used by %s is not defined", device.Type, device.Name)
+		}
+	}
+	return report
+}
+
+// validateDictionaryConfig reports skipped rows of a dictionary as warnings
+func validateDictionaryConfig(dataId, data string) *ValidationReport {
+	report := NewValidationReport(dataId)
+	table, err := ParseDictionary(dataId, data)
+	if err != nil {
+		report.Error(1, "", "%v", err)
+		return report
+	}
+	for _, rowErr := range table.Errors {
+		report.Warning(rowErr.Row, "", "row skipped: %s", rowErr.Message)
+	}
+	return report
+}
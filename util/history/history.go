@@ -0,0 +1,235 @@
+package history
+
+/**
+ * 设备数据历史存储:
+ * 1. Initialize() 根据配置选择 Redis（有序集合）或 MySQL 后端
+ * 2. Record() 写入一组寄存器数值
+ * 3. Query() 查询原始数据（超出 maxPoints 时截断并标记），或由后端按时间桶聚合 min/max/avg
+ */
+
+import (
+	"fmt"
+	"log"
+	"main/config"
+	"main/util/strings"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Point is a single recorded value
+type Point struct {
+	Time  int64   `json:"t"` // unix milliseconds
+	Value float64 `json:"v"`
+}
+
+// Bucket is an aggregated time bucket
+type Bucket struct {
+	Time  int64   `json:"t"` // bucket start, unix milliseconds
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Avg   float64 `json:"avg"`
+	Count int     `json:"count"`
+}
+
+// Result is the answer of a history query: raw Points, or Buckets when a
+// step is given. Truncated is set when more than History.MaxPoints raw
+// points matched and only the first ones are returned.
+type Result struct {
+	Points    []Point
+	Buckets   []Bucket
+	Truncated bool
+}
+
+// Data returns the points or buckets of the result
+func (r *Result) Data() interface{} {
+	if r.Buckets != nil {
+		return r.Buckets
+	}
+	return r.Points
+}
+
+// Store is the history storage backend. A value recorded again for the same
+// timestamp replaces the previous one.
+type Store interface {
+	Record(device string, ts time.Time, values map[string]float64) error
+	// Query returns at most limit (0 = all) points in time order
+	Query(device, key string, from, to time.Time, limit int) ([]Point, error)
+	// Aggregate buckets the points of [from, to) without loading them all
+	Aggregate(device, key string, from, to time.Time, step time.Duration) ([]Bucket, error)
+	Trim(before time.Time) error
+}
+
+var HISTORY_STORE Store
+
+// Initialize creates the history store selected by configuration and starts
+// the retention worker
+func Initialize(cfg *config.HistoryConfig) error {
+	var store Store
+	var err error
+	backend := cfg.Backend
+	switch backend {
+	case config.HISTORY_BACKEND_MYSQL:
+		store, err = NewMySQLStore(cfg.Database, cfg.Table)
+	case config.HISTORY_BACKEND_REDIS, "":
+		backend = config.HISTORY_BACKEND_REDIS
+		store, err = NewRedisStore()
+	default:
+		err = fmt.Errorf("unknown history backend %s", cfg.Backend)
+	}
+	if err != nil {
+		return err
+	}
+
+	HISTORY_STORE = store
+	if cfg.RetentionVal > 0 {
+		go retain(store, cfg.RetentionVal)
+	}
+	log.Printf("History store initialized with %s backend", backend)
+	return nil
+}
+
+// 定期清理超出保留期的数据
+func retain(store Store, retention time.Duration) {
+	interval := retention / 24
+	if interval < time.Minute {
+		interval = time.Minute
+	}
+	if interval > time.Hour {
+		interval = time.Hour
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := store.Trim(time.Now().Add(-retention)); err != nil {
+			log.Printf("Failed to trim history: %v", err)
+		}
+	}
+}
+
+// Record stores the numeric values of a device, ignoring non-numeric ones
+func Record(device string, ts time.Time, values map[string]interface{}) error {
+	if HISTORY_STORE == nil {
+		return fmt.Errorf("history store is not initialized")
+	}
+	numeric := make(map[string]float64, len(values))
+	for key, value := range values {
+		if v, ok := strings.ToFloat(value); ok {
+			numeric[key] = v
+		}
+	}
+	if len(numeric) == 0 {
+		return nil
+	}
+	return HISTORY_STORE.Record(device, ts, numeric)
+}
+
+// Query returns raw points, or aggregated buckets when step is positive
+func Query(device, key string, from, to time.Time, step time.Duration) (*Result, error) {
+	if HISTORY_STORE == nil {
+		return nil, fmt.Errorf("history store is not initialized")
+	}
+	if key == "" {
+		return nil, fmt.Errorf("history query requires a key")
+	}
+	if !from.Before(to) {
+		return nil, fmt.Errorf("invalid time range")
+	}
+
+	if step > 0 {
+		buckets, err := HISTORY_STORE.Aggregate(device, key, from, to, step)
+		if err != nil {
+			return nil, err
+		}
+		return &Result{Buckets: buckets}, nil
+	}
+
+	// 多取一个点，用于判断结果是否被截断
+	limit := config.CONFIG.History.MaxPoints
+	queryLimit := 0
+	if limit > 0 {
+		queryLimit = limit + 1
+	}
+	points, err := HISTORY_STORE.Query(device, key, from, to, queryLimit)
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{Points: points}
+	if limit > 0 && len(points) > limit {
+		result.Points, result.Truncated = points[:limit], true
+	}
+	return result, nil
+}
+
+// Downsample aggregates sorted points into buckets of the given step
+func Downsample(points []Point, from time.Time, step time.Duration) []Bucket {
+	agg := newAggregator(from, step)
+	for _, p := range points {
+		agg.add(p)
+	}
+	return agg.result()
+}
+
+// aggregator builds buckets from points added in time order, keeping only
+// the current bucket open
+type aggregator struct {
+	origin  int64
+	step    int64
+	buckets []Bucket
+	current *Bucket
+	sum     float64
+}
+
+func newAggregator(from time.Time, step time.Duration) *aggregator {
+	return &aggregator{origin: from.UnixMilli(), step: step.Milliseconds(), buckets: make([]Bucket, 0)}
+}
+
+func (a *aggregator) add(p Point) {
+	if a.step <= 0 {
+		return
+	}
+	start := a.origin + (p.Time-a.origin)/a.step*a.step
+	if a.current == nil || a.current.Time != start {
+		a.flush()
+		a.current = &Bucket{Time: start, Min: math.Inf(1), Max: math.Inf(-1)}
+	}
+	a.current.Min = math.Min(a.current.Min, p.Value)
+	a.current.Max = math.Max(a.current.Max, p.Value)
+	a.current.Count++
+	a.sum += p.Value
+}
+
+func (a *aggregator) flush() {
+	if a.current != nil {
+		a.current.Avg = a.sum / float64(a.current.Count)
+		a.buckets = append(a.buckets, *a.current)
+		a.current, a.sum = nil, 0
+	}
+}
+
+func (a *aggregator) result() []Bucket {
+	a.flush()
+	return a.buckets
+}
+
+// ParseTime parses unix milliseconds or RFC3339 text, returning def when empty
+func ParseTime(value string, def time.Time) (time.Time, error) {
+	if value == "" {
+		return def, nil
+	}
+	if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.UnixMilli(ms), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// ParseStep parses a duration such as "5m" or a number of seconds
+func ParseStep(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	return time.ParseDuration(value)
+}
@@ -0,0 +1,140 @@
+package history
+
+import (
+	"fmt"
+	"main/util/mysql"
+	"main/util/strings"
+	gstrings "strings"
+	"sync"
+	"time"
+)
+
+// MySQLStore keeps history rows in a single table of a named database
+type MySQLStore struct {
+	Database string
+	Table    string
+	ready    bool
+	mu       sync.Mutex
+}
+
+func NewMySQLStore(database, table string) (*MySQLStore, error) {
+	if table == "" {
+		table = "device_history"
+	}
+	return &MySQLStore{Database: database, Table: table}, nil
+}
+
+// 延迟获取客户端并建表，MySQL 可能晚于历史存储初始化
+func (s *MySQLStore) client() (*mysql.MySQLClient, error) {
+	client := mysql.GetClient(s.Database)
+	if client == nil {
+		return nil, fmt.Errorf("MySQL client '%s' is not initialized", s.Database)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.ready {
+		_, err := client.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+			device VARCHAR(128) NOT NULL,
+			reg_key VARCHAR(128) NOT NULL,
+			ts BIGINT NOT NULL,
+			value DOUBLE NOT NULL,
+			PRIMARY KEY (device, reg_key, ts),
+			KEY idx_ts (ts)
+		)`, s.Table))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create history table %s: %w", s.Table, err)
+		}
+		s.ready = true
+	}
+	return client, nil
+}
+
+func (s *MySQLStore) Record(device string, ts time.Time, values map[string]float64) error {
+	client, err := s.client()
+	if err != nil {
+		return err
+	}
+
+	placeholders := make([]string, 0, len(values))
+	args := make([]interface{}, 0, len(values)*4)
+	for key, value := range values {
+		placeholders = append(placeholders, "(?, ?, ?, ?)")
+		args = append(args, device, key, ts.UnixMilli(), value)
+	}
+	query := fmt.Sprintf("INSERT INTO %s (device, reg_key, ts, value) VALUES %s ON DUPLICATE KEY UPDATE value = VALUES(value)",
+		s.Table, gstrings.Join(placeholders, ", "))
+	_, err = client.Exec(query, args...)
+	return err
+}
+
+func (s *MySQLStore) Query(device, key string, from, to time.Time, limit int) ([]Point, error) {
+	client, err := s.client()
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf("SELECT ts, value FROM %s WHERE device = ? AND reg_key = ? AND ts >= ? AND ts < ? ORDER BY ts", s.Table)
+	args := []interface{}{device, key, from.UnixMilli(), to.UnixMilli()}
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+	rows, err := client.QueryToMap(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	points := make([]Point, 0, len(rows))
+	for _, row := range rows {
+		t, _ := strings.ToFloat(row["ts"])
+		v, _ := strings.ToFloat(row["value"])
+		points = append(points, Point{Time: int64(t), Value: v})
+	}
+	return points, nil
+}
+
+// Aggregate groups the rows into buckets in MySQL
+func (s *MySQLStore) Aggregate(device, key string, from, to time.Time, step time.Duration) ([]Bucket, error) {
+	client, err := s.client()
+	if err != nil {
+		return nil, err
+	}
+
+	origin, stepMs := from.UnixMilli(), step.Milliseconds()
+	if stepMs <= 0 {
+		return make([]Bucket, 0), nil
+	}
+	query := fmt.Sprintf(`SELECT (ts - ?) DIV ? AS bucket, MIN(value) AS min_value, MAX(value) AS max_value, AVG(value) AS avg_value, COUNT(*) AS cnt
+		FROM %s WHERE device = ? AND reg_key = ? AND ts >= ? AND ts < ? GROUP BY bucket ORDER BY bucket`, s.Table)
+	rows, err := client.QueryToMap(query, origin, stepMs, device, key, origin, to.UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+
+	buckets := make([]Bucket, 0, len(rows))
+	for _, row := range rows {
+		bucket, _ := strings.ToFloat(row["bucket"])
+		minValue, _ := strings.ToFloat(row["min_value"])
+		maxValue, _ := strings.ToFloat(row["max_value"])
+		avgValue, _ := strings.ToFloat(row["avg_value"])
+		count, _ := strings.ToFloat(row["cnt"])
+		buckets = append(buckets, Bucket{
+			Time:  origin + int64(bucket)*stepMs,
+			Min:   minValue,
+			Max:   maxValue,
+			Avg:   avgValue,
+			Count: int(count),
+		})
+	}
+	return buckets, nil
+}
+
+func (s *MySQLStore) Trim(before time.Time) error {
+	client, err := s.client()
+	if err != nil {
+		return err
+	}
+	_, err = client.Exec(fmt.Sprintf("DELETE FROM %s WHERE ts < ?", s.Table), before.UnixMilli())
+	return err
+}
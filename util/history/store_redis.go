@@ -0,0 +1,129 @@
+package history
+
+import (
+	"errors"
+	"main/util"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	REDIS_HISTORY_PREFIX = "HISTORY:"
+	REDIS_HISTORY_KEYS   = "HISTORY_KEYS"
+	// REDIS_HISTORY_PAGE is the number of members read per round trip when aggregating
+	REDIS_HISTORY_PAGE = 5000
+)
+
+// RedisStore keeps one sorted set per device register, scored by timestamp
+type RedisStore struct {
+	Redis util.KVStore
+}
+
+func NewRedisStore() (*RedisStore, error) {
+	if util.RedisData == nil {
+		return nil, errors.New("redis client not initialized")
+	}
+	return &RedisStore{Redis: util.RedisData}, nil
+}
+
+func historyKey(device, key string) string {
+	return REDIS_HISTORY_PREFIX + device + ":" + key
+}
+
+func (s *RedisStore) Record(device string, ts time.Time, values map[string]float64) error {
+	score := ts.UnixMilli()
+	scoreText := strconv.FormatInt(score, 10)
+	commands := make([][]interface{}, 0, len(values)*3)
+	for key, value := range values {
+		name := historyKey(device, key)
+		// 每个时间戳只保留一个值：先删除同一时间戳的旧成员，与 MySQL 的 upsert 一致；
+		// 成员包含时间戳，保证相同数值不会被去重
+		member := scoreText + ":" + strconv.FormatFloat(value, 'f', -1, 64)
+		commands = append(commands,
+			[]interface{}{"ZREMRANGEBYSCORE", name, scoreText, scoreText},
+			[]interface{}{"ZADD", name, score, member},
+			[]interface{}{"SADD", REDIS_HISTORY_KEYS, name})
+	}
+	results, err := s.Redis.ExecBatch(commands, true)
+	if err != nil {
+		return err
+	}
+	for _, result := range results {
+		if err, ok := result.(error); ok {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *RedisStore) Query(device, key string, from, to time.Time, limit int) ([]Point, error) {
+	min := strconv.FormatInt(from.UnixMilli(), 10)
+	max := "(" + strconv.FormatInt(to.UnixMilli(), 10)
+	members, err := s.Redis.ZRangeByScore(historyKey(device, key), min, max, 0, int64(limit))
+	if err != nil {
+		return nil, err
+	}
+
+	points := make([]Point, 0, len(members))
+	for _, member := range members {
+		if p, ok := parseMember(member.Member); ok {
+			points = append(points, p)
+		}
+	}
+	return points, nil
+}
+
+// Aggregate reads the sorted set page by page, so only one page and the
+// open bucket are held in memory
+func (s *RedisStore) Aggregate(device, key string, from, to time.Time, step time.Duration) ([]Bucket, error) {
+	agg := newAggregator(from, step)
+	name := historyKey(device, key)
+	min := strconv.FormatInt(from.UnixMilli(), 10)
+	max := "(" + strconv.FormatInt(to.UnixMilli(), 10)
+	for {
+		members, err := s.Redis.ZRangeByScore(name, min, max, 0, REDIS_HISTORY_PAGE)
+		if err != nil {
+			return nil, err
+		}
+		for _, member := range members {
+			if p, ok := parseMember(member.Member); ok {
+				agg.add(p)
+			}
+		}
+		if len(members) < REDIS_HISTORY_PAGE {
+			break
+		}
+		// 每个时间戳只有一个成员，下一页从最后一个分数之后开始
+		min = "(" + strconv.FormatInt(int64(members[len(members)-1].Score), 10)
+	}
+	return agg.result(), nil
+}
+
+// parseMember parses a "<ts>:<value>" member
+func parseMember(member string) (Point, bool) {
+	parts := strings.SplitN(member, ":", 2)
+	if len(parts) != 2 {
+		return Point{}, false
+	}
+	t, err1 := strconv.ParseInt(parts[0], 10, 64)
+	v, err2 := strconv.ParseFloat(parts[1], 64)
+	if err1 != nil || err2 != nil {
+		return Point{}, false
+	}
+	return Point{Time: t, Value: v}, true
+}
+
+func (s *RedisStore) Trim(before time.Time) error {
+	names, err := s.Redis.SMembers(REDIS_HISTORY_KEYS)
+	if err != nil {
+		return err
+	}
+	max := "(" + strconv.FormatInt(before.UnixMilli(), 10)
+	for _, name := range names {
+		if _, err := s.Redis.ZRemRangeByScore(name, "-inf", max); err != nil {
+			return err
+		}
+	}
+	return nil
+}
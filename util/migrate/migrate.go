@@ -0,0 +1,465 @@
+package migrate
+
+/**
+ * 数据库结构迁移:
+ * 1. Initialize() 根据配置创建 MIGRATOR，迁移单元来自目录（.sql/.js）和脚本库（meta 中声明 migration）
+ * 2. Status() 对比迁移单元与各库的跟踪表（applied / pending / modified / missing）
+ * 3. Up() / Rollback() 在 GET_LOCK 咨询锁内执行，多节点同时启动时只有一个节点迁移
+ * 4. dryRun 只返回将要执行的语句，不修改数据库
+ */
+
+import (
+	"fmt"
+	"log"
+	"main/config"
+	"main/util/mysql"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	DIRECTION_UP   = "up"
+	DIRECTION_DOWN = "down"
+
+	STATE_APPLIED  = "applied"
+	STATE_PENDING  = "pending"
+	STATE_MODIFIED = "modified" // applied, but the unit changed since
+	STATE_MISSING  = "missing"  // applied, but the unit no longer exists
+)
+
+var tableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ScriptSource lists the migrations kept in the script store
+type ScriptSource func() ([]*Unit, error)
+
+// JSRunner runs the up or down function of a JS migration
+type JSRunner func(unit *Unit, direction string) error
+
+// UnitStatus is the state of one migration in its database
+type UnitStatus struct {
+	Database  string     `json:"database"`
+	Version   string     `json:"version"`
+	Name      string     `json:"name"`
+	Kind      string     `json:"kind,omitempty"`
+	Source    string     `json:"source"`
+	State     string     `json:"state"`
+	AppliedAt *time.Time `json:"appliedAt,omitempty"`
+}
+
+// Step is one migration run (or planned, with DryRun) by Up or Rollback
+type Step struct {
+	Database   string   `json:"database"`
+	Version    string   `json:"version"`
+	Name       string   `json:"name"`
+	Kind       string   `json:"kind"`
+	Direction  string   `json:"direction"`
+	Statements []string `json:"statements,omitempty"` // SQL units only
+	DryRun     bool     `json:"dryRun,omitempty"`
+	Duration   int64    `json:"durationMs"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// record is a row of the tracking table
+type record struct {
+	Version   string
+	Name      string
+	Source    string
+	Checksum  string
+	AppliedAt *time.Time
+}
+
+// Migrator applies and rolls back the migrations of all named databases
+type Migrator struct {
+	Dir              string
+	Table            string
+	LockTimeout      time.Duration
+	StatementTimeout time.Duration // 0 = none
+	Scripts          ScriptSource
+	RunJS            JSRunner
+}
+
+var MIGRATOR *Migrator
+
+// Initialize creates MIGRATOR from the migration configuration
+func Initialize(cfg *config.MigrationConfig, scripts ScriptSource, runJS JSRunner) error {
+	migrator, err := NewMigrator(cfg, scripts, runJS)
+	if err != nil {
+		return err
+	}
+	MIGRATOR = migrator
+	return nil
+}
+
+func NewMigrator(cfg *config.MigrationConfig, scripts ScriptSource, runJS JSRunner) (*Migrator, error) {
+	table := cfg.Table
+	if table == "" {
+		table = "schema_migrations"
+	}
+	if !tableName.MatchString(table) {
+		return nil, fmt.Errorf("invalid migration table name %q", table)
+	}
+	lockTimeout := time.Duration(cfg.LockTimeout) * time.Second
+	if lockTimeout <= 0 {
+		lockTimeout = 60 * time.Second
+	}
+	return &Migrator{
+		Dir:              cfg.Dir,
+		Table:            table,
+		LockTimeout:      lockTimeout,
+		StatementTimeout: time.Duration(cfg.StatementTimeout) * time.Second,
+		Scripts:          scripts,
+		RunJS:            runJS,
+	}, nil
+}
+
+// Load returns the migrations of every database, ordered by version
+func (m *Migrator) Load() (map[string][]*Unit, error) {
+	var units []*Unit
+	if m.Dir != "" {
+		files, err := loadDir(m.Dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration directory %s: %w", m.Dir, err)
+		}
+		units = append(units, files...)
+	}
+	if m.Scripts != nil {
+		scripts, err := m.Scripts()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list migration scripts: %w", err)
+		}
+		units = append(units, scripts...)
+	}
+
+	result := make(map[string][]*Unit)
+	for _, unit := range units {
+		result[unit.Database] = append(result[unit.Database], unit)
+	}
+	for _, list := range result {
+		if err := sortUnits(list); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// databases returns db, or every database that has migrations when db is ""
+func databases(units map[string][]*Unit, db string) []string {
+	if db != "" {
+		return []string{db}
+	}
+	names := make([]string, 0, len(units))
+	for name := range units {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Status compares the migrations with the tracking table of db ("" = all)
+func (m *Migrator) Status(db string) ([]UnitStatus, error) {
+	units, err := m.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]UnitStatus, 0)
+	for _, name := range databases(units, db) {
+		client, err := primary(name)
+		if err != nil {
+			return nil, err
+		}
+		records, err := m.applied(client)
+		if err != nil {
+			return nil, fmt.Errorf("database %s: %w", name, err)
+		}
+
+		for _, unit := range units[name] {
+			status := UnitStatus{
+				Database: name,
+				Version:  unit.Version,
+				Name:     unit.Name,
+				Kind:     unit.Kind,
+				Source:   unit.Source,
+				State:    STATE_PENDING,
+			}
+			if r, ok := records[versionKey(unit.Version)]; ok {
+				status.State = STATE_APPLIED
+				status.AppliedAt = r.AppliedAt
+				if r.Checksum != unit.Checksum {
+					status.State = STATE_MODIFIED
+				}
+				delete(records, versionKey(unit.Version))
+			}
+			result = append(result, status)
+		}
+		for _, r := range sortedRecords(records) {
+			result = append(result, UnitStatus{
+				Database:  name,
+				Version:   r.Version,
+				Name:      r.Name,
+				Source:    r.Source,
+				State:     STATE_MISSING,
+				AppliedAt: r.AppliedAt,
+			})
+		}
+	}
+	return result, nil
+}
+
+// Up applies the pending migrations of db ("" = all) up to and including
+// version to ("" = all). Steps run so far are returned along with an error.
+func (m *Migrator) Up(db, to string, dryRun bool) ([]Step, error) {
+	units, err := m.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	steps := make([]Step, 0)
+	for _, name := range databases(units, db) {
+		err := m.withLock(name, dryRun, func(client *mysql.MySQLClient, conn *mysql.LockedConn) error {
+			records, err := m.applied(client)
+			if err != nil {
+				return err
+			}
+			for _, unit := range units[name] {
+				if _, ok := records[versionKey(unit.Version)]; ok {
+					continue
+				}
+				if to != "" && compareVersions(unit.Version, to) > 0 {
+					break
+				}
+				step, err := m.run(conn, unit, DIRECTION_UP, dryRun)
+				steps = append(steps, step)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return steps, fmt.Errorf("database %s: %w", name, err)
+		}
+	}
+	return steps, nil
+}
+
+// Rollback reverts the last count applied migrations of db ("" = all), or
+// every migration after version to when given
+func (m *Migrator) Rollback(db string, count int, to string, dryRun bool) ([]Step, error) {
+	units, err := m.Load()
+	if err != nil {
+		return nil, err
+	}
+	if count <= 0 {
+		count = 1
+	}
+
+	steps := make([]Step, 0)
+	for _, name := range databases(units, db) {
+		byVersion := make(map[string]*Unit, len(units[name]))
+		for _, unit := range units[name] {
+			byVersion[versionKey(unit.Version)] = unit
+		}
+
+		err := m.withLock(name, dryRun, func(client *mysql.MySQLClient, conn *mysql.LockedConn) error {
+			records, err := m.applied(client)
+			if err != nil {
+				return err
+			}
+			applied := sortedRecords(records)
+			for i, done := len(applied)-1, 0; i >= 0; i-- {
+				r := applied[i]
+				if to != "" {
+					if compareVersions(r.Version, to) <= 0 {
+						break
+					}
+				} else if done >= count {
+					break
+				}
+
+				unit, ok := byVersion[versionKey(r.Version)]
+				if !ok {
+					return fmt.Errorf("migration %s (%s) is applied but no longer exists", r.Version, r.Source)
+				}
+				if !unit.Reversible {
+					return fmt.Errorf("migration %s (%s) has no down section", unit.Version, unit.Source)
+				}
+				step, err := m.run(conn, unit, DIRECTION_DOWN, dryRun)
+				steps = append(steps, step)
+				if err != nil {
+					return err
+				}
+				done++
+			}
+			return nil
+		})
+		if err != nil {
+			return steps, fmt.Errorf("database %s: %w", name, err)
+		}
+	}
+	return steps, nil
+}
+
+// withLock runs fn holding the migration lock of the database; migrations
+// run on conn, the connection holding the lock. Dry runs only read and take
+// no lock, conn is nil.
+func (m *Migrator) withLock(name string, dryRun bool, fn func(client *mysql.MySQLClient, conn *mysql.LockedConn) error) error {
+	client, err := primary(name)
+	if err != nil {
+		return err
+	}
+	if dryRun {
+		return fn(client, nil)
+	}
+
+	conn, err := client.LockConn(m.Table, m.LockTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer conn.Unlock()
+	if err := m.ensureTable(conn); err != nil {
+		return err
+	}
+	return fn(client, conn)
+}
+
+// run applies or reverts one migration and updates the tracking table
+func (m *Migrator) run(conn *mysql.LockedConn, unit *Unit, direction string, dryRun bool) (Step, error) {
+	step := Step{
+		Database:  unit.Database,
+		Version:   unit.Version,
+		Name:      unit.Name,
+		Kind:      unit.Kind,
+		Direction: direction,
+		DryRun:    dryRun,
+	}
+	if unit.Kind == KIND_SQL {
+		step.Statements = unit.Up
+		if direction == DIRECTION_DOWN {
+			step.Statements = unit.Down
+		}
+	}
+	if dryRun {
+		return step, nil
+	}
+
+	start := time.Now()
+	err := m.execute(conn, unit, direction, step.Statements)
+	step.Duration = time.Since(start).Milliseconds()
+	if err == nil {
+		if direction == DIRECTION_UP {
+			_, err = conn.Exec(m.StatementTimeout, fmt.Sprintf("INSERT INTO `%s` (version, name, source, checksum, applied_at, duration_ms) VALUES (?, ?, ?, ?, ?, ?)", m.Table),
+				unit.Version, unit.Name, unit.Source, unit.Checksum, time.Now(), step.Duration)
+		} else {
+			_, err = conn.Exec(m.StatementTimeout, fmt.Sprintf("DELETE FROM `%s` WHERE version = ?", m.Table), unit.Version)
+		}
+	}
+	if err != nil {
+		step.Error = err.Error()
+		log.Printf("Migration %s %s/%s_%s failed: %v", direction, unit.Database, unit.Version, unit.Name, err)
+		return step, fmt.Errorf("migration %s %s failed: %w", unit.Version, direction, err)
+	}
+	log.Printf("Migration %s %s/%s_%s done in %dms", direction, unit.Database, unit.Version, unit.Name, step.Duration)
+	return step, nil
+}
+
+// execute runs the statements of a SQL unit one by one, or the JS function.
+// MySQL commits DDL implicitly, so a failing unit may be partially applied.
+// Statements run without the database's statement timeout: cancelling a
+// long ALTER TABLE would record the unit as failed while the server may
+// still complete it.
+func (m *Migrator) execute(conn *mysql.LockedConn, unit *Unit, direction string, statements []string) error {
+	if unit.Kind == KIND_JS {
+		if m.RunJS == nil {
+			return fmt.Errorf("JS migrations are not supported")
+		}
+		return m.RunJS(unit, direction)
+	}
+	for i, statement := range statements {
+		if _, err := conn.Exec(m.StatementTimeout, statement); err != nil {
+			return fmt.Errorf("statement %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) ensureTable(conn *mysql.LockedConn) error {
+	_, err := conn.Exec(m.StatementTimeout, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		version VARCHAR(64) NOT NULL,
+		name VARCHAR(255) NOT NULL,
+		source VARCHAR(255) NOT NULL,
+		checksum CHAR(64) NOT NULL,
+		applied_at DATETIME NOT NULL,
+		duration_ms BIGINT NOT NULL,
+		PRIMARY KEY (version)
+	)`, "`"+m.Table+"`"))
+	if err != nil {
+		return fmt.Errorf("failed to create migration table %s: %w", m.Table, err)
+	}
+	return nil
+}
+
+// applied reads the tracking table keyed by versionKey; a database that was
+// never migrated has none
+func (m *Migrator) applied(client *mysql.MySQLClient) (map[string]*record, error) {
+	tables, err := client.QueryToMap("SELECT COUNT(*) AS n FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?", m.Table)
+	if err != nil {
+		return nil, err
+	}
+	records := make(map[string]*record)
+	if len(tables) == 0 || fmt.Sprint(tables[0]["n"]) == "0" {
+		return records, nil
+	}
+
+	rows, err := client.QueryToMap(fmt.Sprintf("SELECT version, name, source, checksum, applied_at FROM `%s`", m.Table))
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		r := &record{
+			Version:  fmt.Sprint(row["version"]),
+			Name:     fmt.Sprint(row["name"]),
+			Source:   fmt.Sprint(row["source"]),
+			Checksum: fmt.Sprint(row["checksum"]),
+		}
+		if t, ok := row["applied_at"].(time.Time); ok {
+			r.AppliedAt = &t
+		}
+		records[versionKey(r.Version)] = r
+	}
+	return records, nil
+}
+
+// primary returns the client of a database without its replicas, so the
+// tracking table is never read stale
+func primary(name string) (*mysql.MySQLClient, error) {
+	client, err := mysql.Client(name)
+	if err != nil {
+		return nil, err
+	}
+	return client.Primary(), nil
+}
+
+func sortedRecords(records map[string]*record) []*record {
+	list := make([]*record, 0, len(records))
+	for _, r := range records {
+		list = append(list, r)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return compareVersions(list[i].Version, list[j].Version) < 0
+	})
+	return list
+}
+
+// versionKey normalizes numeric versions so 0001 and 1 are the same
+func versionKey(version string) string {
+	if isDigits(version) {
+		if trimmed := strings.TrimLeft(version, "0"); trimmed != "" {
+			return trimmed
+		}
+		return "0"
+	}
+	return version
+}
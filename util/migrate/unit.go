@@ -0,0 +1,203 @@
+package migrate
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"main/util/mysql"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	KIND_SQL = "sql"
+	KIND_JS  = "js"
+
+	DEFAULT_DATABASE = "default"
+)
+
+var (
+	unitFileName  = regexp.MustCompile(`^(\d+)[_-]?(.*)\.(sql|js)$`)
+	sectionMarker = regexp.MustCompile(`(?i)^--\s*migrate:(up|down)\s*$`)
+)
+
+// Unit is one migration of a named database. SQL units carry their
+// statements, JS units the script code defining up(db) and down(db).
+type Unit struct {
+	Database   string   `json:"database"`
+	Version    string   `json:"version"`
+	Name       string   `json:"name"`
+	Kind       string   `json:"kind"`
+	Source     string   `json:"source"` // file path or script:<name>
+	Checksum   string   `json:"checksum"`
+	Up         []string `json:"-"`
+	Down       []string `json:"-"`
+	Reversible bool     `json:"reversible"`
+	Code       string   `json:"-"`
+}
+
+// NewScriptUnit creates a JS unit from a script of the script store
+func NewScriptUnit(database, version, name, code string) *Unit {
+	if database == "" {
+		database = DEFAULT_DATABASE
+	}
+	return &Unit{
+		Database:   database,
+		Version:    version,
+		Name:       name,
+		Kind:       KIND_JS,
+		Source:     "script:" + name,
+		Checksum:   checksum(code),
+		Reversible: true,
+		Code:       code,
+	}
+}
+
+// loadDir reads the migration directory: files directly inside belong to
+// the default database, files in a subdirectory to the database it is named
+// after. A missing directory has no migrations.
+func loadDir(dir string) ([]*Unit, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var units []*Unit
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			unit, err := loadFile(filepath.Join(dir, entry.Name()), DEFAULT_DATABASE)
+			if err != nil {
+				return nil, err
+			}
+			if unit != nil {
+				units = append(units, unit)
+			}
+			continue
+		}
+
+		files, err := os.ReadDir(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			if file.IsDir() {
+				continue
+			}
+			unit, err := loadFile(filepath.Join(dir, entry.Name(), file.Name()), entry.Name())
+			if err != nil {
+				return nil, err
+			}
+			if unit != nil {
+				units = append(units, unit)
+			}
+		}
+	}
+	return units, nil
+}
+
+// loadFile reads <version>_<name>.sql or .js; other files are skipped
+func loadFile(path, database string) (*Unit, error) {
+	match := unitFileName.FindStringSubmatch(filepath.Base(path))
+	if match == nil {
+		log.Printf("Migration: skipping %s, expected <version>_<name>.sql or .js", path)
+		return nil, nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	unit := &Unit{
+		Database: database,
+		Version:  match[1],
+		Name:     match[2],
+		Kind:     match[3],
+		Source:   path,
+		Checksum: checksum(string(content)),
+	}
+	if unit.Kind == KIND_JS {
+		unit.Code = string(content)
+		unit.Reversible = true
+	} else {
+		unit.Up, unit.Down, unit.Reversible = parseSQL(string(content))
+	}
+	return unit, nil
+}
+
+// parseSQL splits a SQL migration into its "-- migrate:up" and
+// "-- migrate:down" sections. Statements before any marker belong to up; a
+// file without a down section cannot be rolled back.
+func parseSQL(content string) (up, down []string, reversible bool) {
+	var sections [2]strings.Builder
+	current := 0
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	scanner.Buffer(make([]byte, 64*1024), len(content)+1)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if match := sectionMarker.FindStringSubmatch(strings.TrimSpace(line)); match != nil {
+			current = 0
+			if strings.EqualFold(match[1], "down") {
+				current = 1
+				reversible = true
+			}
+			continue
+		}
+		sections[current].WriteString(line)
+		sections[current].WriteByte('\n')
+	}
+	return mysql.SplitStatements(sections[0].String()), mysql.SplitStatements(sections[1].String()), reversible
+}
+
+func checksum(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// sortUnits orders the units of a database by version and rejects duplicates
+func sortUnits(units []*Unit) error {
+	sort.SliceStable(units, func(i, j int) bool {
+		return compareVersions(units[i].Version, units[j].Version) < 0
+	})
+	for i := 1; i < len(units); i++ {
+		if compareVersions(units[i-1].Version, units[i].Version) == 0 {
+			return fmt.Errorf("duplicate migration version %s in database %s: %s and %s",
+				units[i].Version, units[i].Database, units[i-1].Source, units[i].Source)
+		}
+	}
+	return nil
+}
+
+// compareVersions compares numeric versions by value (so 9 < 10 and 0001 ==
+// 1) and anything else as strings
+func compareVersions(a, b string) int {
+	if isDigits(a) && isDigits(b) {
+		a, b = strings.TrimLeft(a, "0"), strings.TrimLeft(b, "0")
+		if len(a) != len(b) {
+			if len(a) < len(b) {
+				return -1
+			}
+			return 1
+		}
+	}
+	return strings.Compare(a, b)
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -1,6 +1,7 @@
 package mysql
 
 import (
+	"fmt"
 	"main/config"
 	"sync"
 )
@@ -31,3 +32,15 @@ func GetClient(name string) *MySQLClient {
 
 	return MYSQL_CLIENTS[name]
 }
+
+// Client returns the named client ("" or "default" for the default one)
+func Client(name string) (*MySQLClient, error) {
+	client := GetClient(name)
+	if client != nil {
+		return client, nil
+	}
+	if name == "" || name == "default" || len(MYSQL_CLIENTS) == 0 {
+		return nil, fmt.Errorf("MySQL client is not initialized")
+	}
+	return nil, fmt.Errorf("unknown MySQL database %q", name)
+}
@@ -0,0 +1,283 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// MYSQL_MAX_ROWS caps the rows a script query returns unless configured
+// (maxRows) or raised per call
+const MYSQL_MAX_ROWS = 10000
+
+// CURSOR_TIMEOUT closes cursors and each() iterations that run longer
+const CURSOR_TIMEOUT = 5 * time.Minute
+
+// ErrTooManyRows is returned when a query returns more rows than allowed
+var ErrTooManyRows = errors.New("too many rows")
+
+// rowScanner converts the rows of a result set one at a time
+type rowScanner struct {
+	rows       *sql.Rows
+	columns    []string
+	converters []columnConverter
+	values     []interface{}
+	scanArgs   []interface{}
+}
+
+func newRowScanner(rows *sql.Rows, options valueOptions) (*rowScanner, error) {
+	// Get column names
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a slice of interface{} to hold each row's values
+	s := &rowScanner{
+		rows:       rows,
+		columns:    columns,
+		converters: options.converters(columnTypes),
+		values:     make([]interface{}, len(columns)),
+		scanArgs:   make([]interface{}, len(columns)),
+	}
+	for i := range s.values {
+		s.scanArgs[i] = &s.values[i]
+	}
+	return s, nil
+}
+
+// next returns the next row, or nil after the last one
+func (s *rowScanner) next() (map[string]interface{}, error) {
+	if !s.rows.Next() {
+		return nil, s.rows.Err()
+	}
+	if err := s.rows.Scan(s.scanArgs...); err != nil {
+		return nil, err
+	}
+
+	// Create a map for this row
+	row := make(map[string]interface{}, len(s.columns))
+	for i, col := range s.columns {
+		val := s.values[i]
+
+		// Handle nil values
+		if val == nil {
+			row[col] = nil
+			continue
+		}
+
+		row[col] = s.converters[i](val)
+	}
+	return row, nil
+}
+
+// Cursor reads a result set in batches. It holds a connection until it is
+// closed, read to the end or CURSOR_TIMEOUT expires.
+type Cursor struct {
+	scanner *rowScanner
+	cancel  context.CancelFunc
+	done    bool
+}
+
+func newCursor(rows *sql.Rows, cancel context.CancelFunc, options valueOptions) (*Cursor, error) {
+	scanner, err := newRowScanner(rows, options)
+	if err != nil {
+		rows.Close()
+		cancel()
+		return nil, err
+	}
+	return &Cursor{scanner: scanner, cancel: cancel}, nil
+}
+
+// Columns returns the column names of the result set
+func (c *Cursor) Columns() []string {
+	return c.scanner.columns
+}
+
+// Next returns up to n rows; an empty result means the end was reached
+func (c *Cursor) Next(n int) ([]map[string]interface{}, error) {
+	result := make([]map[string]interface{}, 0, n)
+	for !c.done && len(result) < n {
+		row, err := c.scanner.next()
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+		if row == nil {
+			c.Close()
+			break
+		}
+		result = append(result, row)
+	}
+	return result, nil
+}
+
+// Close releases the connection; closing twice is a no-op
+func (c *Cursor) Close() error {
+	if c.done {
+		return nil
+	}
+	c.done = true
+	defer c.cancel()
+	return c.scanner.rows.Close()
+}
+
+// each calls fn for every row until it returns false
+func each(cursor *Cursor, fn func(row map[string]interface{}) (bool, error)) error {
+	defer cursor.Close()
+	for {
+		row, err := cursor.scanner.next()
+		if err != nil || row == nil {
+			return err
+		}
+		if more, err := fn(row); err != nil || !more {
+			return err
+		}
+	}
+}
+
+// Cursor runs a query and returns a cursor over its rows. Statistics cover
+// the query execution, not reading the rows.
+func (c *MySQLClient) Cursor(query string, args ...interface{}) (*Cursor, error) {
+	if c.db == nil {
+		return nil, fmt.Errorf("MySQL client not initialized")
+	}
+	args, err := c.values.bindArgs(args)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), CURSOR_TIMEOUT)
+	start := time.Now()
+	rows, err := c.queryContext(ctx, query, args)
+	c.trace.observe(query, args, start, err)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return newCursor(rows, cancel, c.values)
+}
+
+// Each runs a query and calls fn for every row until it returns false,
+// without loading the whole result
+func (c *MySQLClient) Each(query string, args []interface{}, fn func(row map[string]interface{}) (bool, error)) error {
+	cursor, err := c.Cursor(query, args...)
+	if err != nil {
+		return err
+	}
+	return each(cursor, fn)
+}
+
+// Scan is Each bounded by the statement timeout instead of CURSOR_TIMEOUT,
+// for results read whole. Statistics cover reading the rows.
+func (c *MySQLClient) Scan(query string, args []interface{}, fn func(row map[string]interface{}) (bool, error)) error {
+	if c.db == nil {
+		return fmt.Errorf("MySQL client not initialized")
+	}
+	args, err := c.values.bindArgs(args)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	start := time.Now()
+	rows, err := c.queryContext(ctx, query, args)
+	if err != nil {
+		cancel()
+		c.trace.observe(query, args, start, err)
+		return err
+	}
+	fnErr, err := scan(rows, cancel, c.values, fn)
+	c.trace.observe(query, args, start, err)
+	if err != nil {
+		return err
+	}
+	return fnErr
+}
+
+// scan reads rows like each; errors returned by fn are reported apart, they
+// are not errors of the statement
+func scan(rows *sql.Rows, cancel context.CancelFunc, options valueOptions, fn func(row map[string]interface{}) (bool, error)) (fnErr, err error) {
+	cursor, err := newCursor(rows, cancel, options)
+	if err != nil {
+		return nil, err
+	}
+	err = each(cursor, func(row map[string]interface{}) (bool, error) {
+		more, err := fn(row)
+		if err != nil {
+			fnErr = err
+			return false, nil
+		}
+		return more, nil
+	})
+	return fnErr, err
+}
+
+// MaxRows is the default row cap of script queries on this database
+func (c *MySQLClient) MaxRows() int {
+	return c.maxRows
+}
+
+// Cursor runs a query inside the transaction. The connection is busy until
+// the cursor is closed or read to the end, other statements of the
+// transaction must wait until then.
+func (t *MySQLTx) Cursor(query string, args ...interface{}) (*Cursor, error) {
+	args, err := t.values.bindArgs(args)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(t.ctx, CURSOR_TIMEOUT)
+	start := time.Now()
+	rows, err := t.tx.QueryContext(ctx, query, args...)
+	t.trace.observe(query, args, start, err)
+	if err != nil {
+		cancel()
+		return nil, t.wrap(err)
+	}
+	return newCursor(rows, cancel, t.values)
+}
+
+// Each is MySQLClient.Each inside the transaction, see Cursor
+func (t *MySQLTx) Each(query string, args []interface{}, fn func(row map[string]interface{}) (bool, error)) error {
+	cursor, err := t.Cursor(query, args...)
+	if err != nil {
+		return err
+	}
+	return t.wrap(each(cursor, fn))
+}
+
+// Scan is MySQLClient.Scan inside the transaction
+func (t *MySQLTx) Scan(query string, args []interface{}, fn func(row map[string]interface{}) (bool, error)) error {
+	args, err := t.values.bindArgs(args)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(t.ctx, t.timeout)
+	start := time.Now()
+	rows, err := t.tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		cancel()
+		err = t.wrap(err)
+		t.trace.observe(query, args, start, err)
+		return err
+	}
+	fnErr, err := scan(rows, cancel, t.values, fn)
+	err = t.wrap(err)
+	t.trace.observe(query, args, start, err)
+	if err != nil {
+		return err
+	}
+	return fnErr
+}
+
+func (t *MySQLTx) MaxRows() int {
+	return t.maxRows
+}
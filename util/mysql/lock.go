@@ -0,0 +1,85 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrLockTimeout is returned when an advisory lock is held elsewhere for
+// longer than the wait timeout
+var ErrLockTimeout = errors.New("timed out waiting for lock")
+
+// LockedConn is the connection holding an advisory lock, see LockConn
+type LockedConn struct {
+	client *MySQLClient
+	conn   *sql.Conn
+	name   string
+}
+
+// Lock takes a named advisory lock (GET_LOCK) on the current schema, waiting
+// up to timeout. MySQL ties the lock to the connection, so one connection is
+// held until unlock is called; the lock is also released if the node dies.
+func (c *MySQLClient) Lock(name string, timeout time.Duration) (unlock func(), err error) {
+	locked, err := c.LockConn(name, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return locked.Unlock, nil
+}
+
+// LockConn is Lock returning the connection that holds the lock, so the
+// work guarded by the lock can run on it
+func (c *MySQLClient) LockConn(name string, timeout time.Duration) (*LockedConn, error) {
+	if c.db == nil {
+		return nil, fmt.Errorf("MySQL client not initialized")
+	}
+	conn, err := c.db.Conn(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout+c.timeout)
+	defer cancel()
+	var acquired sql.NullInt64
+	err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(CONCAT(IFNULL(DATABASE(), ''), '.', ?), ?)", name, int(timeout.Seconds())).Scan(&acquired)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !acquired.Valid || acquired.Int64 != 1 {
+		conn.Close()
+		return nil, fmt.Errorf("%w %s", ErrLockTimeout, name)
+	}
+	return &LockedConn{client: c, conn: conn, name: name}, nil
+}
+
+// Exec runs a statement on the locked connection. timeout 0 applies no
+// statement timeout, for DDL that may run for a long time.
+func (l *LockedConn) Exec(timeout time.Duration, query string, args ...interface{}) (sql.Result, error) {
+	args, err := l.client.values.bindArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	result, err := l.conn.ExecContext(ctx, query, args...)
+	l.client.trace.observe(query, args, start, err)
+	return result, err
+}
+
+// Unlock releases the lock and returns the connection to the pool
+func (l *LockedConn) Unlock() {
+	ctx, cancel := context.WithTimeout(context.Background(), l.client.timeout)
+	defer cancel()
+	l.conn.ExecContext(ctx, "SELECT RELEASE_LOCK(CONCAT(IFNULL(DATABASE(), ''), '.', ?))", l.name)
+	l.conn.Close()
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"main/config"
+	"strings"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
@@ -13,8 +14,12 @@ import (
 
 // MySQLClient encapsulates MySQL database operations
 type MySQLClient struct {
-	db      *sql.DB
-	timeout time.Duration
+	trace    tracer
+	db       *sql.DB
+	replicas *replicaSet // nil without replicas
+	timeout  time.Duration
+	values   valueOptions
+	maxRows  int // 0 = unlimited
 }
 
 // Initialize creates MySQL clients with the given configurations
@@ -48,6 +53,7 @@ func Initialize() error {
 		}
 
 		// Initialize all MySQL clients from the configuration
+		var failed []string
 		for i, mysqlConfig := range cfg.Database.MySQLList {
 			name := mysqlConfig.Name
 			if name == "" {
@@ -63,13 +69,12 @@ func Initialize() error {
 			db, dbErr := sql.Open("mysql", mysqlConfig.ConnString)
 			if dbErr != nil {
 				log.Printf("Failed to connect to MySQL '%s': %v", name, dbErr)
+				failed = append(failed, name)
 				continue
 			}
 
 			// Set connection pool settings
-			db.SetMaxOpenConns(25)
-			db.SetMaxIdleConns(5)
-			db.SetConnMaxLifetime(1 * time.Hour)
+			applyPool(name, db, &mysqlConfig.Pool)
 
 			// Test the connection
 			timeout := 10 // Default timeout
@@ -78,16 +83,27 @@ func Initialize() error {
 			}
 
 			pingCtx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
-			defer cancel()
-
-			if pingErr := db.PingContext(pingCtx); pingErr != nil {
+			pingErr := db.PingContext(pingCtx)
+			cancel()
+			if pingErr != nil {
 				log.Printf("Failed to ping MySQL '%s': %v", name, pingErr)
+				db.Close()
+				failed = append(failed, name)
 				continue
 			}
 
 			client := &MySQLClient{
-				db:      db,
-				timeout: time.Duration(timeout) * time.Second,
+				trace:    newTracer(name, &mysqlConfig),
+				db:       db,
+				replicas: openReplicas(name, &mysqlConfig, time.Duration(timeout)*time.Second),
+				timeout:  time.Duration(timeout) * time.Second,
+				values:   newValueOptions(&cfg.Database.MySQLList[i]),
+				maxRows:  MYSQL_MAX_ROWS,
+			}
+			if mysqlConfig.MaxRows > 0 {
+				client.maxRows = mysqlConfig.MaxRows
+			} else if mysqlConfig.MaxRows < 0 {
+				client.maxRows = 0
 			}
 
 			// Add to the clients map
@@ -110,13 +126,19 @@ func Initialize() error {
 				break
 			}
 		}
+		if len(failed) > 0 {
+			err = fmt.Errorf("failed to connect to MySQL database(s): %s", strings.Join(failed, ", "))
+		}
 	})
 
 	return err
 }
 
-// Close closes the MySQL database connection
+// Close closes the MySQL database connection and its replicas
 func (c *MySQLClient) Close() error {
+	if c.replicas != nil {
+		c.replicas.close()
+	}
 	if c.db != nil {
 		return c.db.Close()
 	}
@@ -124,15 +146,21 @@ func (c *MySQLClient) Close() error {
 }
 
 // Query executes a query that returns rows
+// The rows outlive this call, so no statement timeout is applied; the caller
+// must close them
 func (c *MySQLClient) Query(query string, args ...interface{}) (*sql.Rows, error) {
 	if c.db == nil {
 		return nil, fmt.Errorf("MySQL client not initialized")
 	}
+	args, err := c.values.bindArgs(args)
+	if err != nil {
+		return nil, err
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
-	defer cancel()
-
-	return c.db.QueryContext(ctx, query, args...)
+	start := time.Now()
+	rows, err := c.queryContext(context.Background(), query, args)
+	c.trace.observe(query, args, start, err)
+	return rows, err
 }
 
 // QueryRow executes a query that returns a single row
@@ -140,11 +168,18 @@ func (c *MySQLClient) QueryRow(query string, args ...interface{}) *sql.Row {
 	if c.db == nil {
 		return nil
 	}
+	if bound, err := c.values.bindArgs(args); err == nil {
+		args = bound
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
-	defer cancel()
-
-	return c.db.QueryRowContext(ctx, query, args...)
+	start := time.Now()
+	db := c.db
+	if r := c.reader(query); r != nil {
+		db = r.db
+	}
+	row := db.QueryRowContext(context.Background(), query, args...)
+	c.trace.observe(query, args, start, row.Err())
+	return row
 }
 
 // Exec executes a query that doesn't return rows
@@ -152,90 +187,77 @@ func (c *MySQLClient) Exec(query string, args ...interface{}) (sql.Result, error
 	if c.db == nil {
 		return nil, fmt.Errorf("MySQL client not initialized")
 	}
+	args, err := c.values.bindArgs(args)
+	if err != nil {
+		return nil, err
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
 	defer cancel()
 
-	return c.db.ExecContext(ctx, query, args...)
+	start := time.Now()
+	result, err := c.db.ExecContext(ctx, query, args...)
+	c.trace.observe(query, args, start, err)
+	return result, err
 }
 
-// QueryToMap executes a query and returns the results as a slice of maps
+// QueryToMap executes a query and returns the results as a slice of maps,
+// with values converted by column type (see types.go)
 func (c *MySQLClient) QueryToMap(query string, args ...interface{}) ([]map[string]interface{}, error) {
 	if c.db == nil {
 		return nil, fmt.Errorf("MySQL client not initialized")
 	}
+	args, err := c.values.bindArgs(args)
+	if err != nil {
+		return nil, err
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
 	defer cancel()
 
-	rows, err := c.db.QueryContext(ctx, query, args...)
-	if err != nil {
-		return nil, err
+	start := time.Now()
+	rows, err := c.queryContext(ctx, query, args)
+	if err == nil {
+		var result []map[string]interface{}
+		result, err = scanRows(rows, c.values)
+		c.trace.observe(query, args, start, err)
+		return result, err
 	}
+	c.trace.observe(query, args, start, err)
+	return nil, err
+}
+
+// scanRows reads all rows into maps and closes them
+func scanRows(rows *sql.Rows, options valueOptions) ([]map[string]interface{}, error) {
 	defer rows.Close()
 
-	// Get column names
-	columns, err := rows.Columns()
+	scanner, err := newRowScanner(rows, options)
 	if err != nil {
 		return nil, err
 	}
 
-	// Create a slice of interface{} to hold each row's values
-	values := make([]interface{}, len(columns))
-	scanArgs := make([]interface{}, len(columns))
-	for i := range values {
-		scanArgs[i] = &values[i]
-	}
-
 	// Create the result slice
 	var result []map[string]interface{}
 
 	// Iterate through the rows
-	for rows.Next() {
-		err = rows.Scan(scanArgs...)
+	for {
+		row, err := scanner.next()
 		if err != nil {
 			return nil, err
 		}
-
-		// Create a map for this row
-		row := make(map[string]interface{})
-		for i, col := range columns {
-			val := values[i]
-
-			// Handle nil values
-			if val == nil {
-				row[col] = nil
-				continue
-			}
-
-			// Handle different types
-			switch v := val.(type) {
-			case []byte:
-				// Convert []byte to string for text data
-				row[col] = string(v)
-			default:
-				row[col] = v
-			}
+		if row == nil {
+			return result, nil
 		}
-
 		result = append(result, row)
 	}
-
-	if err = rows.Err(); err != nil {
-		return nil, err
-	}
-
-	return result, nil
 }
 
-// Begin starts a new transaction
+// Begin starts a new transaction without a time limit, see BeginTx
 func (c *MySQLClient) Begin() (*sql.Tx, error) {
 	if c.db == nil {
 		return nil, fmt.Errorf("MySQL client not initialized")
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
-	defer cancel()
-
-	return c.db.BeginTx(ctx, nil)
+	// 事务的 context 在提交或回滚前不能取消，否则驱动会自动回滚
+	return c.db.BeginTx(context.Background(), nil)
 }
@@ -0,0 +1,214 @@
+package mysql
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ExpandParams rewrites named parameters (:name, bound from named) into
+// positional ones and expands array values into "?, ?, ?" lists for
+// IN (...); an empty array becomes NULL, which matches nothing. Placeholders
+// inside quotes, backticks and comments are left alone.
+func ExpandParams(query string, args []interface{}, named map[string]interface{}) (string, []interface{}, error) {
+	if named == nil && !hasArray(args) {
+		return query, args, nil
+	}
+
+	var result []interface{}
+	position := 0
+	var err error
+	expanded := scanPlaceholders(query, func(name string) string {
+		if err != nil {
+			return ""
+		}
+		var value interface{}
+		if name == "" {
+			if named != nil {
+				err = fmt.Errorf("cannot mix ? and :name parameters")
+				return ""
+			}
+			if position >= len(args) {
+				err = fmt.Errorf("missing parameter %d", position+1)
+				return ""
+			}
+			value = args[position]
+			position++
+		} else {
+			if named == nil {
+				return ":" + name
+			}
+			var ok bool
+			if value, ok = named[name]; !ok {
+				err = fmt.Errorf("missing parameter :%s", name)
+				return ""
+			}
+		}
+
+		list, ok := value.([]interface{})
+		if !ok {
+			result = append(result, value)
+			return "?"
+		}
+		if len(list) == 0 {
+			return "NULL"
+		}
+		result = append(result, list...)
+		return strings.TrimSuffix(strings.Repeat("?, ", len(list)), ", ")
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	if named == nil && position < len(args) {
+		return "", nil, fmt.Errorf("%d parameters given but the query uses %d", len(args), position)
+	}
+	return expanded, result, nil
+}
+
+// HasNamedParams reports whether the query uses :name parameters
+func HasNamedParams(query string) bool {
+	found := false
+	scanPlaceholders(query, func(name string) string {
+		if name != "" {
+			found = true
+			return ":" + name
+		}
+		return "?"
+	})
+	return found
+}
+
+func hasArray(args []interface{}) bool {
+	for _, arg := range args {
+		if _, ok := arg.([]interface{}); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// scanPlaceholders copies the query, replacing each ? (name "") and :name
+// with the result of replace
+func scanPlaceholders(query string, replace func(name string) string) string {
+	var b strings.Builder
+	n := len(query)
+	for i := 0; i < n; {
+		c := query[i]
+		if end := literalEnd(query, i); end > i {
+			b.WriteString(query[i:end])
+			i = end
+			continue
+		}
+		switch {
+		case c == '?':
+			b.WriteString(replace(""))
+			i++
+		case c == ':' && i+1 < n && isNameStart(query[i+1]) && (i == 0 || query[i-1] != ':'):
+			j := i + 1
+			for j < n && isNamePart(query[j]) {
+				j++
+			}
+			b.WriteString(replace(query[i+1 : j]))
+			i = j
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String()
+}
+
+// SplitStatements splits a script into statements at semicolons outside
+// quotes and comments. Comment-only and empty statements are dropped;
+// DELIMITER is not supported.
+func SplitStatements(script string) []string {
+	var statements []string
+	start := 0
+	add := func(end int) {
+		if statement := strings.TrimSpace(script[start:end]); !isCommentOnly(statement) {
+			statements = append(statements, statement)
+		}
+		start = end + 1
+	}
+	for i := 0; i < len(script); {
+		if end := literalEnd(script, i); end > i {
+			i = end
+			continue
+		}
+		if script[i] == ';' {
+			add(i)
+		}
+		i++
+	}
+	if start < len(script) {
+		add(len(script))
+	}
+	return statements
+}
+
+func isCommentOnly(statement string) bool {
+	for i := 0; i < len(statement); {
+		end := literalEnd(statement, i)
+		switch {
+		case end > i && statement[i] != '\'' && statement[i] != '"' && statement[i] != '`':
+			i = end
+		case statement[i] == ' ' || statement[i] == '\t' || statement[i] == '\r' || statement[i] == '\n':
+			i++
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// literalEnd returns the index after the quoted string or comment starting
+// at i, or i when there is none
+func literalEnd(query string, i int) int {
+	n := len(query)
+	c := query[i]
+	switch {
+	case c == '\'' || c == '"' || c == '`':
+		return quoteEnd(query, i)
+	case c == '#' || (c == '-' && strings.HasPrefix(query[i:], "-- ")):
+		end := strings.IndexByte(query[i:], '\n')
+		if end < 0 {
+			return n
+		}
+		return i + end
+	case c == '/' && strings.HasPrefix(query[i:], "/*"):
+		end := strings.Index(query[i+2:], "*/")
+		if end < 0 {
+			return n
+		}
+		return end + i + 4
+	}
+	return i
+}
+
+// quoteEnd returns the index after the quoted string starting at i, allowing
+// backslash escapes and doubled quotes
+func quoteEnd(query string, i int) int {
+	quote := query[i]
+	for j := i + 1; j < len(query); j++ {
+		switch query[j] {
+		case '\\':
+			if quote != '`' {
+				j++
+			}
+		case quote:
+			if j+1 < len(query) && query[j+1] == quote {
+				j++
+				continue
+			}
+			return j + 1
+		}
+	}
+	return len(query)
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isNamePart(c byte) bool {
+	return isNameStart(c) || (c >= '0' && c <= '9')
+}
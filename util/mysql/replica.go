@@ -0,0 +1,340 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"main/config"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+)
+
+const (
+	MYSQL_MAX_OPEN_CONNS    = 25
+	MYSQL_MAX_IDLE_CONNS    = 5
+	MYSQL_CONN_MAX_LIFETIME = time.Hour
+
+	// REPLICA_CHECK_INTERVAL is the default interval of replica health checks
+	REPLICA_CHECK_INTERVAL = 10 * time.Second
+)
+
+var (
+	readStatement = regexp.MustCompile(`(?i)^(SELECT|SHOW|WITH|EXPLAIN|DESC|DESCRIBE)\b`)
+	// 加锁读和会话相关的函数必须在主库执行
+	primaryOnly = regexp.MustCompile(`(?i)\bFOR\s+(UPDATE|SHARE)\b|\bLOCK\s+IN\s+SHARE\s+MODE\b|\b(GET_LOCK|RELEASE_LOCK|IS_FREE_LOCK|IS_USED_LOCK|LAST_INSERT_ID|FOUND_ROWS)\s*\(|\bINTO\s+(@|OUTFILE|DUMPFILE)|(?s:^WITH\b.*\b(UPDATE|DELETE)\b)`)
+)
+
+// replica is a read-only copy of a database. Reads are sent to it while
+// health checks pass.
+type replica struct {
+	addr    string
+	db      *sql.DB
+	healthy atomic.Bool
+	reads   atomic.Uint64
+
+	mu        sync.Mutex
+	lastCheck time.Time
+	lastError string
+}
+
+// replicaSet balances reads over the healthy replicas of a client
+type replicaSet struct {
+	replicas  []*replica
+	next      atomic.Uint64
+	fallbacks atomic.Uint64 // reads sent to the primary because no replica was available
+	stop      chan struct{}
+}
+
+// openReplicas connects the replicas of a database; unreachable ones start
+// unhealthy and are picked up by the health checks once they recover
+func openReplicas(name string, cfg *config.MySQLConfig, timeout time.Duration) *replicaSet {
+	if len(cfg.Replicas) == 0 {
+		return nil
+	}
+	set := &replicaSet{stop: make(chan struct{})}
+	for i, dsn := range cfg.Replicas {
+		addr := replicaAddr(dsn, i)
+		db, err := sql.Open("mysql", dsn)
+		if err != nil {
+			log.Printf("Failed to open MySQL '%s' replica %s: %v", name, addr, err)
+			continue
+		}
+		applyPool(name, db, &cfg.Pool)
+		r := &replica{addr: addr, db: db}
+		r.check(timeout)
+		set.replicas = append(set.replicas, r)
+		log.Printf("MySQL '%s' replica %s added, healthy: %v", name, addr, r.healthy.Load())
+	}
+	if len(set.replicas) == 0 {
+		return nil
+	}
+
+	interval := REPLICA_CHECK_INTERVAL
+	if cfg.HealthCheck > 0 {
+		interval = time.Duration(cfg.HealthCheck) * time.Second
+	}
+	go set.monitor(name, interval, timeout)
+	return set
+}
+
+// replicaAddr returns the address of a DSN, without credentials, for logs
+// and statistics
+func replicaAddr(dsn string, index int) string {
+	if parsed, err := mysqldriver.ParseDSN(dsn); err == nil && parsed.Addr != "" {
+		return parsed.Addr
+	}
+	return fmt.Sprintf("replica_%d", index)
+}
+
+// applyPool sets the pool limits of a connection pool, with the defaults
+// for unset values
+func applyPool(name string, db *sql.DB, pool *config.MySQLPoolConfig) {
+	maxOpen, maxIdle, lifetime := MYSQL_MAX_OPEN_CONNS, MYSQL_MAX_IDLE_CONNS, MYSQL_CONN_MAX_LIFETIME
+	if pool.MaxOpen != 0 {
+		maxOpen = pool.MaxOpen
+	}
+	if pool.MaxIdle != 0 {
+		maxIdle = pool.MaxIdle
+	}
+	if pool.MaxLifetime != "" {
+		if d, err := time.ParseDuration(pool.MaxLifetime); err == nil {
+			lifetime = d
+		} else {
+			log.Printf("Warning: Invalid maxLifetime %s for MySQL '%s': %v", pool.MaxLifetime, name, err)
+		}
+	}
+	db.SetMaxOpenConns(maxOpen)
+	db.SetMaxIdleConns(maxIdle)
+	db.SetConnMaxLifetime(lifetime)
+	if pool.MaxIdleTime != "" {
+		if d, err := time.ParseDuration(pool.MaxIdleTime); err == nil {
+			db.SetConnMaxIdleTime(d)
+		} else {
+			log.Printf("Warning: Invalid maxIdleTime %s for MySQL '%s': %v", pool.MaxIdleTime, name, err)
+		}
+	}
+}
+
+// 定期检查副本，状态变化时记录日志
+func (s *replicaSet) monitor(name string, interval, timeout time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+		for _, r := range s.replicas {
+			was := r.healthy.Load()
+			if healthy := r.check(timeout); healthy != was {
+				if healthy {
+					log.Printf("MySQL '%s' replica %s recovered", name, r.addr)
+				} else {
+					log.Printf("MySQL '%s' replica %s is down: %s", name, r.addr, r.status())
+				}
+			}
+		}
+	}
+}
+
+// check pings the replica and records the result
+func (r *replica) check(timeout time.Duration) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	err := r.db.PingContext(ctx)
+
+	r.mu.Lock()
+	r.lastCheck = time.Now()
+	r.lastError = ""
+	if err != nil {
+		r.lastError = err.Error()
+	}
+	r.mu.Unlock()
+	r.healthy.Store(err == nil)
+	return err == nil
+}
+
+// markDown takes the replica out of rotation until the next successful check
+func (r *replica) markDown(err error) {
+	r.mu.Lock()
+	r.lastError = err.Error()
+	r.mu.Unlock()
+	r.healthy.Store(false)
+}
+
+func (r *replica) status() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lastError
+}
+
+// pick returns the next healthy replica, nil if there is none
+func (s *replicaSet) pick() *replica {
+	n := uint64(len(s.replicas))
+	start := s.next.Add(1)
+	for i := uint64(0); i < n; i++ {
+		if r := s.replicas[(start+i)%n]; r.healthy.Load() {
+			r.reads.Add(1)
+			return r
+		}
+	}
+	s.fallbacks.Add(1)
+	return nil
+}
+
+func (s *replicaSet) close() {
+	close(s.stop)
+	for _, r := range s.replicas {
+		r.db.Close()
+	}
+}
+
+// IsReadQuery reports whether a statement may run on a replica: plain
+// SELECT / SHOW / EXPLAIN without locking reads or session functions
+func IsReadQuery(query string) bool {
+	query = strings.TrimLeft(skipComments(query), " \t\r\n(")
+	return readStatement.MatchString(query) && !primaryOnly.MatchString(query)
+}
+
+// skipComments drops leading comments, e.g. /* hints */
+func skipComments(query string) string {
+	for {
+		query = strings.TrimLeft(query, " \t\r\n")
+		if query == "" {
+			return query
+		}
+		end := literalEnd(query, 0)
+		if end == 0 || query[0] == '\'' || query[0] == '"' || query[0] == '`' {
+			return query
+		}
+		query = query[end:]
+	}
+}
+
+// Primary returns the client without replicas, for reads that must see the
+// latest writes
+func (c *MySQLClient) Primary() *MySQLClient {
+	if c.replicas == nil {
+		return c
+	}
+	primary := *c
+	primary.replicas = nil
+	return &primary
+}
+
+// reader picks a replica for a read statement, nil to use the primary
+func (c *MySQLClient) reader(query string) *replica {
+	if c.replicas == nil || !IsReadQuery(query) {
+		return nil
+	}
+	return c.replicas.pick()
+}
+
+// queryContext runs a query on a replica when it is a read, or on the
+// primary. A replica that cannot be reached is taken out of rotation and
+// the query retried on the primary.
+func (c *MySQLClient) queryContext(ctx context.Context, query string, args []interface{}) (*sql.Rows, error) {
+	if r := c.reader(query); r != nil {
+		rows, err := r.db.QueryContext(ctx, query, args...)
+		var serverErr *mysqldriver.MySQLError
+		if err == nil || errors.As(err, &serverErr) || ctx.Err() != nil {
+			return rows, err
+		}
+		log.Printf("MySQL replica %s failed, retrying on the primary: %v", r.addr, err)
+		r.markDown(err)
+		c.replicas.fallbacks.Add(1)
+	}
+	return c.db.QueryContext(ctx, query, args...)
+}
+
+// PoolStats is a snapshot of a connection pool
+type PoolStats struct {
+	MaxOpen           int   `json:"maxOpen"`
+	Open              int   `json:"open"`
+	InUse             int   `json:"inUse"`
+	Idle              int   `json:"idle"`
+	WaitCount         int64 `json:"waitCount"`
+	WaitDuration      int64 `json:"waitMs"`
+	MaxIdleClosed     int64 `json:"maxIdleClosed"`
+	MaxIdleTimeClosed int64 `json:"maxIdleTimeClosed"`
+	MaxLifetimeClosed int64 `json:"maxLifetimeClosed"`
+}
+
+// ReplicaStats is the state of a replica
+type ReplicaStats struct {
+	Addr      string     `json:"addr"`
+	Healthy   bool       `json:"healthy"`
+	LastCheck *time.Time `json:"lastCheck,omitempty"`
+	LastError string     `json:"lastError,omitempty"`
+	Reads     uint64     `json:"reads"`
+	Pool      PoolStats  `json:"pool"`
+}
+
+// ClientStats is the state of a named database
+type ClientStats struct {
+	Name      string         `json:"name"`
+	Default   bool           `json:"default"`
+	Pool      PoolStats      `json:"pool"`
+	Fallbacks uint64         `json:"fallbacks,omitempty"` // reads sent to the primary for lack of a healthy replica
+	Replicas  []ReplicaStats `json:"replicas,omitempty"`
+}
+
+func poolStats(db *sql.DB) PoolStats {
+	s := db.Stats()
+	return PoolStats{
+		MaxOpen:           s.MaxOpenConnections,
+		Open:              s.OpenConnections,
+		InUse:             s.InUse,
+		Idle:              s.Idle,
+		WaitCount:         s.WaitCount,
+		WaitDuration:      s.WaitDuration.Milliseconds(),
+		MaxIdleClosed:     s.MaxIdleClosed,
+		MaxIdleTimeClosed: s.MaxIdleTimeClosed,
+		MaxLifetimeClosed: s.MaxLifetimeClosed,
+	}
+}
+
+// Stats returns the pool statistics of every database, ordered by name
+func Stats() []ClientStats {
+	result := make([]ClientStats, 0, len(MYSQL_CLIENTS))
+	for name, client := range MYSQL_CLIENTS {
+		stats := ClientStats{
+			Name:    name,
+			Default: client == MYSQL_CLIENT,
+			Pool:    poolStats(client.db),
+		}
+		if client.replicas != nil {
+			stats.Fallbacks = client.replicas.fallbacks.Load()
+			for _, r := range client.replicas.replicas {
+				r.mu.Lock()
+				rs := ReplicaStats{
+					Addr:      r.addr,
+					Healthy:   r.healthy.Load(),
+					LastError: r.lastError,
+					Reads:     r.reads.Load(),
+					Pool:      poolStats(r.db),
+				}
+				if !r.lastCheck.IsZero() {
+					lastCheck := r.lastCheck
+					rs.LastCheck = &lastCheck
+				}
+				r.mu.Unlock()
+				stats.Replicas = append(stats.Replicas, rs)
+			}
+		}
+		result = append(result, stats)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
@@ -0,0 +1,393 @@
+package mysql
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"main/config"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"github.com/puzpuzpuz/xsync/v4"
+)
+
+const (
+	// SLOW_QUERY_THRESHOLD is the default slow query threshold
+	SLOW_QUERY_THRESHOLD = time.Second
+	// SLOW_QUERY_HISTORY is the number of recent slow queries kept for the API
+	SLOW_QUERY_HISTORY = 100
+	// STATS_MAX_STATEMENTS caps the distinct statements tracked, later ones
+	// are only counted as dropped
+	STATS_MAX_STATEMENTS = 2000
+	// STATS_SAMPLES is the number of recent durations percentiles are computed from
+	STATS_SAMPLES = 256
+	// STATS_MAX_SCRIPTS caps the scripts attributed per statement
+	STATS_MAX_SCRIPTS = 20
+)
+
+var (
+	valueList   = regexp.MustCompile(`\(\s*\?(\s*,\s*\?)*\s*\)`)
+	repeatedSet = regexp.MustCompile(`\(\?\+\)(\s*,\s*\(\?\+\))+`)
+	// 错误信息中的引号内容，如 Duplicate entry 'alice@example.com' for key 'users.email'
+	quotedValue = regexp.MustCompile(`'(?:[^'\\]|\\.)*'`)
+	// 引号前是这些词时为对象名，保留
+	identifierWord = regexp.MustCompile(`(?i)\b(key|column|table|database|index|constraint|function|procedure)\s*$`)
+)
+
+// tracer attributes the statements of a client or transaction to a database
+// and the script that issued them
+type tracer struct {
+	database string
+	script   string
+	slow     time.Duration // 0 = slow query log off
+}
+
+func newTracer(name string, cfg *config.MySQLConfig) tracer {
+	t := tracer{database: name, slow: SLOW_QUERY_THRESHOLD}
+	switch cfg.SlowQuery {
+	case "":
+	case "off", "0":
+		t.slow = 0
+	default:
+		if d, err := time.ParseDuration(cfg.SlowQuery); err == nil {
+			t.slow = d
+		} else {
+			log.Printf("Warning: Invalid slowQuery %s for MySQL '%s': %v", cfg.SlowQuery, name, err)
+		}
+	}
+	return t
+}
+
+// observe records a statement that started at start
+func (t tracer) observe(query string, args []interface{}, start time.Time, err error) {
+	duration := time.Since(start)
+	statement := NormalizeQuery(query)
+	errText := ""
+	if err != nil {
+		errText = RedactError(err)
+	}
+	STATEMENT_STATS.record(t.database, t.script, statement, duration, errText)
+
+	if t.slow > 0 && duration >= t.slow {
+		slow := SlowQuery{
+			Database:  t.database,
+			Script:    t.script,
+			Statement: statement,
+			Params:    len(args),
+			Duration:  duration.Milliseconds(),
+			Time:      start,
+		}
+		slow.Error = errText
+		STATEMENT_STATS.slow(slow)
+		log.Printf("Slow query on '%s' (%v, script '%s', %d params): %s", t.database, duration.Round(time.Millisecond), t.script, len(args), statement)
+	}
+}
+
+// RedactError describes a statement error without the values it repeats:
+// MySQL errors keep their number and have quoted values replaced by ?,
+// quoted object names after key, column, table etc. are kept
+func RedactError(err error) string {
+	message := err.Error()
+	var serverErr *mysqldriver.MySQLError
+	if errors.As(err, &serverErr) {
+		message = fmt.Sprintf("Error %d: %s", serverErr.Number, serverErr.Message)
+	}
+	var b strings.Builder
+	last := 0
+	for _, loc := range quotedValue.FindAllStringIndex(message, -1) {
+		b.WriteString(message[last:loc[0]])
+		if identifierWord.MatchString(message[:loc[0]]) {
+			b.WriteString(message[loc[0]:loc[1]])
+		} else {
+			b.WriteByte('?')
+		}
+		last = loc[1]
+	}
+	b.WriteString(message[last:])
+	return b.String()
+}
+
+// WithScript returns the client with its statements attributed to a script
+func (c *MySQLClient) WithScript(script string) *MySQLClient {
+	if c.trace.script == script {
+		return c
+	}
+	client := *c
+	client.trace.script = script
+	return &client
+}
+
+// StatementStats aggregates the executions of a normalized statement.
+// Percentiles are computed over the last STATS_SAMPLES executions.
+type StatementStats struct {
+	Database  string            `json:"database"`
+	Statement string            `json:"statement"`
+	Count     uint64            `json:"count"`
+	Errors    uint64            `json:"errors"`
+	LastError string            `json:"lastError,omitempty"`
+	Total     float64           `json:"totalMs"`
+	Avg       float64           `json:"avgMs"`
+	Max       float64           `json:"maxMs"`
+	P50       float64           `json:"p50Ms"`
+	P95       float64           `json:"p95Ms"`
+	P99       float64           `json:"p99Ms"`
+	Scripts   map[string]uint64 `json:"scripts,omitempty"`
+	LastSeen  time.Time         `json:"lastSeen"`
+}
+
+// SlowQuery is a statement that ran over the slow query threshold; its
+// literals are replaced by ? and parameters only counted
+type SlowQuery struct {
+	Database  string    `json:"database"`
+	Script    string    `json:"script,omitempty"`
+	Statement string    `json:"statement"`
+	Params    int       `json:"params"`
+	Duration  int64     `json:"durationMs"`
+	Error     string    `json:"error,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+type statementKey struct {
+	database  string
+	statement string
+}
+
+type statementEntry struct {
+	mu        sync.Mutex
+	count     uint64
+	errors    uint64
+	lastError string
+	total     time.Duration
+	max       time.Duration
+	samples   []time.Duration
+	next      int
+	scripts   map[string]uint64
+	lastSeen  time.Time
+}
+
+// StatementRecorder aggregates statement statistics and recent slow queries
+type StatementRecorder struct {
+	statements *xsync.Map[statementKey, *statementEntry]
+	dropped    uint64
+
+	mu        sync.Mutex
+	slowQuery []SlowQuery
+}
+
+var STATEMENT_STATS = NewStatementRecorder()
+
+func NewStatementRecorder() *StatementRecorder {
+	return &StatementRecorder{statements: xsync.NewMap[statementKey, *statementEntry]()}
+}
+
+func (r *StatementRecorder) record(database, script, statement string, duration time.Duration, errText string) {
+	key := statementKey{database, statement}
+	entry, ok := r.statements.Load(key)
+	if !ok {
+		if r.statements.Size() >= STATS_MAX_STATEMENTS {
+			r.mu.Lock()
+			r.dropped++
+			r.mu.Unlock()
+			return
+		}
+		entry, _ = r.statements.LoadOrStore(key, &statementEntry{scripts: make(map[string]uint64)})
+	}
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	entry.count++
+	entry.total += duration
+	entry.max = max(entry.max, duration)
+	entry.lastSeen = time.Now()
+	if errText != "" {
+		entry.errors++
+		entry.lastError = errText
+	}
+	if len(entry.samples) < STATS_SAMPLES {
+		entry.samples = append(entry.samples, duration)
+	} else {
+		entry.samples[entry.next] = duration
+		entry.next = (entry.next + 1) % STATS_SAMPLES
+	}
+	if script != "" {
+		if _, ok := entry.scripts[script]; ok || len(entry.scripts) < STATS_MAX_SCRIPTS {
+			entry.scripts[script]++
+		}
+	}
+}
+
+func (r *StatementRecorder) slow(query SlowQuery) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.slowQuery = append(r.slowQuery, query)
+	if len(r.slowQuery) > SLOW_QUERY_HISTORY {
+		r.slowQuery = r.slowQuery[len(r.slowQuery)-SLOW_QUERY_HISTORY:]
+	}
+}
+
+// Statements returns the statistics of the statements of database and
+// script ("" = any), sorted by sortBy (total, count, avg, max, p95, p99 or
+// errors, descending), at most limit (0 = all)
+func (r *StatementRecorder) Statements(database, script, sortBy string, limit int) []StatementStats {
+	result := make([]StatementStats, 0)
+	r.statements.Range(func(key statementKey, entry *statementEntry) bool {
+		if database != "" && key.database != database {
+			return true
+		}
+		entry.mu.Lock()
+		defer entry.mu.Unlock()
+		if script != "" && entry.scripts[script] == 0 {
+			return true
+		}
+
+		stats := StatementStats{
+			Database:  key.database,
+			Statement: key.statement,
+			Count:     entry.count,
+			Errors:    entry.errors,
+			LastError: entry.lastError,
+			Total:     milliseconds(entry.total),
+			Max:       milliseconds(entry.max),
+			LastSeen:  entry.lastSeen,
+		}
+		if entry.count > 0 {
+			stats.Avg = milliseconds(entry.total / time.Duration(entry.count))
+		}
+		samples := append([]time.Duration(nil), entry.samples...)
+		sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+		stats.P50 = percentile(samples, 0.50)
+		stats.P95 = percentile(samples, 0.95)
+		stats.P99 = percentile(samples, 0.99)
+		if len(entry.scripts) > 0 {
+			stats.Scripts = make(map[string]uint64, len(entry.scripts))
+			for name, count := range entry.scripts {
+				stats.Scripts[name] = count
+			}
+		}
+		result = append(result, stats)
+		return true
+	})
+
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		switch sortBy {
+		case "count":
+			return a.Count > b.Count
+		case "avg":
+			return a.Avg > b.Avg
+		case "max":
+			return a.Max > b.Max
+		case "p95":
+			return a.P95 > b.P95
+		case "p99":
+			return a.P99 > b.P99
+		case "errors":
+			return a.Errors > b.Errors
+		default:
+			return a.Total > b.Total
+		}
+	})
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
+
+// SlowQueries returns the recent slow queries, newest first
+func (r *StatementRecorder) SlowQueries() []SlowQuery {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	result := make([]SlowQuery, len(r.slowQuery))
+	for i, query := range r.slowQuery {
+		result[len(result)-1-i] = query
+	}
+	return result
+}
+
+// Dropped is the number of executions not tracked because
+// STATS_MAX_STATEMENTS was reached
+func (r *StatementRecorder) Dropped() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.dropped
+}
+
+// Reset clears all statistics and slow queries
+func (r *StatementRecorder) Reset() {
+	r.statements.Clear()
+	r.mu.Lock()
+	r.dropped = 0
+	r.slowQuery = nil
+	r.mu.Unlock()
+}
+
+func percentile(sorted []time.Duration, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	// nearest rank
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return milliseconds(sorted[max(rank, 0)])
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// NormalizeQuery reduces a statement to its shape: string and number
+// literals become ?, comments are dropped, whitespace is collapsed and
+// lists of placeholders such as IN (?, ?, ?) become (?+)
+func NormalizeQuery(query string) string {
+	var b strings.Builder
+	n := len(query)
+	space := false
+	for i := 0; i < n; {
+		c := query[i]
+		if end := literalEnd(query, i); end > i {
+			literal := "?"
+			switch c {
+			case '`':
+				literal = query[i:end]
+			case '\'', '"':
+			default:
+				space, literal = b.Len() > 0, ""
+			}
+			if literal != "" {
+				if space {
+					b.WriteByte(' ')
+					space = false
+				}
+				b.WriteString(literal)
+			}
+			i = end
+			continue
+		}
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			space = b.Len() > 0
+			i++
+			continue
+		case c >= '0' && c <= '9' && (i == 0 || !isNamePart(query[i-1])):
+			j := i
+			for j < n && (isNamePart(query[j]) || query[j] == '.') {
+				j++
+			}
+			c, i = '?', j
+		default:
+			i++
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteByte(c)
+	}
+
+	normalized := valueList.ReplaceAllString(b.String(), "(?+)")
+	return repeatedSet.ReplaceAllString(normalized, "(?+), ...")
+}
@@ -0,0 +1,175 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TX_DEFAULT_TIMEOUT limits transactions started without a timeout option
+const TX_DEFAULT_TIMEOUT = 60 * time.Second
+
+// ErrTxTimeout is returned by statements of a transaction that ran past its
+// timeout; the driver has already rolled it back
+var ErrTxTimeout = errors.New("transaction timed out and was rolled back")
+
+// Executor runs statements on a database or inside a transaction
+type Executor interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryToMap(query string, args ...interface{}) ([]map[string]interface{}, error)
+	Cursor(query string, args ...interface{}) (*Cursor, error)
+	Each(query string, args []interface{}, fn func(row map[string]interface{}) (bool, error)) error
+	Scan(query string, args []interface{}, fn func(row map[string]interface{}) (bool, error)) error
+	MaxRows() int
+}
+
+var (
+	_ Executor = (*MySQLClient)(nil)
+	_ Executor = (*MySQLTx)(nil)
+)
+
+// TxOptions configures BeginTx
+type TxOptions struct {
+	Isolation sql.IsolationLevel
+	ReadOnly  bool
+	Timeout   time.Duration // 0 = TX_DEFAULT_TIMEOUT
+}
+
+// MySQLTx is a transaction with its own deadline. Statements inside it also
+// use the client's per-statement timeout.
+type MySQLTx struct {
+	tx         *sql.Tx
+	trace      tracer
+	ctx        context.Context
+	cancel     context.CancelFunc
+	timeout    time.Duration
+	values     valueOptions
+	maxRows    int
+	savepoints int
+}
+
+// BeginTx starts a transaction, rolled back automatically once its timeout
+// expires
+func (c *MySQLClient) BeginTx(options TxOptions) (*MySQLTx, error) {
+	if c.db == nil {
+		return nil, fmt.Errorf("MySQL client not initialized")
+	}
+	timeout := options.Timeout
+	if timeout <= 0 {
+		timeout = TX_DEFAULT_TIMEOUT
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	tx, err := c.db.BeginTx(ctx, &sql.TxOptions{Isolation: options.Isolation, ReadOnly: options.ReadOnly})
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return &MySQLTx{tx: tx, trace: c.trace, ctx: ctx, cancel: cancel, timeout: c.timeout, values: c.values, maxRows: c.maxRows}, nil
+}
+
+// Exec executes a statement inside the transaction
+func (t *MySQLTx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	args, err := t.values.bindArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(t.ctx, t.timeout)
+	defer cancel()
+
+	start := time.Now()
+	result, err := t.tx.ExecContext(ctx, query, args...)
+	err = t.wrap(err)
+	t.trace.observe(query, args, start, err)
+	return result, err
+}
+
+// QueryToMap executes a query inside the transaction, see MySQLClient.QueryToMap
+func (t *MySQLTx) QueryToMap(query string, args ...interface{}) ([]map[string]interface{}, error) {
+	args, err := t.values.bindArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(t.ctx, t.timeout)
+	defer cancel()
+
+	start := time.Now()
+	rows, err := t.tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		err = t.wrap(err)
+		t.trace.observe(query, args, start, err)
+		return nil, err
+	}
+	result, err := scanRows(rows, t.values)
+	err = t.wrap(err)
+	t.trace.observe(query, args, start, err)
+	return result, err
+}
+
+// Commit commits the transaction
+func (t *MySQLTx) Commit() error {
+	defer t.cancel()
+	return t.wrap(t.tx.Commit())
+}
+
+// Rollback aborts the transaction; rolling back a finished one is a no-op
+func (t *MySQLTx) Rollback() error {
+	defer t.cancel()
+	if err := t.tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+		return err
+	}
+	return nil
+}
+
+// Savepoint creates a savepoint for a nested transaction and returns its name
+func (t *MySQLTx) Savepoint() (string, error) {
+	t.savepoints++
+	name := fmt.Sprintf("sp_%d", t.savepoints)
+	if _, err := t.Exec("SAVEPOINT " + name); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// RollbackTo undoes the statements executed since the savepoint
+func (t *MySQLTx) RollbackTo(savepoint string) error {
+	_, err := t.Exec("ROLLBACK TO SAVEPOINT " + savepoint)
+	return err
+}
+
+// Release keeps the changes made since the savepoint
+func (t *MySQLTx) Release(savepoint string) error {
+	_, err := t.Exec("RELEASE SAVEPOINT " + savepoint)
+	return err
+}
+
+// wrap reports statements failing because the transaction deadline passed
+func (t *MySQLTx) wrap(err error) error {
+	if err != nil && errors.Is(t.ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %v", ErrTxTimeout, err)
+	}
+	return err
+}
+
+// ParseIsolation parses isolation level names such as "read committed",
+// "READ_COMMITTED" or "repeatable-read"; "" and "default" use the server's
+func ParseIsolation(name string) (sql.IsolationLevel, error) {
+	normalized := strings.ToLower(strings.NewReplacer("_", " ", "-", " ").Replace(strings.TrimSpace(name)))
+	switch normalized {
+	case "", "default":
+		return sql.LevelDefault, nil
+	case "read uncommitted":
+		return sql.LevelReadUncommitted, nil
+	case "read committed":
+		return sql.LevelReadCommitted, nil
+	case "repeatable read":
+		return sql.LevelRepeatableRead, nil
+	case "serializable":
+		return sql.LevelSerializable, nil
+	default:
+		return sql.LevelDefault, fmt.Errorf("unknown isolation level %q", name)
+	}
+}
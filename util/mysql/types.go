@@ -0,0 +1,230 @@
+package mysql
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"main/config"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MAX_SAFE_INTEGER is the largest integer a JS number holds exactly (2^53-1)
+const MAX_SAFE_INTEGER = 1<<53 - 1
+
+const mysqlTimeLayout = "2006-01-02 15:04:05.999999"
+
+/**
+ * 列类型转换：按 rows.ColumnTypes 把驱动返回的值（文本协议为 []byte，
+ * 预处理语句为 int64 / float64 等）统一成一致的类型
+ * - 整数：int64，超出 ±(2^53-1) 时为 *big.Int（脚本中为 BigInt）或字符串
+ * - DECIMAL：字符串（保留精度）或 float64
+ * - DATE / DATETIME / TIMESTAMP：按配置时区解析的 time.Time，零值日期为 nil
+ * - JSON：解析后的对象；BIT：整数；TIME：字符串；其余文本与二进制：字符串
+ */
+
+// valueOptions controls how values are converted, set per database
+type valueOptions struct {
+	location *time.Location
+	bigInt   string
+	decimal  string
+}
+
+func newValueOptions(cfg *config.MySQLConfig) valueOptions {
+	options := valueOptions{location: time.Local, bigInt: cfg.BigInt, decimal: cfg.Decimal}
+	if cfg.Timezone != "" {
+		location, err := time.LoadLocation(cfg.Timezone)
+		if err != nil {
+			log.Printf("Warning: MySQL '%s' has an invalid timezone %q, using local time: %v", cfg.Name, cfg.Timezone, err)
+		} else {
+			options.location = location
+		}
+	}
+	return options
+}
+
+type columnConverter func(value interface{}) interface{}
+
+// converters returns the conversion of each column
+func (o valueOptions) converters(columns []*sql.ColumnType) []columnConverter {
+	result := make([]columnConverter, len(columns))
+	for i, column := range columns {
+		result[i] = o.converter(column.DatabaseTypeName())
+	}
+	return result
+}
+
+func (o valueOptions) converter(typeName string) columnConverter {
+	switch strings.TrimPrefix(typeName, "UNSIGNED ") {
+	case "TINYINT", "SMALLINT", "MEDIUMINT", "INT", "BIGINT", "YEAR":
+		return o.integer
+	case "FLOAT", "DOUBLE":
+		return float
+	case "DECIMAL":
+		return o.decimalValue
+	case "DATE", "DATETIME", "TIMESTAMP":
+		return o.temporal
+	case "JSON":
+		return jsonValue
+	case "BIT":
+		return o.bit
+	default:
+		return text
+	}
+}
+
+func text(value interface{}) interface{} {
+	switch v := value.(type) {
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format(mysqlTimeLayout)
+	default:
+		return v
+	}
+}
+
+func (o valueOptions) integer(value interface{}) interface{} {
+	switch v := value.(type) {
+	case int64:
+		return o.safeInt(v)
+	case uint64:
+		if v <= MAX_SAFE_INTEGER {
+			return int64(v)
+		}
+		return o.bigInteger(new(big.Int).SetUint64(v))
+	case []byte:
+		if i, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			return o.safeInt(i)
+		}
+		if n, ok := new(big.Int).SetString(string(v), 10); ok {
+			return o.bigInteger(n)
+		}
+		return string(v)
+	default:
+		return text(value)
+	}
+}
+
+func (o valueOptions) safeInt(v int64) interface{} {
+	if v > MAX_SAFE_INTEGER || v < -MAX_SAFE_INTEGER {
+		return o.bigInteger(big.NewInt(v))
+	}
+	return v
+}
+
+func (o valueOptions) bigInteger(n *big.Int) interface{} {
+	if o.bigInt == config.MYSQL_BIGINT_STRING {
+		return n.String()
+	}
+	return n
+}
+
+func float(value interface{}) interface{} {
+	switch v := value.(type) {
+	case float32:
+		return float64(v)
+	case []byte:
+		if f, err := strconv.ParseFloat(string(v), 64); err == nil {
+			return f
+		}
+		return string(v)
+	default:
+		return text(value)
+	}
+}
+
+func (o valueOptions) decimalValue(value interface{}) interface{} {
+	if o.decimal == config.MYSQL_DECIMAL_NUMBER {
+		return float(value)
+	}
+	return text(value)
+}
+
+func (o valueOptions) temporal(value interface{}) interface{} {
+	switch v := value.(type) {
+	case time.Time:
+		if v.IsZero() {
+			return nil
+		}
+		return v.In(o.location)
+	case []byte:
+		s := string(v)
+		if strings.HasPrefix(s, "0000-00-00") {
+			return nil
+		}
+		layout := mysqlTimeLayout
+		if len(s) == len("2006-01-02") {
+			layout = "2006-01-02"
+		}
+		if t, err := time.ParseInLocation(layout, s, o.location); err == nil {
+			return t
+		}
+		return s
+	default:
+		return text(value)
+	}
+}
+
+func jsonValue(value interface{}) interface{} {
+	data, ok := value.([]byte)
+	if !ok {
+		return text(value)
+	}
+	var result interface{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return string(data)
+	}
+	return result
+}
+
+func (o valueOptions) bit(value interface{}) interface{} {
+	data, ok := value.([]byte)
+	if !ok || len(data) > 8 {
+		return o.integer(value)
+	}
+	var n uint64
+	for _, b := range data {
+		n = n<<8 | uint64(b)
+	}
+	return o.integer(n)
+}
+
+// bindArgs converts parameters bound from scripts to values the driver
+// accepts: times are formatted in the database timezone (as they are read),
+// big integers as numbers or strings, objects and arrays as JSON
+func (o valueOptions) bindArgs(args []interface{}) ([]interface{}, error) {
+	result := args
+	copied := false
+	for i, arg := range args {
+		var value interface{}
+		switch v := arg.(type) {
+		case time.Time:
+			value = v.In(o.location).Format(mysqlTimeLayout)
+		case *big.Int:
+			if v.IsInt64() {
+				value = v.Int64()
+			} else {
+				value = v.String()
+			}
+		case map[string]interface{}, []interface{}:
+			data, err := json.Marshal(v)
+			if err != nil {
+				return nil, fmt.Errorf("parameter %d: %w", i+1, err)
+			}
+			value = string(data)
+		default:
+			continue
+		}
+		// 只在需要转换时复制参数
+		if !copied {
+			result = append([]interface{}(nil), args...)
+			copied = true
+		}
+		result[i] = value
+	}
+	return result, nil
+}
@@ -2,36 +2,75 @@ package net
 
 import (
 	"bytes"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math/rand"
+	"net"
 	"net/http"
 	"net/url"
+	"slices"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 )
 
+const (
+	// HTTP_MAX_BODY limits the response body read into memory
+	HTTP_MAX_BODY = 32 << 20
+	// HTTP_MAX_REDIRECTS is the default redirect limit
+	HTTP_MAX_REDIRECTS = 10
+	// HTTP_RETRY_BACKOFF and HTTP_RETRY_MAX_BACKOFF bound the wait between retries
+	HTTP_RETRY_BACKOFF     = 200 * time.Millisecond
+	HTTP_RETRY_MAX_BACKOFF = 10 * time.Second
+)
+
+// HTTP_RETRY_STATUS are the status codes retried by default
+var HTTP_RETRY_STATUS = []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+
 // HTTPResponse represents the response from an HTTP request
 type HTTPResponse struct {
 	StatusCode int
 	Headers    map[string][]string
-	Body       string
+	Body       []byte
+	URL        string // final URL after redirects
+	Attempts   int
 	Error      error
 }
 
-// HTTPClient is a wrapper around http.Client with additional functionality
+// Text returns the body as a string
+func (r HTTPResponse) Text() string {
+	return string(r.Body)
+}
+
+// RetryOptions retries failed requests with exponential backoff. Only
+// idempotent methods are retried unless NonIdempotent is set; a Retry-After
+// header overrides the backoff.
+type RetryOptions struct {
+	Retries       int           // retries after the first attempt, 0 = none
+	Backoff       time.Duration // first wait, doubled on every retry; 0 = HTTP_RETRY_BACKOFF
+	MaxBackoff    time.Duration // 0 = HTTP_RETRY_MAX_BACKOFF
+	StatusCodes   []int         // nil = HTTP_RETRY_STATUS; network errors and timeouts are always retried
+	NonIdempotent bool          // also retry POST and PATCH
+}
+
+// HTTPClient sends requests over shared, pooled transports
 type HTTPClient struct {
-	client *http.Client
+	timeout time.Duration
+	policy  *EgressPolicy
 }
 
-// NewHTTPClient creates a new HTTP client with the specified timeout
+// NewHTTPClient creates a new HTTP client with the specified timeout,
+// restricted by EGRESS_POLICY
 func NewHTTPClient(timeoutSeconds int) *HTTPClient {
-	timeout := time.Duration(timeoutSeconds) * time.Second
-	return &HTTPClient{
-		client: &http.Client{
-			Timeout: timeout,
-		},
-	}
+	return &HTTPClient{timeout: time.Duration(timeoutSeconds) * time.Second, policy: EGRESS_POLICY}
+}
+
+// WithPolicy replaces the egress policy of the client, nil allows everything
+func (c *HTTPClient) WithPolicy(policy *EgressPolicy) *HTTPClient {
+	c.policy = policy
+	return c
 }
 
 // DefaultHTTPClient returns a new HTTP client with a default timeout of 30 seconds
@@ -41,97 +80,202 @@ func DefaultHTTPClient() *HTTPClient {
 
 // Get performs a synchronous HTTP GET request
 func (c *HTTPClient) Get(urlStr string, params map[string]string, headers map[string]string) HTTPResponse {
-	// Add query parameters to URL if provided
-	if len(params) > 0 {
-		parsedURL, err := url.Parse(urlStr)
-		if err != nil {
-			return HTTPResponse{Error: fmt.Errorf("invalid URL: %v", err)}
-		}
-		
-		q := parsedURL.Query()
-		for k, v := range params {
-			q.Add(k, v)
-		}
-		parsedURL.RawQuery = q.Encode()
-		urlStr = parsedURL.String()
-	}
+	return c.Do(&Request{Method: http.MethodGet, URL: urlStr, Params: params, Headers: headers})
+}
+
+// Post performs a synchronous HTTP POST request
+func (c *HTTPClient) Post(urlStr string, body interface{}, headers map[string]string) HTTPResponse {
+	return c.Do(&Request{Method: http.MethodPost, URL: urlStr, Body: body, Headers: headers})
+}
 
-	// Create request
-	req, err := http.NewRequest(http.MethodGet, urlStr, nil)
+// Do performs a synchronous HTTP request, retrying as configured
+func (c *HTTPClient) Do(r *Request) HTTPResponse {
+	method := strings.ToUpper(r.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+	if !validMethod(method) {
+		return HTTPResponse{Error: fmt.Errorf("invalid HTTP method %q", r.Method)}
+	}
+	urlStr, err := r.fullURL()
 	if err != nil {
-		return HTTPResponse{Error: fmt.Errorf("error creating request: %v", err)}
+		return HTTPResponse{Error: err}
 	}
-
-	// Add headers
-	for k, v := range headers {
-		req.Header.Add(k, v)
+	parsedURL, err := url.Parse(urlStr)
+	if err != nil {
+		return HTTPResponse{Error: fmt.Errorf("invalid URL: %v", err)}
+	}
+	if err := c.policy.CheckURL(parsedURL); err != nil {
+		return HTTPResponse{Error: err}
+	}
+	body, contentType, err := r.encodeBody()
+	if err != nil {
+		return HTTPResponse{Error: err}
+	}
+	client, err := c.httpClient(r)
+	if err != nil {
+		return HTTPResponse{Error: err}
 	}
 
-	return c.doRequest(req)
-}
-
-// Post performs a synchronous HTTP POST request
-func (c *HTTPClient) Post(urlStr string, body interface{}, headers map[string]string) HTTPResponse {
-	var bodyReader io.Reader
-	var contentType string
-
-	// Process body based on type
-	switch b := body.(type) {
-	case string:
-		bodyReader = strings.NewReader(b)
-		contentType = "text/plain"
-	case []byte:
-		bodyReader = bytes.NewReader(b)
-		contentType = "application/octet-stream"
-	case nil:
-		bodyReader = nil
-	default:
-		// Try to marshal as JSON
-		jsonData, err := json.Marshal(body)
+	retries := r.Retry.Retries
+	if !r.Retry.NonIdempotent && (method == http.MethodPost || method == http.MethodPatch) {
+		retries = 0
+	}
+	var response HTTPResponse
+	for attempt := 0; ; attempt++ {
+		var bodyReader io.Reader
+		if body != nil {
+			bodyReader = bytes.NewReader(body)
+		}
+		req, err := http.NewRequest(method, urlStr, bodyReader)
 		if err != nil {
-			return HTTPResponse{Error: fmt.Errorf("error marshaling JSON body: %v", err)}
+			return HTTPResponse{Error: fmt.Errorf("error creating request: %v", err)}
+		}
+		// Set default content type if not overridden in headers
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		for k, v := range r.Headers {
+			req.Header.Set(k, v)
+		}
+
+		response = c.doRequest(client, req, r.MaxBytes)
+		response.Attempts = attempt + 1
+		if attempt >= retries || !r.Retry.retryable(response) {
+			return response
 		}
-		bodyReader = bytes.NewReader(jsonData)
-		contentType = "application/json"
+		time.Sleep(r.Retry.wait(attempt, response))
 	}
+}
 
-	// Create request
-	req, err := http.NewRequest(http.MethodPost, urlStr, bodyReader)
+// httpClient builds the client of a request on the shared transport
+func (c *HTTPClient) httpClient(r *Request) (*http.Client, error) {
+	transport, err := transportFor(r.TLS, c.policy)
 	if err != nil {
-		return HTTPResponse{Error: fmt.Errorf("error creating request: %v", err)}
+		return nil, err
 	}
-
-	// Set default content type if not overridden in headers
-	if contentType != "" && headers["Content-Type"] == "" {
-		req.Header.Set("Content-Type", contentType)
+	timeout := c.timeout
+	if r.Timeout > 0 {
+		timeout = r.Timeout
 	}
-
-	// Add headers
-	for k, v := range headers {
-		req.Header.Add(k, v)
+	maxRedirects := r.MaxRedirects
+	if maxRedirects <= 0 {
+		maxRedirects = HTTP_MAX_REDIRECTS
 	}
 
-	return c.doRequest(req)
+	return &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			switch r.Redirect {
+			case REDIRECT_MANUAL:
+				return http.ErrUseLastResponse
+			case REDIRECT_ERROR:
+				return fmt.Errorf("redirected to %s", req.URL)
+			}
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			return c.policy.CheckURL(req.URL)
+		},
+	}, nil
 }
 
 // doRequest executes the HTTP request and returns a response
-func (c *HTTPClient) doRequest(req *http.Request) HTTPResponse {
-	resp, err := c.client.Do(req)
+func (c *HTTPClient) doRequest(client *http.Client, req *http.Request, maxBytes int64) HTTPResponse {
+	resp, err := client.Do(req)
 	if err != nil {
-		return HTTPResponse{Error: fmt.Errorf("request failed: %v", err)}
+		return HTTPResponse{Error: fmt.Errorf("request failed: %w", err)}
 	}
 	defer resp.Body.Close()
 
 	// Read response body
-	body, err := io.ReadAll(resp.Body)
+	if maxBytes <= 0 {
+		maxBytes = HTTP_MAX_BODY
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes+1))
+	if err == nil && int64(len(body)) > maxBytes {
+		err = fmt.Errorf("body larger than %d bytes", maxBytes)
+	}
 	if err != nil {
-		return HTTPResponse{Error: fmt.Errorf("error reading response body: %v", err)}
+		return HTTPResponse{StatusCode: resp.StatusCode, Headers: resp.Header, Error: fmt.Errorf("error reading response body: %v", err)}
 	}
 
 	return HTTPResponse{
 		StatusCode: resp.StatusCode,
 		Headers:    resp.Header,
-		Body:       string(body),
+		Body:       body,
+		URL:        resp.Request.URL.String(),
 		Error:      nil,
 	}
 }
+
+// retryable reports whether a response is worth another attempt
+func (o RetryOptions) retryable(response HTTPResponse) bool {
+	if response.Error != nil {
+		return response.StatusCode == 0 && transient(response.Error)
+	}
+	codes := o.StatusCodes
+	if codes == nil {
+		codes = HTTP_RETRY_STATUS
+	}
+	return slices.Contains(codes, response.StatusCode)
+}
+
+// transient reports whether a request error is a network failure or timeout
+// worth retrying; redirect, TLS verification and egress errors are not
+func transient(err error) bool {
+	if errors.Is(err, ErrEgressDenied) {
+		return false
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) || errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+	// url.Error 自身实现了 net.Error，需要看其内部的错误
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return !dnsErr.IsNotFound
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// wait returns the delay before the retry after attempt: exponential
+// backoff with jitter, or the Retry-After of the response
+func (o RetryOptions) wait(attempt int, response HTTPResponse) time.Duration {
+	backoff, maxBackoff := o.Backoff, o.MaxBackoff
+	if backoff <= 0 {
+		backoff = HTTP_RETRY_BACKOFF
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = HTTP_RETRY_MAX_BACKOFF
+	}
+
+	if values := response.Headers["Retry-After"]; len(values) > 0 {
+		if seconds, err := strconv.Atoi(values[0]); err == nil && seconds >= 0 {
+			return min(time.Duration(seconds)*time.Second, maxBackoff)
+		}
+		if t, err := http.ParseTime(values[0]); err == nil {
+			return min(max(time.Until(t), 0), maxBackoff)
+		}
+	}
+	wait := backoff << attempt
+	if wait <= 0 || wait > maxBackoff {
+		wait = maxBackoff
+	}
+	// ±20% 抖动，避免多个节点同时重试
+	return wait*4/5 + time.Duration(rand.Int63n(int64(wait*2/5)+1))
+}
+
+func validMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,304 @@
+package net
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"main/config"
+	"net"
+	"net/netip"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/puzpuzpuz/xsync/v4"
+)
+
+// ErrEgressDenied is returned for requests to destinations the egress
+// policy does not allow
+var ErrEgressDenied = errors.New("egress denied")
+
+// 默认拦截的保留地址段（回环、内网、链路本地等由 netip 判断）
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // CGNAT
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"), // NAT64
+}
+
+// EGRESS_POLICY applies to every HTTPClient; nil allows everything
+var EGRESS_POLICY *EgressPolicy
+
+// Initialize sets EGRESS_POLICY and CERT_DIR from the configuration. An
+// invalid egress configuration falls back to the default policy rather
+// than none.
+func Initialize(netConfig *config.NetConfig) error {
+	CERT_DIR = netConfig.CertDir
+	cfg := &netConfig.Egress
+	if !cfg.Enable {
+		EGRESS_POLICY = nil
+		log.Printf("Egress policy is disabled, scripts may reach any address")
+		return nil
+	}
+	policy, err := NewEgressPolicy(cfg)
+	if err != nil {
+		EGRESS_POLICY, _ = NewEgressPolicy(&config.EgressConfig{Enable: true})
+		return err
+	}
+	EGRESS_POLICY = policy
+	return nil
+}
+
+// egressRule matches a host name (exact or *.suffix) or an address prefix,
+// on one port or any (0)
+type egressRule struct {
+	host   string
+	prefix netip.Prefix
+	port   int
+}
+
+// EgressPolicy decides which destinations requests may connect to. Deny
+// rules always win; private addresses need allowPrivate or a matching
+// allow rule; a non-empty allow list permits nothing else.
+type EgressPolicy struct {
+	allow        []egressRule
+	deny         []egressRule
+	allowPrivate bool
+	ports        []int
+	allowlist    bool // allow rules came from the configuration, not only capabilities
+
+	capabilities map[string][]egressRule
+	derived      *xsync.Map[string, *EgressPolicy]
+}
+
+func NewEgressPolicy(cfg *config.EgressConfig) (*EgressPolicy, error) {
+	p := &EgressPolicy{
+		allowPrivate: cfg.AllowPrivate,
+		ports:        cfg.Ports,
+		allowlist:    len(cfg.Allow) > 0,
+		capabilities: make(map[string][]egressRule),
+		derived:      xsync.NewMap[string, *EgressPolicy](),
+	}
+	var err error
+	if p.allow, err = parseRules(cfg.Allow); err != nil {
+		return nil, fmt.Errorf("egress allow: %w", err)
+	}
+	if p.deny, err = parseRules(cfg.Deny); err != nil {
+		return nil, fmt.Errorf("egress deny: %w", err)
+	}
+	for name, rules := range cfg.Capabilities {
+		if p.capabilities[name], err = parseRules(rules); err != nil {
+			return nil, fmt.Errorf("egress capability %s: %w", name, err)
+		}
+	}
+	return p, nil
+}
+
+func parseRules(entries []string) ([]egressRule, error) {
+	rules := make([]egressRule, 0, len(entries))
+	for _, entry := range entries {
+		rule, err := parseRule(entry)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// parseRule parses host, *.domain, IP or CIDR, each with an optional :port
+func parseRule(entry string) (egressRule, error) {
+	var rule egressRule
+	target := strings.ToLower(strings.TrimSpace(entry))
+	if host, port, err := net.SplitHostPort(target); err == nil {
+		if rule.port, err = strconv.Atoi(port); err != nil || rule.port <= 0 || rule.port > 65535 {
+			return rule, fmt.Errorf("invalid port in %q", entry)
+		}
+		target = host
+	}
+	if target == "" {
+		return rule, fmt.Errorf("empty rule %q", entry)
+	}
+
+	if prefix, err := netip.ParsePrefix(target); err == nil {
+		rule.prefix = prefix.Masked()
+	} else if addr, err := netip.ParseAddr(target); err == nil {
+		rule.prefix = netip.PrefixFrom(addr, addr.BitLen())
+	} else if strings.Contains(target, "/") {
+		return rule, fmt.Errorf("invalid CIDR %q", entry)
+	} else {
+		rule.host = strings.TrimSuffix(target, ".")
+	}
+	return rule, nil
+}
+
+func (r egressRule) matchHost(host string, port int) bool {
+	if r.host == "" || (r.port != 0 && r.port != port) {
+		return false
+	}
+	if suffix, ok := strings.CutPrefix(r.host, "*."); ok {
+		return strings.HasSuffix(host, "."+suffix)
+	}
+	return host == r.host
+}
+
+func (r egressRule) matchAddr(addr netip.Addr, port int) bool {
+	return r.prefix.IsValid() && (r.port == 0 || r.port == port) && r.prefix.Contains(addr)
+}
+
+// ForCapabilities returns the policy extended by the allow rules of the
+// named capabilities; names that are not configured are ignored
+func (p *EgressPolicy) ForCapabilities(names []string) *EgressPolicy {
+	if p == nil || len(names) == 0 {
+		return p
+	}
+	sorted := slices.Clone(names)
+	sort.Strings(sorted)
+	key := strings.Join(sorted, ",")
+	if derived, ok := p.derived.Load(key); ok {
+		return derived
+	}
+
+	extended := *p
+	extended.allow = slices.Clone(p.allow)
+	granted := false
+	for _, name := range sorted {
+		if rules, ok := p.capabilities[name]; ok {
+			extended.allow = append(extended.allow, rules...)
+			granted = true
+		}
+	}
+	if !granted {
+		return p
+	}
+	actual, _ := p.derived.LoadOrStore(key, &extended)
+	return actual
+}
+
+// CheckURL checks the scheme and host of a request URL before any
+// connection is made. IP checks happen again when dialing.
+func (p *EgressPolicy) CheckURL(u *url.URL) error {
+	if p == nil {
+		return nil
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: scheme %q", ErrEgressDenied, u.Scheme)
+	}
+	port, _ := strconv.Atoi(u.Port())
+	if port == 0 {
+		port = 80
+		if u.Scheme == "https" {
+			port = 443
+		}
+	}
+	host := u.Hostname()
+	explicit, err := p.checkHost(host, port)
+	if err != nil {
+		return err
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return p.checkAddr(addr, port, explicit)
+	}
+	return nil
+}
+
+// checkHost applies the name rules; explicit reports an allow rule matching
+// the name, which also permits private addresses it resolves to
+func (p *EgressPolicy) checkHost(host string, port int) (explicit bool, err error) {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	for _, rule := range p.deny {
+		if rule.matchHost(host, port) {
+			return false, fmt.Errorf("%w: %s:%d is on the deny list", ErrEgressDenied, host, port)
+		}
+	}
+	for _, rule := range p.allow {
+		if rule.matchHost(host, port) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// checkAddr applies the address rules to the address being connected to
+func (p *EgressPolicy) checkAddr(addr netip.Addr, port int, explicit bool) error {
+	addr = addr.Unmap()
+	for _, rule := range p.deny {
+		if rule.matchAddr(addr, port) {
+			return fmt.Errorf("%w: %s:%d is on the deny list", ErrEgressDenied, addr, port)
+		}
+	}
+	if !explicit {
+		for _, rule := range p.allow {
+			if rule.matchAddr(addr, port) {
+				explicit = true
+				break
+			}
+		}
+	}
+	if explicit {
+		return nil
+	}
+
+	switch {
+	case p.allowlist:
+		return fmt.Errorf("%w: %s:%d is not on the allow list", ErrEgressDenied, addr, port)
+	case !p.allowPrivate && isPrivate(addr):
+		return fmt.Errorf("%w: %s is a private or reserved address", ErrEgressDenied, addr)
+	case len(p.ports) > 0 && !slices.Contains(p.ports, port):
+		return fmt.Errorf("%w: port %d is not allowed", ErrEgressDenied, port)
+	}
+	return nil
+}
+
+func isPrivate(addr netip.Addr) bool {
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() || addr.IsUnspecified() {
+		return true
+	}
+	if addr.Is4() && addr == netip.AddrFrom4([4]byte{255, 255, 255, 255}) {
+		return true
+	}
+	for _, prefix := range reservedPrefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// dialContext checks the host name, then every address actually connected
+// to, so a name cannot resolve to a checked address and then rebind to an
+// internal one
+func (p *EgressPolicy) dialContext(dialer *net.Dialer) func(ctx context.Context, network, address string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, portStr, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		port, _ := strconv.Atoi(portStr)
+		explicit, err := p.checkHost(host, port)
+		if err != nil {
+			return nil, err
+		}
+
+		d := *dialer
+		d.Control = func(network, address string, _ syscall.RawConn) error {
+			ip, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			addr, err := netip.ParseAddr(ip)
+			if err != nil {
+				return fmt.Errorf("%w: unexpected address %s", ErrEgressDenied, address)
+			}
+			return p.checkAddr(addr, port, explicit)
+		}
+		return d.DialContext(ctx, network, address)
+	}
+}
@@ -0,0 +1,127 @@
+package net
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Request describes an HTTP request. At most one of Body, Form and
+// Multipart is used, in that order.
+type Request struct {
+	Method       string
+	URL          string
+	Params       map[string]string // added to the query string
+	Headers      map[string]string
+	Body         interface{}         // string, []byte, or anything else sent as JSON
+	Form         map[string][]string // application/x-www-form-urlencoded
+	Multipart    *Multipart          // multipart/form-data
+	Timeout      time.Duration       // per attempt, 0 = the client's timeout
+	Redirect     string              // REDIRECT_FOLLOW (default), REDIRECT_MANUAL or REDIRECT_ERROR
+	MaxRedirects int                 // 0 = 10
+	TLS          TLSOptions
+	Retry        RetryOptions
+	MaxBytes     int64 // response body limit, 0 = HTTP_MAX_BODY
+}
+
+const (
+	REDIRECT_FOLLOW = "follow" // follow up to MaxRedirects redirects
+	REDIRECT_MANUAL = "manual" // return the 3xx response
+	REDIRECT_ERROR  = "error"  // fail on a redirect
+)
+
+// Multipart is a multipart/form-data body
+type Multipart struct {
+	Fields map[string]string
+	Files  []MultipartFile
+}
+
+// MultipartFile is a file part of a multipart body
+type MultipartFile struct {
+	Field       string
+	Filename    string
+	ContentType string // default application/octet-stream
+	Data        []byte
+}
+
+// encodeBody returns the request body and its default content type
+func (r *Request) encodeBody() ([]byte, string, error) {
+	switch {
+	case r.Body != nil:
+		switch b := r.Body.(type) {
+		case string:
+			return []byte(b), "text/plain; charset=utf-8", nil
+		case []byte:
+			return b, "application/octet-stream", nil
+		default:
+			data, err := json.Marshal(b)
+			if err != nil {
+				return nil, "", fmt.Errorf("error marshaling JSON body: %v", err)
+			}
+			return data, "application/json", nil
+		}
+	case r.Form != nil:
+		return []byte(url.Values(r.Form).Encode()), "application/x-www-form-urlencoded", nil
+	case r.Multipart != nil:
+		return r.Multipart.encode()
+	}
+	return nil, "", nil
+}
+
+func (m *Multipart) encode() ([]byte, string, error) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	for name, value := range m.Fields {
+		if err := writer.WriteField(name, value); err != nil {
+			return nil, "", err
+		}
+	}
+	for _, file := range m.Files {
+		contentType := file.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, escapeQuotes(file.Field), escapeQuotes(file.Filename)))
+		header.Set("Content-Type", contentType)
+		part, err := writer.CreatePart(header)
+		if err != nil {
+			return nil, "", err
+		}
+		if _, err := part.Write(file.Data); err != nil {
+			return nil, "", err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), writer.FormDataContentType(), nil
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
+
+// fullURL returns the URL with Params added to its query string
+func (r *Request) fullURL() (string, error) {
+	if len(r.Params) == 0 {
+		return r.URL, nil
+	}
+	parsedURL, err := url.Parse(r.URL)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL: %v", err)
+	}
+	q := parsedURL.Query()
+	for k, v := range r.Params {
+		q.Add(k, v)
+	}
+	parsedURL.RawQuery = q.Encode()
+	return parsedURL.String(), nil
+}
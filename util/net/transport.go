@@ -0,0 +1,161 @@
+package net
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/puzpuzpuz/xsync/v4"
+)
+
+// TRANSPORT_CACHE_SIZE bounds the shared transports; the least recently
+// used one is evicted beyond it
+const TRANSPORT_CACHE_SIZE = 32
+
+// ErrCertFile is returned for certificate files outside CERT_DIR
+var ErrCertFile = errors.New("certificate file not allowed")
+
+// CERT_DIR is the only directory TLS options may read certificate files
+// from; empty disables certificate files
+var CERT_DIR string
+
+// TLSOptions configures the TLS connections of a request; requests with the
+// same options share a transport. Files are relative to CERT_DIR.
+type TLSOptions struct {
+	InsecureSkipVerify bool
+	CAFile             string // PEM file of a private CA
+	CertFile           string // client certificate for mutual TLS
+	KeyFile            string
+	ServerName         string
+}
+
+type transportKey struct {
+	tls    TLSOptions
+	policy *EgressPolicy
+}
+
+type cachedTransport struct {
+	transport *http.Transport
+	lastUsed  atomic.Int64
+}
+
+// 按 TLS 选项和出站策略缓存的共享 Transport，复用连接
+var transports = xsync.NewMap[transportKey, *cachedTransport]()
+
+// transportFor returns the shared transport for the TLS options, dialing
+// through the egress policy when there is one
+func transportFor(options TLSOptions, policy *EgressPolicy) (*http.Transport, error) {
+	options, err := resolveCertFiles(options)
+	if err != nil {
+		return nil, err
+	}
+	key := transportKey{options, policy}
+	if cached, ok := transports.Load(key); ok {
+		cached.lastUsed.Store(time.Now().UnixNano())
+		return cached.transport, nil
+	}
+
+	tlsConfig, err := newTLSConfig(options)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	// 出站策略在拨号时检查实际连接的地址，经过代理时检查的只是代理本身，
+	// 因此启用策略时不使用环境变量中的代理
+	proxy, dialContext := http.ProxyFromEnvironment, dialer.DialContext
+	if policy != nil {
+		proxy, dialContext = nil, policy.dialContext(dialer)
+	}
+	cached := &cachedTransport{transport: &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dialContext,
+		TLSClientConfig:       tlsConfig,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   10,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}}
+	cached.lastUsed.Store(time.Now().UnixNano())
+	actual, loaded := transports.LoadOrStore(key, cached)
+	if !loaded {
+		evictTransports()
+	}
+	return actual.transport, nil
+}
+
+// evictTransports drops the least recently used transports beyond
+// TRANSPORT_CACHE_SIZE; requests still using them finish normally
+func evictTransports() {
+	for transports.Size() > TRANSPORT_CACHE_SIZE {
+		var oldestKey transportKey
+		var oldest *cachedTransport
+		transports.Range(func(key transportKey, cached *cachedTransport) bool {
+			if oldest == nil || cached.lastUsed.Load() < oldest.lastUsed.Load() {
+				oldestKey, oldest = key, cached
+			}
+			return true
+		})
+		if oldest == nil {
+			return
+		}
+		transports.Delete(oldestKey)
+		oldest.transport.CloseIdleConnections()
+	}
+}
+
+// resolveCertFiles maps the certificate files of the options into CERT_DIR;
+// absolute paths and paths leaving the directory are rejected
+func resolveCertFiles(options TLSOptions) (TLSOptions, error) {
+	for _, file := range []*string{&options.CAFile, &options.CertFile, &options.KeyFile} {
+		if *file == "" {
+			continue
+		}
+		if CERT_DIR == "" {
+			return options, fmt.Errorf("%w: %s, no certificate directory is configured", ErrCertFile, *file)
+		}
+		if !filepath.IsLocal(*file) {
+			return options, fmt.Errorf("%w: %s is not a relative path inside the certificate directory", ErrCertFile, *file)
+		}
+		*file = filepath.Join(CERT_DIR, *file)
+	}
+	return options, nil
+}
+
+func newTLSConfig(options TLSOptions) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         options.ServerName,
+		InsecureSkipVerify: options.InsecureSkipVerify,
+	}
+	if options.CAFile != "" {
+		pem, err := os.ReadFile(options.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in CA file %s", options.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if options.CertFile != "" || options.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(options.CertFile, options.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
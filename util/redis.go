@@ -2,7 +2,9 @@ package util
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"time"
 
 	"main/config"
 
@@ -12,33 +14,52 @@ import (
 const DEFAULT_HSET_GROUP = "gotask"
 
 type RedisClient struct {
-	Client *redis.Client
+	Client redis.UniversalClient
 }
 
 var (
 	ctx         = context.Background()
-	RedisData   *RedisClient
-	RedisConfig *RedisClient
+	RedisData   KVStore // 业务数据
+	RedisConfig KVStore // 脚本与配置
 )
 
-func CreateRedisConn(db int) (*redis.Client, error) {
-	// Initialize Redis client
-	addr := config.CONFIG.Database.Redis.Addr
+// CreateRedisConn connects to the configured standalone server, Sentinel
+// master or cluster. Clusters only have db 0, db is ignored there.
+func CreateRedisConn(db int) (redis.UniversalClient, error) {
+	cfg := &config.CONFIG.Database.Redis
+	options, err := redisOptions(cfg, db)
+	if err != nil {
+		return nil, err
+	}
 
-	client := redis.NewClient(&redis.Options{
-		Addr:     addr,
-		Password: "", // no password set
-		DB:       db, // use default DB
-	})
+	var client redis.UniversalClient
+	mode := redisMode(cfg)
+	switch mode {
+	case config.REDIS_MODE_SENTINEL:
+		if options.MasterName == "" {
+			return nil, fmt.Errorf("redis sentinel mode requires masterName")
+		}
+		client = redis.NewFailoverClient(options.Failover())
+	case config.REDIS_MODE_CLUSTER:
+		if db != 0 {
+			log.Printf("Warning: Redis cluster only supports db 0, db %d is ignored", db)
+		}
+		client = redis.NewClusterClient(options.Cluster())
+	case config.REDIS_MODE_STANDALONE:
+		client = redis.NewClient(options.Simple())
+	default:
+		return nil, fmt.Errorf("unknown redis mode %q", cfg.Mode)
+	}
 
 	// Test Redis connection
-	_, err := client.Ping(ctx).Result()
+	_, err = client.Ping(ctx).Result()
 	if err != nil {
+		client.Close()
 		log.Printf("Warning: Could not connect to Redis: %v", err)
-		log.Printf("Redis operations will fail. Please ensure Redis is running on localhost:6379")
+		log.Printf("Redis operations will fail. Please ensure Redis is running on %v", options.Addrs)
 		return nil, err
 	} else {
-		log.Printf("Successfully connected to Redis %s, db %d\n", addr, db)
+		log.Printf("Successfully connected to Redis %v (%s), db %d\n", options.Addrs, mode, db)
 	}
 	return client, nil
 }
@@ -145,3 +166,259 @@ func (rc *RedisClient) SMembers(key string) ([]string, error) {
 	}
 	return result, nil
 }
+
+// ErrNil is returned when a key or field does not exist
+var ErrNil = redis.Nil
+
+// ZMember is a sorted set member with its score
+type ZMember struct {
+	Member string  `json:"member"`
+	Score  float64 `json:"score"`
+}
+
+// SetArgs sets a string key; ttl 0 keeps the key forever, nx only sets it if
+// it does not exist. Returns false if nx prevented the write.
+func (rc *RedisClient) SetArgs(key string, value string, ttl time.Duration, nx bool) (bool, error) {
+	args := redis.SetArgs{TTL: ttl}
+	if nx {
+		args.Mode = "NX"
+	}
+	err := rc.Client.SetArgs(ctx, key, value, args).Err()
+	if err == redis.Nil {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (rc *RedisClient) Del(keys ...string) (int64, error) {
+	return rc.Client.Del(ctx, keys...).Result()
+}
+
+func (rc *RedisClient) Exists(keys ...string) (int64, error) {
+	return rc.Client.Exists(ctx, keys...).Result()
+}
+
+func (rc *RedisClient) Expire(key string, ttl time.Duration) (bool, error) {
+	return rc.Client.Expire(ctx, key, ttl).Result()
+}
+
+func (rc *RedisClient) Persist(key string) (bool, error) {
+	return rc.Client.Persist(ctx, key).Result()
+}
+
+// TTL returns the remaining time to live, -1 for keys without expiry and -2
+// for missing keys (in seconds, as Redis does)
+func (rc *RedisClient) TTL(key string) (time.Duration, error) {
+	return rc.Client.TTL(ctx, key).Result()
+}
+
+func (rc *RedisClient) IncrBy(key string, value int64) (int64, error) {
+	return rc.Client.IncrBy(ctx, key, value).Result()
+}
+
+func (rc *RedisClient) IncrByFloat(key string, value float64) (float64, error) {
+	return rc.Client.IncrByFloat(ctx, key, value).Result()
+}
+
+func (rc *RedisClient) HGetAll(key string) (map[string]string, error) {
+	return rc.Client.HGetAll(ctx, key).Result()
+}
+
+// HMGet returns the values of the fields, nil for missing fields
+func (rc *RedisClient) HMGet(key string, fields ...string) ([]interface{}, error) {
+	return rc.Client.HMGet(ctx, key, fields...).Result()
+}
+
+func (rc *RedisClient) HMSet(key string, values map[string]interface{}) error {
+	return rc.Client.HSet(ctx, key, values).Err()
+}
+
+func (rc *RedisClient) HDelFields(key string, fields ...string) (int64, error) {
+	return rc.Client.HDel(ctx, key, fields...).Result()
+}
+
+func (rc *RedisClient) HExists(key string, field string) (bool, error) {
+	return rc.Client.HExists(ctx, key, field).Result()
+}
+
+func (rc *RedisClient) HIncrBy(key string, field string, value int64) (int64, error) {
+	return rc.Client.HIncrBy(ctx, key, field, value).Result()
+}
+
+func (rc *RedisClient) LPush(key string, values ...interface{}) (int64, error) {
+	return rc.Client.LPush(ctx, key, values...).Result()
+}
+
+func (rc *RedisClient) RPush(key string, values ...interface{}) (int64, error) {
+	return rc.Client.RPush(ctx, key, values...).Result()
+}
+
+func (rc *RedisClient) LPop(key string) (string, error) {
+	return rc.Client.LPop(ctx, key).Result()
+}
+
+func (rc *RedisClient) RPop(key string) (string, error) {
+	return rc.Client.RPop(ctx, key).Result()
+}
+
+func (rc *RedisClient) LRange(key string, start, stop int64) ([]string, error) {
+	return rc.Client.LRange(ctx, key, start, stop).Result()
+}
+
+func (rc *RedisClient) LLen(key string) (int64, error) {
+	return rc.Client.LLen(ctx, key).Result()
+}
+
+func (rc *RedisClient) LTrim(key string, start, stop int64) error {
+	return rc.Client.LTrim(ctx, key, start, stop).Err()
+}
+
+func (rc *RedisClient) ZAdd(key string, members ...ZMember) (int64, error) {
+	zs := make([]redis.Z, len(members))
+	for i, m := range members {
+		zs[i] = redis.Z{Score: m.Score, Member: m.Member}
+	}
+	return rc.Client.ZAdd(ctx, key, zs...).Result()
+}
+
+func (rc *RedisClient) ZRem(key string, members ...interface{}) (int64, error) {
+	return rc.Client.ZRem(ctx, key, members...).Result()
+}
+
+func (rc *RedisClient) ZScore(key string, member string) (float64, error) {
+	return rc.Client.ZScore(ctx, key, member).Result()
+}
+
+func (rc *RedisClient) ZIncrBy(key string, increment float64, member string) (float64, error) {
+	return rc.Client.ZIncrBy(ctx, key, increment, member).Result()
+}
+
+func (rc *RedisClient) ZCard(key string) (int64, error) {
+	return rc.Client.ZCard(ctx, key).Result()
+}
+
+// ZRange returns members by rank, highest score first if rev
+func (rc *RedisClient) ZRange(key string, start, stop int64, rev bool) ([]ZMember, error) {
+	var zs []redis.Z
+	var err error
+	if rev {
+		zs, err = rc.Client.ZRevRangeWithScores(ctx, key, start, stop).Result()
+	} else {
+		zs, err = rc.Client.ZRangeWithScores(ctx, key, start, stop).Result()
+	}
+	return toZMembers(zs), err
+}
+
+// ZRangeByScore returns members with min <= score <= max ("-inf", "+inf" and
+// "(" exclusive bounds are supported); count 0 returns all
+func (rc *RedisClient) ZRangeByScore(key string, min, max string, offset, count int64) ([]ZMember, error) {
+	by := &redis.ZRangeBy{Min: min, Max: max, Offset: offset, Count: count}
+	if count == 0 {
+		by.Offset, by.Count = 0, 0
+	}
+	zs, err := rc.Client.ZRangeByScoreWithScores(ctx, key, by).Result()
+	return toZMembers(zs), err
+}
+
+func (rc *RedisClient) ZRemRangeByScore(key string, min, max string) (int64, error) {
+	return rc.Client.ZRemRangeByScore(ctx, key, min, max).Result()
+}
+
+func toZMembers(zs []redis.Z) []ZMember {
+	members := make([]ZMember, len(zs))
+	for i, z := range zs {
+		members[i] = ZMember{Member: fmt.Sprint(z.Member), Score: z.Score}
+	}
+	return members
+}
+
+// Scan returns one page of keys matching the pattern; keyType filters by
+// type (string, hash, list, set, zset) when not empty. Use ScanAll for clusters.
+func (rc *RedisClient) Scan(cursor uint64, match string, count int64, keyType string) ([]string, uint64, error) {
+	if _, ok := rc.Client.(*redis.ClusterClient); ok {
+		return nil, 0, fmt.Errorf("cursor scan is not supported in cluster mode")
+	}
+	if keyType != "" {
+		return rc.Client.ScanType(ctx, cursor, match, count, keyType).Result()
+	}
+	return rc.Client.Scan(ctx, cursor, match, count).Result()
+}
+
+func (rc *RedisClient) Eval(script string, keys []string, args ...interface{}) (interface{}, error) {
+	return rc.Client.Eval(ctx, script, keys, args...).Result()
+}
+
+func (rc *RedisClient) Publish(channel string, message interface{}) (int64, error) {
+	return rc.Client.Publish(ctx, channel, message).Result()
+}
+
+// Do runs a raw command, e.g. Do("GETRANGE", "key", 0, 3)
+func (rc *RedisClient) Do(args ...interface{}) (interface{}, error) {
+	return rc.Client.Do(ctx, args...).Result()
+}
+
+// ExecBatch sends raw commands in one round trip, wrapped in MULTI/EXEC if
+// transaction is set. Each result is the reply or the error of its command.
+func (rc *RedisClient) ExecBatch(commands [][]interface{}, transaction bool) ([]interface{}, error) {
+	var pipe redis.Pipeliner
+	if transaction {
+		pipe = rc.Client.TxPipeline()
+	} else {
+		pipe = rc.Client.Pipeline()
+	}
+	cmds := make([]*redis.Cmd, len(commands))
+	for i, args := range commands {
+		cmds[i] = pipe.Do(ctx, args...)
+	}
+	_, err := pipe.Exec(ctx)
+	return batchResults(cmds, err)
+}
+
+// Watch runs fn with the keys watched and executes the commands it returns
+// in MULTI/EXEC. If a watched key changed in between, nothing is executed and
+// ErrTxFailed is returned.
+func (rc *RedisClient) Watch(keys []string, fn func() ([][]interface{}, error)) ([]interface{}, error) {
+	var results []interface{}
+	err := rc.Client.Watch(ctx, func(tx *redis.Tx) error {
+		commands, err := fn()
+		if err != nil || len(commands) == 0 {
+			return err
+		}
+		cmds := make([]*redis.Cmd, len(commands))
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			for i, args := range commands {
+				cmds[i] = pipe.Do(ctx, args...)
+			}
+			return nil
+		})
+		results, err = batchResults(cmds, err)
+		return err
+	}, keys...)
+	return results, err
+}
+
+// batchResults collects the replies of a pipeline
+func batchResults(cmds []*redis.Cmd, err error) ([]interface{}, error) {
+	if err == redis.TxFailedErr {
+		return nil, ErrTxFailed
+	}
+	results := make([]interface{}, len(cmds))
+	failed := false
+	for i, cmd := range cmds {
+		if value, cerr := cmd.Result(); cerr == nil {
+			results[i] = value
+		} else if cerr != redis.Nil {
+			results[i] = cerr
+			failed = true
+		}
+	}
+	// 连接失败等错误不会记录在命令上
+	if err != nil && err != redis.Nil && !failed {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (rc *RedisClient) Close() error {
+	return rc.Client.Close()
+}
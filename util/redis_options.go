@@ -0,0 +1,170 @@
+package util
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"main/config"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisMode returns the configured mode; Sentinel is implied by masterName
+func redisMode(cfg *config.RedisConfig) string {
+	if cfg.Mode != "" {
+		return strings.ToLower(cfg.Mode)
+	}
+	if cfg.MasterName != "" {
+		return config.REDIS_MODE_SENTINEL
+	}
+	return config.REDIS_MODE_STANDALONE
+}
+
+func redisOptions(cfg *config.RedisConfig, db int) (*redis.UniversalOptions, error) {
+	options := &redis.UniversalOptions{
+		Addrs:            redisAddrs(cfg),
+		DB:               db,
+		Username:         cfg.Username,
+		Password:         cfg.Password,
+		MasterName:       cfg.MasterName,
+		SentinelUsername: cfg.SentinelUsername,
+		SentinelPassword: cfg.SentinelPassword,
+		PoolSize:         cfg.PoolSize,
+		MinIdleConns:     cfg.MinIdleConns,
+		MaxRetries:       cfg.MaxRetries,
+	}
+	if len(options.Addrs) == 0 {
+		return nil, fmt.Errorf("no redis address configured")
+	}
+
+	timeouts := []struct {
+		name   string
+		value  string
+		target *time.Duration
+	}{
+		{"dialTimeout", cfg.DialTimeout, &options.DialTimeout},
+		{"readTimeout", cfg.ReadTimeout, &options.ReadTimeout},
+		{"writeTimeout", cfg.WriteTimeout, &options.WriteTimeout},
+		{"poolTimeout", cfg.PoolTimeout, &options.PoolTimeout},
+	}
+	for _, timeout := range timeouts {
+		if timeout.value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(timeout.value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redis %s %q: %w", timeout.name, timeout.value, err)
+		}
+		*timeout.target = d
+	}
+
+	if cfg.TLS.Enable {
+		tlsConfig, err := redisTLSConfig(&cfg.TLS)
+		if err != nil {
+			return nil, err
+		}
+		options.TLSConfig = tlsConfig
+	}
+	return options, nil
+}
+
+// redisAddrs merges addr and addrs, dropping duplicates
+func redisAddrs(cfg *config.RedisConfig) []string {
+	addrs := make([]string, 0, len(cfg.Addrs)+1)
+	seen := make(map[string]bool)
+	for _, addr := range append([]string{cfg.Addr}, cfg.Addrs...) {
+		addr = strings.TrimSpace(addr)
+		if addr == "" || seen[addr] {
+			continue
+		}
+		seen[addr] = true
+		addrs = append(addrs, addr)
+	}
+	return addrs
+}
+
+func redisTLSConfig(cfg *config.RedisTLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read redis CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in redis CA file %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load redis client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// ScanAll calls fn for every key matching the pattern, on every master of a
+// cluster. Returning false from fn stops the scan.
+func (rc *RedisClient) ScanAll(match string, count int64, keyType string, fn func(key string) bool) error {
+	cluster, ok := rc.Client.(*redis.ClusterClient)
+	if !ok {
+		return scanNode(redisScanner(rc.Client, match, count, keyType), fn)
+	}
+
+	// 各主节点并发扫描，回调串行执行
+	var mu sync.Mutex
+	stopped := false
+	return cluster.ForEachMaster(ctx, func(_ context.Context, node *redis.Client) error {
+		return scanNode(redisScanner(node, match, count, keyType), func(key string) bool {
+			mu.Lock()
+			defer mu.Unlock()
+			if !stopped && !fn(key) {
+				stopped = true
+			}
+			return !stopped
+		})
+	})
+}
+
+type scanFunc func(cursor uint64) ([]string, uint64, error)
+
+func redisScanner(client redis.Cmdable, match string, count int64, keyType string) scanFunc {
+	return func(cursor uint64) ([]string, uint64, error) {
+		if keyType != "" {
+			return client.ScanType(ctx, cursor, match, count, keyType).Result()
+		}
+		return client.Scan(ctx, cursor, match, count).Result()
+	}
+}
+
+// scanNode iterates all pages of a scan until the cursor returns to 0
+func scanNode(scan scanFunc, fn func(key string) bool) error {
+	var cursor uint64
+	for {
+		keys, next, err := scan(cursor)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if !fn(key) {
+				return nil
+			}
+		}
+		if cursor = next; cursor == 0 {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package script
+
+import (
+	"fmt"
+	"main/util/alarm"
+
+	"github.com/dop251/goja"
+)
+
+// Alarm_evaluate evaluates alarm rules against device values
+// Usage in JS:
+//
+//	alarm.evaluate("pump_01", {pressure: 12.5, status: 3})
+//
+// Returns the alarms that changed state
+func Alarm_evaluate(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+	if len(call.Arguments) < 2 {
+		return nil, fmt.Errorf("alarm.evaluate requires a device name and values")
+	}
+	if alarm.ALARM_ENGINE == nil {
+		return nil, fmt.Errorf("alarm engine is not initialized")
+	}
+
+	device := call.Arguments[0].String()
+	values, ok := call.Arguments[1].Export().(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("second argument must be an object of values")
+	}
+
+	transitions := alarm.ALARM_ENGINE.Evaluate(device, values)
+	return rt.ToValue(alarmsToMaps(transitions)), nil
+}
+
+// Alarm_list returns active alarms
+// Usage in JS:
+//
+//	alarm.list({state: "raised", device: "pump_01"})
+func Alarm_list(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+	if alarm.ALARM_ENGINE == nil {
+		return nil, fmt.Errorf("alarm engine is not initialized")
+	}
+
+	var state, device string
+	if len(call.Arguments) > 0 && !goja.IsUndefined(call.Arguments[0]) && !goja.IsNull(call.Arguments[0]) {
+		options := call.Arguments[0].ToObject(rt)
+		state = extractStringOption(rt, options, "state", "")
+		device = extractStringOption(rt, options, "device", "")
+	}
+
+	return rt.ToValue(alarmsToMaps(alarm.ALARM_ENGINE.List(state, device))), nil
+}
+
+// Alarm_ack acknowledges an active alarm
+// Usage in JS:
+//
+//	alarm.ack("pump_01:high_pressure", "operator")
+func Alarm_ack(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+	if len(call.Arguments) < 1 {
+		return nil, fmt.Errorf("alarm.ack requires an alarm id")
+	}
+	if alarm.ALARM_ENGINE == nil {
+		return nil, fmt.Errorf("alarm engine is not initialized")
+	}
+
+	user := "script"
+	if len(call.Arguments) > 1 {
+		user = call.Arguments[1].String()
+	}
+
+	a, err := alarm.ALARM_ENGINE.Acknowledge(call.Arguments[0].String(), user)
+	if err != nil {
+		return nil, err
+	}
+	return rt.ToValue(a.ToMap()), nil
+}
+
+func alarmsToMaps(alarms []*alarm.Alarm) []map[string]interface{} {
+	result := make([]map[string]interface{}, 0, len(alarms))
+	for _, a := range alarms {
+		result = append(result, a.ToMap())
+	}
+	return result
+}
@@ -0,0 +1,178 @@
+package script
+
+import (
+	"encoding/json"
+	"fmt"
+	"main/util/config"
+	"main/util/strings"
+	"sort"
+
+	"github.com/dop251/goja"
+)
+
+// Config_devices returns the configured devices, optionally filtered
+// Usage in JS:
+//
+//	config.devices({
+//	  type: "pump",              // device type (optional)
+//	  tag: "line=A" | "critical" // tag property, "key=value" or "key" (optional)
+//	})
+//
+// Returns an array of device objects; tags and config are also provided
+// parsed as tagMap / configMap
+func Config_devices(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+	var deviceType string
+	var tags map[string]string
+	if len(call.Arguments) > 0 && !goja.IsUndefined(call.Arguments[0]) && !goja.IsNull(call.Arguments[0]) {
+		options := call.Arguments[0].ToObject(rt)
+		deviceType = extractStringOption(rt, options, "type", "")
+		tags = extractTagFilter(rt, options)
+	}
+
+	devices := config.GetAllDeviceConfig()
+	sort.Slice(devices, func(i, j int) bool {
+		return devices[i].Name < devices[j].Name
+	})
+
+	result := make([]map[string]interface{}, 0, len(devices))
+	for _, device := range devices {
+		if deviceType != "" && device.Type != deviceType {
+			continue
+		}
+		if !matchTags(device.Tags, tags) {
+			continue
+		}
+		result = append(result, deviceToMap(device))
+	}
+	return rt.ToValue(result), nil
+}
+
+// Config_device returns a single device by name, or null
+// Usage in JS:
+//
+//	config.device("pump_01")
+func Config_device(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+	if len(call.Arguments) < 1 {
+		return nil, fmt.Errorf("config.device requires a device name")
+	}
+
+	device := config.GetDeviceConfig(call.Arguments[0].String())
+	if device == nil {
+		return goja.Null(), nil
+	}
+	return rt.ToValue(deviceToMap(device)), nil
+}
+
+// Config_deviceType returns a device type configuration, or null
+// Usage in JS:
+//
+//	config.deviceType("pump")
+func Config_deviceType(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+	if len(call.Arguments) < 1 {
+		return nil, fmt.Errorf("config.deviceType requires a device type")
+	}
+
+	typeConfig := config.GetDeviceTypeConfig(call.Arguments[0].String())
+	if typeConfig == nil {
+		return goja.Null(), nil
+	}
+	result := toPlainMap(typeConfig)
+	result["typeName"] = typeConfig.TypeName
+	result["configMap"] = strings.ParsePropertyMap(typeConfig.Config)
+	result["tagMap"] = strings.ParsePropertyMap(typeConfig.Tags)
+	result["paramMap"] = strings.ParsePropertyMap(typeConfig.Params)
+	return rt.ToValue(result), nil
+}
+
+// Config_registers returns the Modbus registers of a protocol CSV
+// Usage in JS:
+//
+//	config.registers("pump.csv")
+func Config_registers(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+	if len(call.Arguments) < 1 {
+		return nil, fmt.Errorf("config.registers requires a protocol name")
+	}
+
+	registers, err := config.GetModbusRegisterConfig(call.Arguments[0].String())
+	if err != nil {
+		return goja.Null(), nil
+	}
+	result := make([]map[string]interface{}, 0, len(registers))
+	for i := range registers {
+		result = append(result, toPlainMap(&registers[i]))
+	}
+	return rt.ToValue(result), nil
+}
+
+// Config_dict returns the raw content of a dictionary, or null
+// Usage in JS:
+//
+//	config.dict("alarm_codes.csv")
+func Config_dict(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+	if len(call.Arguments) < 1 {
+		return nil, fmt.Errorf("config.dict requires a dictionary name")
+	}
+
+	data, ok := config.GetDictionaryConfig(call.Arguments[0].String())
+	if !ok {
+		return goja.Null(), nil
+	}
+	return rt.ToValue(data), nil
+}
+
+// Config_raw returns the parsed content of a data ID bound to the raw or
+// script handler, or null
+// Usage in JS:
+//
+//	config.raw("line-settings.yaml")
+func Config_raw(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+	if len(call.Arguments) < 1 {
+		return nil, fmt.Errorf("config.raw requires a data id")
+	}
+
+	value, ok := config.GetRawConfig(call.Arguments[0].String())
+	if !ok {
+		return goja.Null(), nil
+	}
+	return rt.ToValue(value), nil
+}
+
+// extractTagFilter accepts "key=value", "key" or an object of key/value pairs
+func extractTagFilter(rt *goja.Runtime, options *goja.Object) map[string]string {
+	val := options.Get("tag")
+	if val == nil || goja.IsUndefined(val) || goja.IsNull(val) {
+		return nil
+	}
+	if tag, ok := val.Export().(string); ok {
+		return strings.ParsePropertyMap(tag)
+	}
+	return extractMapOption(rt, options, "tag")
+}
+
+func matchTags(deviceTags string, tags map[string]string) bool {
+	for key, value := range tags {
+		if config.GetPropertyValue(deviceTags, key, "") != value {
+			return false
+		}
+	}
+	return true
+}
+
+func deviceToMap(device *config.DeviceConfig) map[string]interface{} {
+	result := toPlainMap(device)
+	result["tagMap"] = strings.ParsePropertyMap(device.Tags)
+	result["configMap"] = strings.ParsePropertyMap(device.Config)
+	return result
+}
+
+// toPlainMap converts a config struct into a map using its json field names,
+// so scripts get a copy that cannot modify the live configuration
+func toPlainMap(v interface{}) map[string]interface{} {
+	result := make(map[string]interface{})
+	data, err := json.Marshal(v)
+	if err != nil {
+		return result
+	}
+	json.Unmarshal(data, &result)
+	return result
+}
@@ -0,0 +1,141 @@
+package script
+
+import (
+	"fmt"
+	"main/util/alarm"
+	"main/util/history"
+	"main/util/strings"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// Device_record stores polled register values in the history store and
+// evaluates alarm rules against them
+// Usage in JS:
+//
+//	device.record("pump_01", {pressure: 12.5, status: 3}[, timestamp])
+//
+// Returns the alarms that changed state
+func Device_record(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+	if len(call.Arguments) < 2 {
+		return nil, fmt.Errorf("device.record requires a device name and values")
+	}
+
+	device := call.Arguments[0].String()
+	values, ok := call.Arguments[1].Export().(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("second argument must be an object of values")
+	}
+
+	ts := time.Now()
+	if len(call.Arguments) > 2 && !goja.IsUndefined(call.Arguments[2]) && !goja.IsNull(call.Arguments[2]) {
+		var err error
+		if ts, err = exportTime(call.Arguments[2], ts); err != nil {
+			return nil, err
+		}
+	}
+
+	if history.HISTORY_STORE != nil {
+		if err := history.Record(device, ts, values); err != nil {
+			return nil, fmt.Errorf("failed to record history: %w", err)
+		}
+	}
+
+	if alarm.ALARM_ENGINE == nil {
+		return rt.ToValue([]map[string]interface{}{}), nil
+	}
+	return rt.ToValue(alarmsToMaps(alarm.ALARM_ENGINE.Evaluate(device, values))), nil
+}
+
+// Device_history queries recorded values of a device register
+// Usage in JS:
+//
+//	device.history("pump_01", "pressure", {
+//	  from: Date | number | string,  // default: 1 hour ago
+//	  to: Date | number | string,    // default: now
+//	  step: "5m" | 300               // optional, aggregate min/max/avg per bucket
+//	})
+//
+// Returns an array of {t, v} points, or {t, min, max, avg, count} buckets when step is set.
+// The array's truncated property is true when only the first history.maxPoints points are returned.
+func Device_history(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+	if len(call.Arguments) < 2 {
+		return nil, fmt.Errorf("device.history requires a device name and a key")
+	}
+
+	device := call.Arguments[0].String()
+	key := call.Arguments[1].String()
+
+	to := time.Now()
+	from := to.Add(-time.Hour)
+	var step time.Duration
+	if len(call.Arguments) > 2 && !goja.IsUndefined(call.Arguments[2]) && !goja.IsNull(call.Arguments[2]) {
+		options := call.Arguments[2].ToObject(rt)
+		var err error
+		if v := options.Get("to"); v != nil && !goja.IsUndefined(v) {
+			if to, err = exportTime(v, to); err != nil {
+				return nil, err
+			}
+			from = to.Add(-time.Hour)
+		}
+		if v := options.Get("from"); v != nil && !goja.IsUndefined(v) {
+			if from, err = exportTime(v, from); err != nil {
+				return nil, err
+			}
+		}
+		if step, err = history.ParseStep(extractStringOption(rt, options, "step", "")); err != nil {
+			return nil, fmt.Errorf("invalid step: %w", err)
+		}
+	}
+
+	result, err := history.Query(device, key, from, to, step)
+	if err != nil {
+		return nil, err
+	}
+	maps := historyToMaps(result.Data())
+	items := make([]interface{}, len(maps))
+	for i, m := range maps {
+		items[i] = m
+	}
+	points := rt.NewArray(items...)
+	if err := points.Set("truncated", result.Truncated); err != nil {
+		return nil, err
+	}
+	return points, nil
+}
+
+// historyToMaps converts points or buckets to objects with the documented
+// keys, goja would otherwise expose the Go field names
+func historyToMaps(result interface{}) []map[string]interface{} {
+	switch data := result.(type) {
+	case []history.Point:
+		maps := make([]map[string]interface{}, 0, len(data))
+		for _, p := range data {
+			maps = append(maps, map[string]interface{}{"t": p.Time, "v": p.Value})
+		}
+		return maps
+	case []history.Bucket:
+		maps := make([]map[string]interface{}, 0, len(data))
+		for _, b := range data {
+			maps = append(maps, map[string]interface{}{"t": b.Time, "min": b.Min, "max": b.Max, "avg": b.Avg, "count": b.Count})
+		}
+		return maps
+	}
+	return make([]map[string]interface{}, 0)
+}
+
+// exportTime accepts a JS Date, unix milliseconds or RFC3339 text
+func exportTime(value goja.Value, def time.Time) (time.Time, error) {
+	switch v := value.Export().(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		return history.ParseTime(v, def)
+	default:
+		if ms, ok := strings.ToFloat(v); ok {
+			return time.UnixMilli(int64(ms)), nil
+		}
+	}
+	return def, fmt.Errorf("invalid time value: %v", value)
+}
@@ -0,0 +1,90 @@
+package script
+
+import (
+	"fmt"
+	"main/util/config"
+	"main/util/strings"
+
+	"github.com/dop251/goja"
+)
+
+// Dict_lookup finds a dictionary row by key
+// Usage in JS:
+//
+//	dict.lookup("alarm_codes.csv", "E01")
+//	dict.lookup("line_station.csv", ["L1", "S03"]) // composite key
+//
+// Returns a copy of the row with typed values, or null
+func Dict_lookup(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+	if len(call.Arguments) < 2 {
+		return nil, fmt.Errorf("dict.lookup requires a dictionary name and a key")
+	}
+
+	table, err := getDictionaryTable(call.Arguments[0].String())
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	if parts, ok := call.Arguments[1].Export().([]interface{}); ok {
+		for _, part := range parts {
+			keys = append(keys, strings.ToString(part))
+		}
+	} else {
+		keys = []string{call.Arguments[1].String()}
+	}
+
+	row, ok := table.Lookup(keys...)
+	if !ok {
+		return goja.Null(), nil
+	}
+	return rt.ToValue(row), nil
+}
+
+// Dict_rows returns copies of dictionary rows, optionally filtered by column values
+// Usage in JS:
+//
+//	dict.rows("alarm_codes.csv", {level: "critical"})
+func Dict_rows(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+	if len(call.Arguments) < 1 {
+		return nil, fmt.Errorf("dict.rows requires a dictionary name")
+	}
+
+	table, err := getDictionaryTable(call.Arguments[0].String())
+	if err != nil {
+		return nil, err
+	}
+
+	filter := map[string]string{}
+	if len(call.Arguments) > 1 && !goja.IsUndefined(call.Arguments[1]) && !goja.IsNull(call.Arguments[1]) {
+		obj := call.Arguments[1].ToObject(rt)
+		for _, k := range obj.Keys() {
+			filter[k] = strings.ToString(obj.Get(k).Export())
+		}
+	}
+	return rt.ToValue(table.Filter(filter)), nil
+}
+
+// Dict_reverse finds the rows whose column equals a value
+// Usage in JS:
+//
+//	dict.reverse("alarm_codes.csv", "name", "Overheat")
+func Dict_reverse(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+	if len(call.Arguments) < 3 {
+		return nil, fmt.Errorf("dict.reverse requires a dictionary name, a column and a value")
+	}
+
+	table, err := getDictionaryTable(call.Arguments[0].String())
+	if err != nil {
+		return nil, err
+	}
+	return rt.ToValue(table.Reverse(call.Arguments[1].String(), call.Arguments[2].String())), nil
+}
+
+func getDictionaryTable(name string) (*config.DictionaryTable, error) {
+	table, ok := config.GetDictionaryTable(name)
+	if !ok {
+		return nil, fmt.Errorf("dictionary %s not found", name)
+	}
+	return table, nil
+}
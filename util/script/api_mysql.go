@@ -4,91 +4,268 @@ import (
 	"fmt"
 	"main/util/mysql"
 	"main/util/strings"
+	gstrings "strings"
+
+	"time"
 
 	"github.com/dop251/goja"
+	"github.com/puzpuzpuz/xsync/v4"
 )
 
-// MySQL_query executes a SQL query and returns the results as an array of objects
-// Usage in JS:
-//
-//	mysql.query("SELECT * FROM users WHERE age > ?", [25])
-//
-// Returns an array of objects with column names as keys
-func MySQL_query(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
-	if len(call.Arguments) < 1 {
-		return nil, fmt.Errorf("mysql.query requires at least a query string")
-	}
+const (
+	mysqlDatabaseProperty = "__db"
+	mysqlTxProperty       = "__tx"
+)
 
-	// var query string
-	// var dbName string
-	// argIndex := 1
-	param := call.Arguments[0].String()
-	db, query := strings.Extract(param, "[", "]")
+// mysqlTxKey identifies the transaction a runtime has open on a database
+type mysqlTxKey struct {
+	rt     *goja.Runtime
+	client *mysql.MySQLClient
+}
 
-	// Check if MySQL client is initialized
-	if mysql.MYSQL_CLIENT == nil {
-		return nil, fmt.Errorf("MySQL client is not initialized")
+// mysqlActiveTx lets plain mysql.* calls made inside a transaction callback
+// join the transaction of the same database
+var mysqlActiveTx = xsync.NewMap[mysqlTxKey, *mysql.MySQLTx]()
+
+// mysqlRequest is a parsed (query, [params], [options], [callback]) call
+type mysqlRequest struct {
+	executor mysql.Executor
+	query    string
+	args     []interface{}
+	options  *goja.Object
+	callback goja.Callable
+	maxRows  int // 0 = unlimited
+}
+
+// parseMySQLCall reads the query, its parameters and the database to use:
+// options.db, a leading "[name]" prefix, the mysql.db() handle or the default.
+// Parameters are an array for ? placeholders or an object for :name ones,
+// arrays are expanded for IN (?).
+func parseMySQLCall(rt *goja.Runtime, call goja.FunctionCall, name string) (*mysqlRequest, error) {
+	if len(call.Arguments) < 1 {
+		return nil, fmt.Errorf("mysql.%s requires at least a query string", name)
+	}
+	db, query := splitDatabasePrefix(call.Arguments[0].String())
+	if db == "" {
+		db = mysqlHandleDatabase(call)
 	}
 
-	// Process arguments if provided
-	var args []interface{}
-	if len(call.Arguments) > 1 && !goja.IsUndefined(call.Arguments[1]) && !goja.IsNull(call.Arguments[1]) {
-		// Extract arguments array
-		argsValue := call.Arguments[1].Export()
-		if argsArray, ok := argsValue.([]interface{}); ok {
-			args = argsArray
-		} else {
+	request := &mysqlRequest{query: query}
+	var named map[string]interface{}
+	hasNamed := mysql.HasNamedParams(query)
+	for i, arg := range call.Arguments[1:] {
+		if goja.IsUndefined(arg) || goja.IsNull(arg) {
+			continue
+		}
+		if fn, ok := goja.AssertFunction(arg); ok {
+			request.callback = fn
+			continue
+		}
+		switch v := arg.Export().(type) {
+		case []interface{}:
+			if i > 0 {
+				return nil, fmt.Errorf("mysql.%s: query parameters must be the second argument", name)
+			}
+			request.args = v
+		case map[string]interface{}:
+			if i == 0 && hasNamed {
+				named = v
+				continue
+			}
+			if dbName, ok := v["db"].(string); ok && dbName != "" {
+				db = dbName
+			}
+			request.options = arg.ToObject(rt)
+		default:
 			return nil, fmt.Errorf("second argument must be an array of query parameters")
 		}
 	}
+	if hasNamed && named == nil {
+		return nil, fmt.Errorf("mysql.%s: the query uses :name parameters, pass them as an object in the second argument", name)
+	}
+	var err error
+	if request.query, request.args, err = mysql.ExpandParams(query, request.args, named); err != nil {
+		return nil, fmt.Errorf("mysql.%s: %w", name, err)
+	}
 
-	client := mysql.GetClient(db)
-	if client == nil {
-		return nil, fmt.Errorf("MySQL client is not initialized")
+	if tx := mysqlHandleTx(call); tx != nil && db == mysqlHandleDatabase(call) {
+		request.executor = tx
+	} else {
+		client, err := mysql.Client(db)
+		if err != nil {
+			return nil, err
+		}
+		request.executor = client.WithScript(ScriptName(rt))
+		if tx, ok := mysqlActiveTx.Load(mysqlTxKey{rt, client}); ok {
+			request.executor = tx
+		} else if boolOption(request.options, "primary") {
+			request.executor = client.Primary().WithScript(ScriptName(rt))
+		}
+	}
+
+	request.maxRows = request.executor.MaxRows()
+	if v := request.option("maxRows"); v != nil {
+		request.maxRows = max(int(v.ToInteger()), 0)
+	}
+	return request, nil
+}
+
+// option returns an option value, nil if not set
+func (r *mysqlRequest) option(name string) goja.Value {
+	if r.options == nil {
+		return nil
+	}
+	if v := r.options.Get(name); v != nil && !goja.IsUndefined(v) && !goja.IsNull(v) {
+		return v
 	}
+	return nil
+}
 
-	results, err := client.QueryToMap(query, args...)
+// queryRows reads the rows of the query, at most limit (0 = all), within
+// the statement timeout; more than maxRows rows is an error
+func (r *mysqlRequest) queryRows(rt *goja.Runtime, name string, limit int) ([]interface{}, error) {
+	rows := make([]interface{}, 0)
+	err := r.executor.Scan(r.query, r.args, func(row map[string]interface{}) (bool, error) {
+		if r.maxRows > 0 && len(rows) >= r.maxRows {
+			return false, fmt.Errorf("mysql.%s: %w: more than %d rows, use mysql.each, mysql.cursor or mysql.page, or raise {maxRows}",
+				name, mysql.ErrTooManyRows, r.maxRows)
+		}
+		rows = append(rows, mysqlRow(rt, row))
+		return limit == 0 || len(rows) < limit, nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("query execution failed: %w", err)
+		return nil, err
 	}
+	return rows, nil
+}
 
-	// Convert the results to a JavaScript array
-	return rt.ToValue(results), nil
+// splitDatabasePrefix splits "[report] SELECT ..." into "report" and the query
+func splitDatabasePrefix(query string) (string, string) {
+	trimmed := gstrings.TrimSpace(query)
+	if !gstrings.HasPrefix(trimmed, "[") {
+		return "", query
+	}
+	db, rest := strings.Extract(trimmed, "[", "]")
+	return gstrings.TrimSpace(db), rest
 }
 
-// MySQL_exec executes a SQL statement that doesn't return rows (INSERT, UPDATE, DELETE)
+// mysqlHandleDatabase returns the database of a mysql.db() handle
+func mysqlHandleDatabase(call goja.FunctionCall) string {
+	if this, ok := call.This.(*goja.Object); ok {
+		if v := this.Get(mysqlDatabaseProperty); v != nil && !goja.IsUndefined(v) {
+			return v.String()
+		}
+	}
+	return ""
+}
+
+// mysqlHandleTx returns the transaction of a handle passed to a
+// mysql.transaction callback
+func mysqlHandleTx(call goja.FunctionCall) *mysql.MySQLTx {
+	if this, ok := call.This.(*goja.Object); ok {
+		if v := this.Get(mysqlTxProperty); v != nil {
+			if tx, ok := v.Export().(*mysql.MySQLTx); ok {
+				return tx
+			}
+		}
+	}
+	return nil
+}
+
+// mysqlHandle copies the mysql module functions into a new object bound to a
+// database and, for transaction handles, to the transaction
+func mysqlHandle(rt *goja.Runtime, db string, tx *mysql.MySQLTx) (*goja.Object, error) {
+	module := rt.Get("mysql")
+	if module == nil || goja.IsUndefined(module) {
+		return nil, fmt.Errorf("mysql module is not available")
+	}
+	source := module.ToObject(rt)
+	obj := rt.NewObject()
+	for _, key := range source.Keys() {
+		obj.Set(key, source.Get(key))
+	}
+	obj.Set(mysqlDatabaseProperty, db)
+	if tx != nil {
+		obj.Set(mysqlTxProperty, tx)
+	}
+	return obj, nil
+}
+
+// MySQL_db returns the mysql module bound to a named database
 // Usage in JS:
 //
-//	mysql.exec("INSERT INTO users (name, age) VALUES (?, ?)", ["John", 30])
-//
-// Returns an object with lastInsertId and rowsAffected properties
-func MySQL_exec(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+//	var report = mysql.db("report");
+//	report.query("SELECT * FROM daily WHERE day = ?", ["2024-01-01"]);
+func MySQL_db(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
 	if len(call.Arguments) < 1 {
-		return nil, fmt.Errorf("mysql.exec requires at least a query string")
+		return nil, fmt.Errorf("mysql.db requires a database name")
+	}
+	name := call.Arguments[0].String()
+	if _, err := mysql.Client(name); err != nil {
+		return nil, err
 	}
+	return mysqlHandle(rt, name, nil)
+}
 
-	// Get the query string
-	query := call.Arguments[0].String()
+// MySQL_query executes a SQL query and returns the results as an array of objects
+// Usage in JS:
+//
+//	mysql.query("SELECT * FROM users WHERE age > ?", [25])
+//	mysql.query("[report] SELECT * FROM daily")              // named database
+//	mysql.query("SELECT * FROM daily", [], {db: "report"})
+//	mysql.query("SELECT * FROM log", [], {maxRows: 50000})  // 0 = unlimited
+//	mysql.query("SELECT * FROM orders WHERE id = ?", [id], {primary: true})
+//
+// Returns an array of objects with column names as keys. Queries returning
+// more than maxRows rows (database maxRows, default 10000) throw; use
+// mysql.each, mysql.cursor or mysql.page for large results. Reads go to a
+// read replica when the database has any; primary: true reads from the
+// primary, e.g. right after a write.
+func MySQL_query(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+	request, err := parseMySQLCall(rt, call, "query")
+	if err != nil {
+		return nil, err
+	}
 
-	// Check if MySQL client is initialized
-	if mysql.MYSQL_CLIENT == nil {
-		return nil, fmt.Errorf("MySQL client is not initialized")
+	rows, err := request.queryRows(rt, "query", 0)
+	if err != nil {
+		return nil, fmt.Errorf("query execution failed: %w", err)
 	}
+	return rt.ToValue(rows), nil
+}
 
-	// Process arguments if provided
-	var args []interface{}
-	if len(call.Arguments) > 1 && !goja.IsUndefined(call.Arguments[1]) && !goja.IsNull(call.Arguments[1]) {
-		// Extract arguments array
-		argsValue := call.Arguments[1].Export()
-		if argsArray, ok := argsValue.([]interface{}); ok {
-			args = argsArray
-		} else {
-			return nil, fmt.Errorf("second argument must be an array of query parameters")
+// mysqlRow converts a result row to a JS object; DATE / DATETIME / TIMESTAMP
+// columns become Dates, big integers BigInts (see mysql/types.go)
+func mysqlRow(rt *goja.Runtime, row map[string]interface{}) *goja.Object {
+	obj := rt.NewObject()
+	for column, value := range row {
+		if t, ok := value.(time.Time); ok {
+			date, err := rt.New(rt.Get("Date"), rt.ToValue(t.UnixMilli()))
+			if err == nil {
+				obj.Set(column, date)
+				continue
+			}
 		}
+		obj.Set(column, value)
+	}
+	return obj
+}
+
+// MySQL_exec executes a SQL statement that doesn't return rows (INSERT, UPDATE, DELETE)
+// Usage in JS:
+//
+//	mysql.exec("INSERT INTO users (name, age) VALUES (?, ?)", ["John", 30])
+//
+// The database is selected as in mysql.query.
+// Returns an object with lastInsertId and rowsAffected properties
+func MySQL_exec(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+	request, err := parseMySQLCall(rt, call, "exec")
+	if err != nil {
+		return nil, err
 	}
 
 	// Execute the statement
-	result, err := mysql.MYSQL_CLIENT.Exec(query, args...)
+	result, err := request.executor.Exec(request.query, request.args...)
 	if err != nil {
 		return nil, fmt.Errorf("statement execution failed: %w", err)
 	}
@@ -111,34 +288,17 @@ func MySQL_exec(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
 //
 //	mysql.queryRow("SELECT * FROM users WHERE id = ?", [1])
 //
+// The database is selected as in mysql.query.
 // Returns an object with column names as keys or null if no rows found
 func MySQL_queryRow(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
-	if len(call.Arguments) < 1 {
-		return nil, fmt.Errorf("mysql.queryRow requires at least a query string")
-	}
-
-	// Get the query string
-	query := call.Arguments[0].String()
-
-	// Check if MySQL client is initialized
-	if mysql.MYSQL_CLIENT == nil {
-		return nil, fmt.Errorf("MySQL client is not initialized")
-	}
-
-	// Process arguments if provided
-	var args []interface{}
-	if len(call.Arguments) > 1 && !goja.IsUndefined(call.Arguments[1]) && !goja.IsNull(call.Arguments[1]) {
-		// Extract arguments array
-		argsValue := call.Arguments[1].Export()
-		if argsArray, ok := argsValue.([]interface{}); ok {
-			args = argsArray
-		} else {
-			return nil, fmt.Errorf("second argument must be an array of query parameters")
-		}
+	request, err := parseMySQLCall(rt, call, "queryRow")
+	if err != nil {
+		return nil, err
 	}
 
-	// Execute the query
-	results, err := mysql.MYSQL_CLIENT.QueryToMap(query, args...)
+	// Execute the query, only the first row is read
+	request.maxRows = 0
+	results, err := request.queryRows(rt, "queryRow", 1)
 	if err != nil {
 		return nil, fmt.Errorf("query execution failed: %w", err)
 	}
@@ -155,12 +315,19 @@ func MySQL_queryRow(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error
 // MySQL_transaction executes a function within a transaction
 // Usage in JS:
 //
-//	mysql.transaction(function() {
-//	  mysql.exec("INSERT INTO users (name) VALUES (?)", ["John"]);
-//	  mysql.exec("UPDATE counters SET value = value + 1 WHERE name = ?", ["user_count"]);
-//	});
+//	mysql.transaction(function(tx) {
+//	  tx.exec("INSERT INTO users (name) VALUES (?)", ["John"]);
+//	  tx.exec("UPDATE counters SET value = value + 1 WHERE name = ?", ["user_count"]);
+//	  tx.transaction(function(tx) { ... }); // nested: savepoint
+//	}, {db: "report", isolation: "serializable", readOnly: false, timeout: 30});
 //
-// Returns true if the transaction was committed successfully
+// tx has the functions of the mysql module; plain mysql.* calls on the same
+// database made inside the callback join the transaction too. A nested
+// transaction on the same database becomes a savepoint and only undoes its
+// own statements when it throws.
+// The transaction is rolled back if the callback throws or when timeout
+// (seconds, default 60) expires. Returns the callback's return value, or true
+// if it returned nothing.
 func MySQL_transaction(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
 	if len(call.Arguments) < 1 {
 		return nil, fmt.Errorf("mysql.transaction requires a callback function")
@@ -172,36 +339,106 @@ func MySQL_transaction(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, er
 		return nil, fmt.Errorf("first argument must be a function")
 	}
 
-	// Check if MySQL client is initialized
-	if mysql.MYSQL_CLIENT == nil {
-		return nil, fmt.Errorf("MySQL client is not initialized")
+	db := mysqlHandleDatabase(call)
+	var txOptions mysql.TxOptions
+	if len(call.Arguments) > 1 && isObjectArg(call.Arguments[1]) {
+		options := call.Arguments[1].ToObject(rt)
+		if name := extractStringOption(rt, options, "db", ""); name != "" {
+			db = name
+		}
+		isolation, err := mysql.ParseIsolation(extractStringOption(rt, options, "isolation", ""))
+		if err != nil {
+			return nil, err
+		}
+		txOptions.Isolation = isolation
+		if v := options.Get("readOnly"); v != nil && !goja.IsUndefined(v) {
+			txOptions.ReadOnly = v.ToBoolean()
+		}
+		if v := options.Get("timeout"); v != nil && !goja.IsUndefined(v) && v.ToFloat() > 0 {
+			txOptions.Timeout = time.Duration(v.ToFloat() * float64(time.Second))
+		}
+	}
+
+	// 嵌套事务：使用外层事务的保存点
+	if tx := mysqlHandleTx(call); tx != nil && db == mysqlHandleDatabase(call) {
+		return mysqlSavepoint(rt, call.This.(*goja.Object), tx, callback)
+	}
+	client, err := mysql.Client(db)
+	if err != nil {
+		return nil, err
+	}
+	key := mysqlTxKey{rt, client}
+	if tx, ok := mysqlActiveTx.Load(key); ok {
+		handle, err := mysqlHandle(rt, db, tx)
+		if err != nil {
+			return nil, err
+		}
+		return mysqlSavepoint(rt, handle, tx, callback)
 	}
 
 	// Start a transaction
-	tx, err := mysql.MYSQL_CLIENT.Begin()
+	tx, err := client.WithScript(ScriptName(rt)).BeginTx(txOptions)
 	if err != nil {
 		return nil, fmt.Errorf("failed to start transaction: %w", err)
 	}
+	handle, err := mysqlHandle(rt, db, tx)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	mysqlActiveTx.Store(key, tx)
+	defer mysqlActiveTx.Delete(key)
 
 	// Create a deferred function to handle rollback in case of error
 	defer func() {
 		if r := recover(); r != nil {
+			closeCursors(rt, tx)
 			tx.Rollback()
 			panic(r) // Re-throw the panic after rollback
 		}
 	}()
 
 	// Execute the callback function
-	_, err = callback(goja.Undefined())
+	result, err := callback(goja.Undefined(), handle)
+	// 事务连接上仍打开的游标会导致提交/回滚失败
+	closeCursors(rt, tx)
 	if err != nil {
 		tx.Rollback()
-		return rt.ToValue(false), err
+		return nil, err
 	}
 
 	// Commit the transaction
 	if err = tx.Commit(); err != nil {
-		return rt.ToValue(false), fmt.Errorf("failed to commit transaction: %w", err)
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
+	return transactionResult(rt, result), nil
+}
 
-	return rt.ToValue(true), nil
+// mysqlSavepoint runs a nested transaction callback inside a savepoint
+func mysqlSavepoint(rt *goja.Runtime, handle *goja.Object, tx *mysql.MySQLTx, callback goja.Callable) (goja.Value, error) {
+	savepoint, err := tx.Savepoint()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create savepoint: %w", err)
+	}
+	result, err := callback(goja.Undefined(), handle)
+	closeCursors(rt, tx)
+	if err != nil {
+		if rbErr := tx.RollbackTo(savepoint); rbErr != nil {
+			return nil, fmt.Errorf("%v (rollback to savepoint failed: %v)", err, rbErr)
+		}
+		return nil, err
+	}
+	if err := tx.Release(savepoint); err != nil {
+		return nil, err
+	}
+	return transactionResult(rt, result), nil
+}
+
+// transactionResult returns the callback's value, true if it returned nothing
+func transactionResult(rt *goja.Runtime, result goja.Value) goja.Value {
+	if result == nil || goja.IsUndefined(result) {
+		return rt.ToValue(true)
+	}
+	return result
 }
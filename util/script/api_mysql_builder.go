@@ -0,0 +1,413 @@
+package script
+
+import (
+	"encoding/json"
+	"fmt"
+	"main/util/mysql"
+	"regexp"
+	gstrings "strings"
+
+	"github.com/dop251/goja"
+)
+
+var mysqlIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_$]*(\.[A-Za-z_][A-Za-z0-9_$]*)?$`)
+
+// mysqlBuilder builds parameterized statements for one table; statements run
+// through mysql.query / mysql.exec on the handle table() was called on, so
+// database and transaction selection work the same way
+type mysqlBuilder struct {
+	rt      *goja.Runtime
+	handle  goja.Value
+	table   string
+	where   []string
+	args    []interface{}
+	orderBy []string
+	limit   int64
+	offset  int64
+}
+
+// MySQL_table returns a query builder for a table
+// Usage in JS:
+//
+//	var orders = mysql.table("orders");
+//	orders.where({status: "open", line: ["L1", "L2"]}).orderBy("id", "desc").limit(10).select();
+//	orders.where("created_at >= :since", {since: new Date(Date.now() - 3600e3)}).count();
+//	orders.insert({no: "A001", qty: 3});                 // or an array of rows
+//	orders.upsert({no: "A001", qty: 5}, ["qty"]);         // INSERT ... ON DUPLICATE KEY UPDATE
+//	orders.where({no: "A001"}).update({qty: 6});
+//	orders.where({no: "A001"}).delete();
+//
+// where() calls are combined with AND: objects match columns (arrays use IN,
+// null uses IS NULL), strings are SQL conditions with ? or :name parameters.
+// select / first / count return the same values as mysql.query / queryRow;
+// insert / upsert / update / delete return the result of mysql.exec.
+// update and delete require a where condition.
+func MySQL_table(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+	if len(call.Arguments) < 1 {
+		return nil, fmt.Errorf("mysql.table requires a table name")
+	}
+	table, err := quoteIdentifier(call.Arguments[0].String())
+	if err != nil {
+		return nil, err
+	}
+	b := &mysqlBuilder{rt: rt, handle: call.This, table: table, limit: -1, offset: -1}
+	return b.object(), nil
+}
+
+func (b *mysqlBuilder) object() *goja.Object {
+	rt := b.rt
+	obj := rt.NewObject()
+	method := func(fn func(fc goja.FunctionCall) (goja.Value, error)) func(goja.FunctionCall) goja.Value {
+		return func(fc goja.FunctionCall) goja.Value {
+			value, err := fn(fc)
+			if err != nil {
+				panic(rt.NewGoError(err))
+			}
+			return value
+		}
+	}
+	// where / orderBy / limit / offset 返回新的 builder，原 builder 可以复用
+	chain := func(fn func(next *mysqlBuilder, fc goja.FunctionCall) error) func(goja.FunctionCall) goja.Value {
+		return method(func(fc goja.FunctionCall) (goja.Value, error) {
+			next := b.clone()
+			if err := fn(next, fc); err != nil {
+				return nil, err
+			}
+			return next.object(), nil
+		})
+	}
+	obj.Set("where", chain((*mysqlBuilder).whereCall))
+	obj.Set("orderBy", chain((*mysqlBuilder).orderByCall))
+	obj.Set("limit", chain(func(next *mysqlBuilder, fc goja.FunctionCall) error {
+		next.limit = intArg(fc, 0)
+		return nil
+	}))
+	obj.Set("offset", chain(func(next *mysqlBuilder, fc goja.FunctionCall) error {
+		next.offset = intArg(fc, 0)
+		return nil
+	}))
+	obj.Set("select", method(func(fc goja.FunctionCall) (goja.Value, error) {
+		query, err := b.selectSQL(fc)
+		if err != nil {
+			return nil, err
+		}
+		return b.run(MySQL_query, query, b.args)
+	}))
+	obj.Set("first", method(func(fc goja.FunctionCall) (goja.Value, error) {
+		first := b.clone()
+		first.limit = 1
+		query, err := first.selectSQL(fc)
+		if err != nil {
+			return nil, err
+		}
+		return b.run(MySQL_queryRow, query, b.args)
+	}))
+	obj.Set("count", method(func(fc goja.FunctionCall) (goja.Value, error) {
+		row, err := b.run(MySQL_queryRow, "SELECT COUNT(*) AS n FROM "+b.table+b.whereSQL(), b.args)
+		if err != nil {
+			return nil, err
+		}
+		return row.ToObject(rt).Get("n"), nil
+	}))
+	obj.Set("insert", method(func(fc goja.FunctionCall) (goja.Value, error) {
+		query, args, err := b.insertSQL(fc, false)
+		if err != nil {
+			return nil, err
+		}
+		return b.run(MySQL_exec, query, args)
+	}))
+	obj.Set("upsert", method(func(fc goja.FunctionCall) (goja.Value, error) {
+		query, args, err := b.insertSQL(fc, true)
+		if err != nil {
+			return nil, err
+		}
+		return b.run(MySQL_exec, query, args)
+	}))
+	obj.Set("update", method(b.updateCall))
+	obj.Set("delete", method(func(fc goja.FunctionCall) (goja.Value, error) {
+		if len(b.where) == 0 {
+			return nil, fmt.Errorf("delete requires a where condition, use where(\"1 = 1\") to delete all rows")
+		}
+		return b.run(MySQL_exec, "DELETE FROM "+b.table+b.whereSQL(), b.args)
+	}))
+	obj.Set("toSQL", method(func(fc goja.FunctionCall) (goja.Value, error) {
+		query, err := b.selectSQL(fc)
+		if err != nil {
+			return nil, err
+		}
+		return rt.ToValue(map[string]interface{}{"sql": query, "params": b.args}), nil
+	}))
+	return obj
+}
+
+func (b *mysqlBuilder) clone() *mysqlBuilder {
+	next := *b
+	next.where = append([]string(nil), b.where...)
+	next.args = append([]interface{}(nil), b.args...)
+	next.orderBy = append([]string(nil), b.orderBy...)
+	return &next
+}
+
+// run executes a statement as if the script called fn on the handle
+func (b *mysqlBuilder) run(fn func(*goja.Runtime, goja.FunctionCall) (goja.Value, error), query string, args []interface{}) (goja.Value, error) {
+	if args == nil {
+		args = []interface{}{}
+	}
+	return fn(b.rt, goja.FunctionCall{This: b.handle, Arguments: []goja.Value{b.rt.ToValue(query), b.rt.ToValue(args)}})
+}
+
+func (b *mysqlBuilder) whereCall(fc goja.FunctionCall) error {
+	if len(fc.Arguments) == 0 {
+		return fmt.Errorf("usage: where({column: value}) or where(sql, [params])")
+	}
+	condition := fc.Arguments[0]
+	if !isObjectArg(condition) {
+		var args []interface{}
+		var named map[string]interface{}
+		if len(fc.Arguments) > 1 {
+			switch v := fc.Arguments[1].Export().(type) {
+			case []interface{}:
+				args = v
+			case map[string]interface{}:
+				named = v
+			}
+		}
+		query, args, err := mysql.ExpandParams(condition.String(), args, named)
+		if err != nil {
+			return err
+		}
+		b.where = append(b.where, "("+query+")")
+		b.args = append(b.args, args...)
+		return nil
+	}
+
+	obj := condition.ToObject(b.rt)
+	for _, key := range obj.Keys() {
+		column, err := quoteIdentifier(key)
+		if err != nil {
+			return err
+		}
+		switch v := obj.Get(key).Export().(type) {
+		case nil:
+			b.where = append(b.where, column+" IS NULL")
+		case []interface{}:
+			if len(v) == 0 {
+				b.where = append(b.where, "1 = 0")
+				continue
+			}
+			b.where = append(b.where, column+" IN ("+gstrings.TrimSuffix(gstrings.Repeat("?, ", len(v)), ", ")+")")
+			b.args = append(b.args, v...)
+		default:
+			b.where = append(b.where, column+" = ?")
+			b.args = append(b.args, v)
+		}
+	}
+	return nil
+}
+
+func (b *mysqlBuilder) orderByCall(fc goja.FunctionCall) error {
+	if len(fc.Arguments) == 0 {
+		return fmt.Errorf("usage: orderBy(column, [\"asc\" | \"desc\"])")
+	}
+	fields := gstrings.Fields(fc.Arguments[0].String())
+	direction := ""
+	if len(fc.Arguments) > 1 {
+		direction = fc.Arguments[1].String()
+	} else if len(fields) == 2 {
+		direction = fields[1]
+	}
+	if len(fields) == 0 || len(fields) > 2 {
+		return fmt.Errorf("invalid order by %q", fc.Arguments[0].String())
+	}
+	column, err := quoteIdentifier(fields[0])
+	if err != nil {
+		return err
+	}
+	switch gstrings.ToUpper(direction) {
+	case "", "ASC":
+		b.orderBy = append(b.orderBy, column)
+	case "DESC":
+		b.orderBy = append(b.orderBy, column+" DESC")
+	default:
+		return fmt.Errorf("invalid order direction %q", direction)
+	}
+	return nil
+}
+
+func (b *mysqlBuilder) updateCall(fc goja.FunctionCall) (goja.Value, error) {
+	if len(fc.Arguments) == 0 || !isObjectArg(fc.Arguments[0]) {
+		return nil, fmt.Errorf("usage: update({column: value})")
+	}
+	if len(b.where) == 0 {
+		return nil, fmt.Errorf("update requires a where condition, use where(\"1 = 1\") to update all rows")
+	}
+	obj := fc.Arguments[0].ToObject(b.rt)
+	var sets []string
+	var args []interface{}
+	for _, key := range obj.Keys() {
+		column, err := quoteIdentifier(key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := columnValue(obj.Get(key))
+		if err != nil {
+			return nil, err
+		}
+		sets = append(sets, column+" = ?")
+		args = append(args, value)
+	}
+	if len(sets) == 0 {
+		return nil, fmt.Errorf("update requires at least one column")
+	}
+	query := "UPDATE " + b.table + " SET " + gstrings.Join(sets, ", ") + b.whereSQL()
+	return b.run(MySQL_exec, query, append(args, b.args...))
+}
+
+func (b *mysqlBuilder) whereSQL() string {
+	if len(b.where) == 0 {
+		return ""
+	}
+	return " WHERE " + gstrings.Join(b.where, " AND ")
+}
+
+// selectSQL builds the SELECT, columns are an optional array argument
+func (b *mysqlBuilder) selectSQL(fc goja.FunctionCall) (string, error) {
+	columns := "*"
+	if len(fc.Arguments) > 0 && !goja.IsUndefined(fc.Arguments[0]) {
+		names, ok := fc.Arguments[0].Export().([]interface{})
+		if !ok {
+			names = []interface{}{fc.Arguments[0].String()}
+		}
+		quoted := make([]string, len(names))
+		for i, name := range names {
+			column, err := quoteIdentifier(fmt.Sprint(name))
+			if err != nil {
+				return "", err
+			}
+			quoted[i] = column
+		}
+		if len(quoted) > 0 {
+			columns = gstrings.Join(quoted, ", ")
+		}
+	}
+	query := "SELECT " + columns + " FROM " + b.table + b.whereSQL()
+	if len(b.orderBy) > 0 {
+		query += " ORDER BY " + gstrings.Join(b.orderBy, ", ")
+	}
+	if b.limit >= 0 {
+		query += fmt.Sprintf(" LIMIT %d", b.limit)
+	}
+	if b.offset >= 0 {
+		if b.limit < 0 {
+			query += " LIMIT 18446744073709551615"
+		}
+		query += fmt.Sprintf(" OFFSET %d", b.offset)
+	}
+	return query, nil
+}
+
+// insertSQL builds a multi-row INSERT; the columns are those of the first
+// row, missing values are NULL. upsert updates the columns of its second
+// argument, all inserted columns by default.
+func (b *mysqlBuilder) insertSQL(fc goja.FunctionCall, upsert bool) (string, []interface{}, error) {
+	if len(fc.Arguments) == 0 {
+		return "", nil, fmt.Errorf("usage: insert(row | [rows])")
+	}
+	var rows []*goja.Object
+	if _, ok := fc.Arguments[0].Export().([]interface{}); ok {
+		for _, value := range arrayArg(b.rt, fc.Arguments[0]) {
+			if !isObjectArg(value) {
+				return "", nil, fmt.Errorf("insert expects objects")
+			}
+			rows = append(rows, value.ToObject(b.rt))
+		}
+	} else if isObjectArg(fc.Arguments[0]) {
+		rows = append(rows, fc.Arguments[0].ToObject(b.rt))
+	}
+	if len(rows) == 0 || len(rows[0].Keys()) == 0 {
+		return "", nil, fmt.Errorf("insert requires at least one row with columns")
+	}
+
+	keys := rows[0].Keys()
+	columns := make([]string, len(keys))
+	for i, key := range keys {
+		column, err := quoteIdentifier(key)
+		if err != nil {
+			return "", nil, err
+		}
+		columns[i] = column
+	}
+	placeholder := "(" + gstrings.TrimSuffix(gstrings.Repeat("?, ", len(keys)), ", ") + ")"
+	values := make([]string, len(rows))
+	var args []interface{}
+	for i, row := range rows {
+		values[i] = placeholder
+		for _, key := range keys {
+			value, err := columnValue(row.Get(key))
+			if err != nil {
+				return "", nil, err
+			}
+			args = append(args, value)
+		}
+	}
+	query := "INSERT INTO " + b.table + " (" + gstrings.Join(columns, ", ") + ") VALUES " + gstrings.Join(values, ", ")
+
+	if upsert {
+		updates := columns
+		if len(fc.Arguments) > 1 && !goja.IsUndefined(fc.Arguments[1]) {
+			updates = nil
+			for _, name := range redisStrings(arrayArg(b.rt, fc.Arguments[1])) {
+				column, err := quoteIdentifier(name)
+				if err != nil {
+					return "", nil, err
+				}
+				updates = append(updates, column)
+			}
+		}
+		sets := make([]string, len(updates))
+		for i, column := range updates {
+			sets[i] = column + " = VALUES(" + column + ")"
+		}
+		if len(sets) > 0 {
+			query += " ON DUPLICATE KEY UPDATE " + gstrings.Join(sets, ", ")
+		}
+	}
+	return query, args, nil
+}
+
+// columnValue exports a column value; arrays and objects are stored as JSON
+// (arrays would otherwise be expanded as IN lists)
+func columnValue(value goja.Value) (interface{}, error) {
+	if value == nil || goja.IsUndefined(value) {
+		return nil, nil
+	}
+	switch v := value.Export().(type) {
+	case []interface{}, map[string]interface{}:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return string(data), nil
+	default:
+		return v, nil
+	}
+}
+
+// quoteIdentifier quotes a column or table name (optionally db.table)
+func quoteIdentifier(name string) (string, error) {
+	name = gstrings.TrimSpace(name)
+	if name == "*" {
+		return name, nil
+	}
+	if !mysqlIdentifier.MatchString(name) {
+		return "", fmt.Errorf("invalid identifier %q", name)
+	}
+	return "`" + gstrings.ReplaceAll(name, ".", "`.`") + "`", nil
+}
+
+func intArg(fc goja.FunctionCall, index int) int64 {
+	if len(fc.Arguments) > index && !goja.IsUndefined(fc.Arguments[index]) {
+		return fc.Arguments[index].ToInteger()
+	}
+	return -1
+}
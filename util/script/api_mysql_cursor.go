@@ -0,0 +1,222 @@
+package script
+
+import (
+	"fmt"
+	"main/util/mysql"
+	"regexp"
+	gstrings "strings"
+
+	"github.com/dop251/goja"
+	"github.com/puzpuzpuz/xsync/v4"
+)
+
+const (
+	MYSQL_PAGE_SIZE   = 100 // mysql.page 默认每页行数
+	MYSQL_CURSOR_SIZE = 100 // cursor.next() 默认读取行数
+)
+
+var mysqlColumnName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// 脚本打开的游标：运行时 -> 游标及打开它的客户端或事务。
+// 脚本结束、事务提交或回滚前关闭，避免脚本异常或忘记 close 时一直占用连接
+var mysqlCursors = xsync.NewMap[*goja.Runtime, []mysqlOpenCursor]()
+
+type mysqlOpenCursor struct {
+	cursor   *mysql.Cursor
+	executor mysql.Executor
+}
+
+func trackCursor(rt *goja.Runtime, executor mysql.Executor, cursor *mysql.Cursor) {
+	mysqlCursors.Compute(rt, func(cursors []mysqlOpenCursor, _ bool) ([]mysqlOpenCursor, xsync.ComputeOp) {
+		return append(cursors, mysqlOpenCursor{cursor, executor}), xsync.UpdateOp
+	})
+}
+
+// closeCursors closes the cursors of rt opened on executor, or all of them
+// when executor is nil
+func closeCursors(rt *goja.Runtime, executor mysql.Executor) {
+	var closing []*mysql.Cursor
+	mysqlCursors.Compute(rt, func(cursors []mysqlOpenCursor, loaded bool) ([]mysqlOpenCursor, xsync.ComputeOp) {
+		if !loaded {
+			return nil, xsync.CancelOp
+		}
+		kept := cursors[:0]
+		for _, open := range cursors {
+			if executor == nil || open.executor == executor {
+				closing = append(closing, open.cursor)
+			} else {
+				kept = append(kept, open)
+			}
+		}
+		if len(kept) == 0 {
+			return nil, xsync.DeleteOp
+		}
+		return kept, xsync.UpdateOp
+	})
+	for _, cursor := range closing {
+		cursor.Close()
+	}
+}
+
+// MySQL_each calls a function for every row without loading the whole result
+// Usage in JS:
+//
+//	mysql.each("SELECT * FROM log WHERE day = ?", ["2024-01-01"], function(row, index) {
+//	  ...                      // return false to stop
+//	});
+//
+// Options and database selection are as in mysql.query, maxRows does not
+// apply. Returns the number of rows visited.
+func MySQL_each(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+	request, err := parseMySQLCall(rt, call, "each")
+	if err != nil {
+		return nil, err
+	}
+	if request.callback == nil {
+		return nil, fmt.Errorf("mysql.each requires a callback function")
+	}
+
+	count := 0
+	err = request.executor.Each(request.query, request.args, func(row map[string]interface{}) (bool, error) {
+		ret, err := request.callback(goja.Undefined(), mysqlRow(rt, row), rt.ToValue(count))
+		count++
+		if err != nil {
+			return false, err
+		}
+		return ret == nil || goja.IsUndefined(ret) || ret.ToBoolean(), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rt.ToValue(count), nil
+}
+
+// MySQL_cursor opens a cursor over the rows of a query
+// Usage in JS:
+//
+//	var cursor = mysql.cursor("SELECT * FROM log", []);
+//	try {
+//	  for (var rows = cursor.next(500); rows.length > 0; rows = cursor.next(500)) { ... }
+//	} finally {
+//	  cursor.close();
+//	}
+//
+// next(n) returns up to n rows (default 100), an empty array at the end.
+// The cursor holds a connection until it is closed or read to the end (at
+// most 5 minutes); cursors still open are closed when the script ends, and
+// cursors of a transaction before it commits or rolls back.
+func MySQL_cursor(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+	request, err := parseMySQLCall(rt, call, "cursor")
+	if err != nil {
+		return nil, err
+	}
+	cursor, err := request.executor.Cursor(request.query, request.args...)
+	if err != nil {
+		return nil, fmt.Errorf("query execution failed: %w", err)
+	}
+	trackCursor(rt, request.executor, cursor)
+
+	obj := rt.NewObject()
+	obj.Set("columns", cursor.Columns())
+	obj.Set("next", func(fc goja.FunctionCall) goja.Value {
+		n := MYSQL_CURSOR_SIZE
+		if len(fc.Arguments) > 0 && fc.Arguments[0].ToInteger() > 0 {
+			n = int(fc.Arguments[0].ToInteger())
+		}
+		results, err := cursor.Next(n)
+		if err != nil {
+			panic(rt.NewGoError(err))
+		}
+		rows := make([]interface{}, len(results))
+		for i, row := range results {
+			rows[i] = mysqlRow(rt, row)
+		}
+		return rt.ToValue(rows)
+	})
+	obj.Set("close", func(fc goja.FunctionCall) goja.Value {
+		cursor.Close()
+		return goja.Undefined()
+	})
+	return obj, nil
+}
+
+// MySQL_page reads one page of a query
+// Usage in JS:
+//
+//	// offset pagination, page starts at 1; total adds a COUNT(*) query
+//	mysql.page("SELECT * FROM log ORDER BY id", [], {page: 2, size: 50, total: true})
+//	// -> {rows, page, size, total}
+//
+//	// keyset pagination on a unique column, pass next as after for the next page
+//	mysql.page("SELECT * FROM log", [], {key: "id", after: lastId, size: 50, desc: false})
+//	// -> {rows, size, next}, next is null on the last page
+//
+// The query must not have its own LIMIT; keyset queries are ordered by key.
+func MySQL_page(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+	request, err := parseMySQLCall(rt, call, "page")
+	if err != nil {
+		return nil, err
+	}
+	size := MYSQL_PAGE_SIZE
+	if v := request.option("size"); v != nil && v.ToInteger() > 0 {
+		size = int(v.ToInteger())
+	}
+	if request.maxRows > 0 && size > request.maxRows {
+		size = request.maxRows
+	}
+	query := gstrings.TrimRight(gstrings.TrimSpace(request.query), ";")
+	result := map[string]interface{}{"size": size}
+
+	page := *request
+	page.maxRows = 0
+	if key := request.option("key"); key != nil {
+		column := key.String()
+		if !mysqlColumnName.MatchString(column) {
+			return nil, fmt.Errorf("mysql.page: invalid key column %q", column)
+		}
+		op, order := ">", "ASC"
+		if boolOption(request.options, "desc") {
+			op, order = "<", "DESC"
+		}
+		page.query = fmt.Sprintf("SELECT * FROM (%s) AS _page", query)
+		page.args = append([]interface{}(nil), request.args...)
+		if after := request.option("after"); after != nil {
+			page.query += fmt.Sprintf(" WHERE `%s` %s ?", column, op)
+			page.args = append(page.args, after.Export())
+		}
+		page.query += fmt.Sprintf(" ORDER BY `%s` %s LIMIT %d", column, order, size)
+
+		rows, err := page.queryRows(rt, "page", 0)
+		if err != nil {
+			return nil, fmt.Errorf("query execution failed: %w", err)
+		}
+		result["rows"] = rows
+		result["next"] = nil
+		if len(rows) == size {
+			result["next"] = rows[len(rows)-1].(*goja.Object).Get(column)
+		}
+		return rt.ToValue(result), nil
+	}
+
+	number := 1
+	if v := request.option("page"); v != nil && v.ToInteger() > 1 {
+		number = int(v.ToInteger())
+	}
+	page.query = fmt.Sprintf("%s LIMIT %d OFFSET %d", query, size, (number-1)*size)
+	rows, err := page.queryRows(rt, "page", 0)
+	if err != nil {
+		return nil, fmt.Errorf("query execution failed: %w", err)
+	}
+	result["rows"] = rows
+	result["page"] = number
+	if boolOption(request.options, "total") {
+		counts, err := request.executor.QueryToMap(fmt.Sprintf("SELECT COUNT(*) AS total FROM (%s) AS _count", query), request.args...)
+		if err != nil {
+			return nil, fmt.Errorf("count query failed: %w", err)
+		}
+		if len(counts) > 0 {
+			result["total"] = counts[0]["total"]
+		}
+	}
+	return rt.ToValue(result), nil
+}
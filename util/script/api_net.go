@@ -1,50 +1,90 @@
 package script
 
 import (
+	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"main/util/net"
+	"mime"
+	"net/http"
+	gstrings "strings"
+	"time"
 
 	"github.com/dop251/goja"
 )
 
+const (
+	NET_TIMEOUT = 30 // net.fetch 默认超时秒数
+	NET_RETRIES = 2  // 幂等请求默认重试次数
+)
+
 // RequestOptions holds all the options for a fetch request
 // It encapsulates all parameters that can be passed to the net.fetch API
 type RequestOptions struct {
-	// Method specifies the HTTP method (GET, POST, etc.)
+	// Method specifies the HTTP method (GET, POST, PUT, PATCH, DELETE, HEAD, OPTIONS)
 	Method string
-	// Params contains URL query parameters for GET requests
+	// Params contains URL query parameters
 	Params map[string]string
 	// Headers contains HTTP request headers
 	Headers map[string]string
-	// Body contains the request body for POST requests
+	// Body contains the request body: string, ArrayBuffer / Uint8Array, or an object sent as JSON
 	Body interface{}
-	// Timeout specifies the request timeout in seconds
+	// Form is sent as application/x-www-form-urlencoded
+	Form map[string][]string
+	// Multipart is sent as multipart/form-data
+	Multipart *net.Multipart
+	// Timeout specifies the request timeout in seconds, per attempt
 	Timeout int
+	// Redirect is follow, manual or error; MaxRedirects limits follow
+	Redirect     string
+	MaxRedirects int
+	// TLS configures certificates and verification
+	TLS net.TLSOptions
+	// Retry configures retries with backoff
+	Retry net.RetryOptions
+	// MaxBytes limits the response body size
+	MaxBytes int64
 }
 
 // Net_fetch implements a synchronous HTTP request function for scripts
 // This function provides a JavaScript-friendly API for making HTTP requests
-// from scripts. It supports both GET and POST methods with various options.
+// from scripts.
 //
 // Usage in JS:
 //
 //	net.fetch(url, {
-//	  method: "GET" | "POST", // default is GET if not specified
-//	  params: {key: value},   // URL parameters for GET requests
-//	  headers: {key: value},  // HTTP headers
-//	  body: object | string,  // Request body for POST requests
-//	  timeout: number         // Timeout in seconds (default: 30)
+//	  method: "GET",           // GET, POST, PUT, PATCH, DELETE, HEAD, OPTIONS; default is GET
+//	  params: {key: value},    // URL query parameters
+//	  headers: {key: value},   // HTTP headers
+//	  body: object | string | ArrayBuffer | Uint8Array, // objects are sent as JSON
+//	  form: {key: value | [values]},                    // application/x-www-form-urlencoded
+//	  multipart: {field: "value", file: {filename: "a.csv", contentType: "text/csv", data: string | ArrayBuffer, base64: "..."}},
+//	  timeout: number,         // Timeout in seconds per attempt (default: 30)
+//	  redirect: "follow",      // follow | manual (return the 3xx response) | error
+//	  maxRedirects: 10,
+//	  tls: {insecureSkipVerify: false, caFile: "", certFile: "", keyFile: "", serverName: ""},
+//	  retry: 2 | {count: 2, backoff: 200, maxBackoff: 10000, on: [429, 502, 503, 504], nonIdempotent: false},
+//	  maxBytes: 33554432       // response body limit
 //	})
 //
+// Idempotent requests are retried twice by default on connection errors and
+// 429/502/503/504, with exponential backoff (milliseconds) or the server's
+// Retry-After; POST and PATCH only with nonIdempotent. Connections are
+// pooled and reused across calls.
+//
 // Returns an object with the following properties:
 //
 //	{
-//	  status: number,         // HTTP status code
-//	  headers: object,        // Response headers
-//	  data: string,           // Response body as string
-//	  error: string,          // Error message if request failed, null otherwise
-//	  json: object            // Parsed JSON response (if content-type is application/json)
+//	  status: number,          // HTTP status code
+//	  ok: boolean,             // status is 2xx
+//	  headers: object,         // Response headers, header(name) returns the first value
+//	  url: string,             // final URL after redirects
+//	  attempts: number,
+//	  data: string,            // Response body as string
+//	  error: string,           // Error message if request failed, null otherwise
+//	  json: object,            // Parsed JSON response (application/json, */*+json, or JSON-looking untyped text)
+//	  arrayBuffer(), base64()  // Response body as ArrayBuffer or base64 string
 //	}
 func Net_fetch(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
 	if len(call.Arguments) < 1 {
@@ -55,32 +95,37 @@ func Net_fetch(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
 	urlStr := call.Arguments[0].String()
 
 	// Parse options
-	opts := parseRequestOptions(rt, call)
+	opts, err := parseRequestOptions(rt, call)
+	if err != nil {
+		return nil, fmt.Errorf("net.fetch: %w", err)
+	}
 
 	// Execute the request
-	response := executeRequest(urlStr, opts)
+	response := executeRequest(rt, urlStr, opts)
 
 	// Process the response
-	result := processResponse(response)
+	result := processResponse(rt, response)
 
 	return rt.ToValue(result), nil
 }
 
 // parseRequestOptions extracts and processes options from JavaScript arguments
 // It converts the JavaScript options object into a Go RequestOptions struct
-func parseRequestOptions(rt *goja.Runtime, call goja.FunctionCall) RequestOptions {
+func parseRequestOptions(rt *goja.Runtime, call goja.FunctionCall) (RequestOptions, error) {
 	// Default values
 	opts := RequestOptions{
 		Method:  "GET",
-		Timeout: 30,
+		Timeout: NET_TIMEOUT,
+		Retry:   net.RetryOptions{Retries: NET_RETRIES},
 	}
 
 	// Process options if provided
 	if len(call.Arguments) > 1 && !goja.IsUndefined(call.Arguments[1]) && !goja.IsNull(call.Arguments[1]) {
 		options := call.Arguments[1].ToObject(rt)
 		if options != nil {
+			var err error
 			// Extract method
-			opts.Method = extractStringOption(rt, options, "method", opts.Method)
+			opts.Method = gstrings.ToUpper(extractStringOption(rt, options, "method", opts.Method))
 
 			// Extract params
 			opts.Params = extractMapOption(rt, options, "params")
@@ -88,15 +133,45 @@ func parseRequestOptions(rt *goja.Runtime, call goja.FunctionCall) RequestOption
 			// Extract headers
 			opts.Headers = extractMapOption(rt, options, "headers")
 
-			// Extract body
-			opts.Body = extractBodyOption(rt, options)
+			// Extract body, form or multipart
+			if opts.Body, err = extractBodyOption(rt, options); err != nil {
+				return opts, err
+			}
+			opts.Form = extractFormOption(rt, options)
+			if opts.Multipart, err = extractMultipartOption(rt, options); err != nil {
+				return opts, err
+			}
 
 			// Extract timeout
 			opts.Timeout = extractTimeoutOption(rt, options, opts.Timeout)
+
+			opts.Redirect = extractStringOption(rt, options, "redirect", net.REDIRECT_FOLLOW)
+			switch opts.Redirect {
+			case net.REDIRECT_FOLLOW, net.REDIRECT_MANUAL, net.REDIRECT_ERROR:
+			default:
+				return opts, fmt.Errorf("redirect must be follow, manual or error, got %q", opts.Redirect)
+			}
+			if v := optionValue(options, "maxRedirects"); v != nil {
+				opts.MaxRedirects = int(v.ToInteger())
+			}
+			if v := optionValue(options, "maxBytes"); v != nil {
+				opts.MaxBytes = v.ToInteger()
+			}
+			opts.TLS = extractTLSOption(rt, options)
+			opts.Retry = extractRetryOption(rt, options, opts.Retry)
 		}
 	}
 
-	return opts
+	return opts, nil
+}
+
+// optionValue returns an option, nil if it is not set
+func optionValue(options *goja.Object, key string) goja.Value {
+	val := options.Get(key)
+	if val == nil || goja.IsUndefined(val) || goja.IsNull(val) {
+		return nil
+	}
+	return val
 }
 
 // extractStringOption extracts a string option with a default value
@@ -132,17 +207,148 @@ func extractMapOption(rt *goja.Runtime, options *goja.Object, key string) map[st
 }
 
 // extractBodyOption extracts the body option
-// It handles both string and object body types from JavaScript
-func extractBodyOption(rt *goja.Runtime, options *goja.Object) interface{} {
+// Strings are sent as text, ArrayBuffers and typed arrays as bytes, other
+// values as JSON
+func extractBodyOption(rt *goja.Runtime, options *goja.Object) (interface{}, error) {
 	val := options.Get("body")
 	if val == nil || goja.IsUndefined(val) || goja.IsNull(val) {
-		return nil
+		return nil, nil
 	}
 
+	if data, ok := exportBytes(val); ok {
+		return data, nil
+	}
 	if val.ExportType().Kind().String() == "string" {
-		return val.String()
+		return val.String(), nil
+	}
+	return val.Export(), nil
+}
+
+// exportBytes returns the bytes of an ArrayBuffer or Uint8Array
+func exportBytes(val goja.Value) ([]byte, bool) {
+	switch v := val.Export().(type) {
+	case goja.ArrayBuffer:
+		return v.Bytes(), true
+	case []byte:
+		return v, true
+	}
+	return nil, false
+}
+
+// extractFormOption extracts form fields; array values repeat the field
+func extractFormOption(rt *goja.Runtime, options *goja.Object) map[string][]string {
+	val := optionValue(options, "form")
+	if val == nil {
+		return nil
+	}
+	form := make(map[string][]string)
+	obj := val.ToObject(rt)
+	for _, k := range obj.Keys() {
+		item := obj.Get(k)
+		if list, ok := item.Export().([]interface{}); ok {
+			for _, v := range list {
+				form[k] = append(form[k], fmt.Sprint(v))
+			}
+			continue
+		}
+		form[k] = append(form[k], item.String())
+	}
+	return form
+}
+
+// extractMultipartOption extracts multipart fields and files: strings are
+// fields, objects with data or base64 are files, arrays repeat the field
+func extractMultipartOption(rt *goja.Runtime, options *goja.Object) (*net.Multipart, error) {
+	val := optionValue(options, "multipart")
+	if val == nil {
+		return nil, nil
 	}
-	return val.Export()
+	body := &net.Multipart{Fields: make(map[string]string)}
+	obj := val.ToObject(rt)
+	for _, k := range obj.Keys() {
+		items := []goja.Value{obj.Get(k)}
+		if arr, ok := obj.Get(k).(*goja.Object); ok && arr.ClassName() == "Array" {
+			items = items[:0]
+			for _, i := range arr.Keys() {
+				items = append(items, arr.Get(i))
+			}
+		}
+		for _, item := range items {
+			file, ok := item.(*goja.Object)
+			if !ok || (optionValue(file, "data") == nil && optionValue(file, "base64") == nil) {
+				body.Fields[k] = item.String()
+				continue
+			}
+			part := net.MultipartFile{
+				Field:       k,
+				Filename:    extractStringOption(rt, file, "filename", k),
+				ContentType: extractStringOption(rt, file, "contentType", ""),
+			}
+			if encoded := optionValue(file, "base64"); encoded != nil {
+				data, err := base64.StdEncoding.DecodeString(encoded.String())
+				if err != nil {
+					return nil, fmt.Errorf("multipart %s: invalid base64: %w", k, err)
+				}
+				part.Data = data
+			} else if data, ok := exportBytes(file.Get("data")); ok {
+				part.Data = data
+			} else {
+				part.Data = []byte(file.Get("data").String())
+			}
+			body.Files = append(body.Files, part)
+		}
+	}
+	return body, nil
+}
+
+// extractTLSOption extracts the tls option
+func extractTLSOption(rt *goja.Runtime, options *goja.Object) net.TLSOptions {
+	val := optionValue(options, "tls")
+	if val == nil {
+		return net.TLSOptions{}
+	}
+	obj := val.ToObject(rt)
+	return net.TLSOptions{
+		InsecureSkipVerify: boolOption(obj, "insecureSkipVerify"),
+		CAFile:             extractStringOption(rt, obj, "caFile", ""),
+		CertFile:           extractStringOption(rt, obj, "certFile", ""),
+		KeyFile:            extractStringOption(rt, obj, "keyFile", ""),
+		ServerName:         extractStringOption(rt, obj, "serverName", ""),
+	}
+}
+
+// extractRetryOption extracts retry: a number of retries or an object
+func extractRetryOption(rt *goja.Runtime, options *goja.Object, retry net.RetryOptions) net.RetryOptions {
+	val := optionValue(options, "retry")
+	if val == nil {
+		return retry
+	}
+	obj, ok := val.(*goja.Object)
+	if !ok {
+		retry.Retries = max(int(val.ToInteger()), 0)
+		return retry
+	}
+	if v := optionValue(obj, "count"); v != nil {
+		retry.Retries = max(int(v.ToInteger()), 0)
+	}
+	if v := optionValue(obj, "backoff"); v != nil {
+		retry.Backoff = time.Duration(v.ToInteger()) * time.Millisecond
+	}
+	if v := optionValue(obj, "maxBackoff"); v != nil {
+		retry.MaxBackoff = time.Duration(v.ToInteger()) * time.Millisecond
+	}
+	if v := optionValue(obj, "on"); v != nil {
+		retry.StatusCodes = []int{}
+		if list, ok := v.Export().([]interface{}); ok {
+			for _, code := range list {
+				if n, ok := code.(int64); ok {
+					retry.StatusCodes = append(retry.StatusCodes, int(n))
+				}
+			}
+		}
+	}
+	retry.NonIdempotent = boolOption(obj, "nonIdempotent")
+	return retry
 }
 
 // extractTimeoutOption extracts the timeout option with validation
@@ -160,29 +366,49 @@ func extractTimeoutOption(rt *goja.Runtime, options *goja.Object, defaultValue i
 	return timeout
 }
 
-// executeRequest performs the actual HTTP request based on the method
-// It creates an HTTP client with the specified timeout and executes the request
-func executeRequest(urlStr string, opts RequestOptions) net.HTTPResponse {
-	// Create HTTP client with specified timeout
-	client := net.NewHTTPClient(opts.Timeout)
-
-	// Execute request based on method
-	switch opts.Method {
-	case "POST":
-		return client.Post(urlStr, opts.Body, opts.Headers)
-	default: // Default to GET
-		return client.Get(urlStr, opts.Params, opts.Headers)
-	}
+// executeRequest performs the actual HTTP request on the shared transport,
+// under the egress policy extended by the script's capabilities
+func executeRequest(rt *goja.Runtime, urlStr string, opts RequestOptions) net.HTTPResponse {
+	client := net.NewHTTPClient(opts.Timeout).WithPolicy(net.EGRESS_POLICY.ForCapabilities(ScriptCapabilities(rt)))
+	return client.Do(&net.Request{
+		Method:       opts.Method,
+		URL:          urlStr,
+		Params:       opts.Params,
+		Headers:      opts.Headers,
+		Body:         opts.Body,
+		Form:         opts.Form,
+		Multipart:    opts.Multipart,
+		Redirect:     opts.Redirect,
+		MaxRedirects: opts.MaxRedirects,
+		TLS:          opts.TLS,
+		Retry:        opts.Retry,
+		MaxBytes:     opts.MaxBytes,
+	})
 }
 
 // processResponse converts an HTTP response to a JavaScript-friendly format
 // It creates a map that will be converted to a JavaScript object with status, headers, data, and error
-func processResponse(response net.HTTPResponse) map[string]interface{} {
+func processResponse(rt *goja.Runtime, response net.HTTPResponse) map[string]interface{} {
 	// Create result object
 	result := make(map[string]interface{})
 	result["status"] = response.StatusCode
+	result["ok"] = response.Error == nil && response.StatusCode >= 200 && response.StatusCode < 300
 	result["headers"] = response.Headers
-	result["data"] = response.Body
+	result["url"] = response.URL
+	result["attempts"] = response.Attempts
+	result["data"] = response.Text()
+	result["header"] = func(name string) interface{} {
+		if values := http.Header(response.Headers).Values(name); len(values) > 0 {
+			return values[0]
+		}
+		return nil
+	}
+	result["arrayBuffer"] = func() goja.ArrayBuffer {
+		return rt.NewArrayBuffer(response.Body)
+	}
+	result["base64"] = func() string {
+		return base64.StdEncoding.EncodeToString(response.Body)
+	}
 
 	// Handle error if any
 	if response.Error != nil {
@@ -191,25 +417,37 @@ func processResponse(response net.HTTPResponse) map[string]interface{} {
 		result["error"] = nil
 	}
 
-	// Try to parse JSON response if content-type is application/json
+	// Try to parse JSON response
 	processJsonResponse(response, result)
 
 	return result
 }
 
 // processJsonResponse attempts to parse JSON responses and add them to the result
-// If the response has a JSON content type, it will try to parse the body as JSON
-// and add it to the result map as the 'json' property
+// JSON media types (application/json, text/json, */*+json) are parsed; an
+// untyped or text/plain body is parsed when it looks like a JSON object or array
 func processJsonResponse(response net.HTTPResponse, result map[string]interface{}) {
-	contentType := ""
-	if ct, ok := response.Headers["Content-Type"]; ok && len(ct) > 0 {
-		contentType = ct[0]
+	if len(response.Body) == 0 {
+		return
+	}
+	mediaType := ""
+	if ct := http.Header(response.Headers).Get("Content-Type"); ct != "" {
+		mediaType, _, _ = mime.ParseMediaType(ct)
 	}
 
-	if contentType == "application/json" || contentType == "application/json; charset=utf-8" {
-		var jsonData interface{}
-		if err := json.Unmarshal([]byte(response.Body), &jsonData); err == nil {
-			result["json"] = jsonData
+	switch {
+	case mediaType == "application/json" || mediaType == "text/json" || gstrings.HasSuffix(mediaType, "+json"):
+	case mediaType == "" || mediaType == "text/plain":
+		trimmed := bytes.TrimSpace(response.Body)
+		if len(trimmed) == 0 || (trimmed[0] != '{' && trimmed[0] != '[') {
+			return
 		}
+	default:
+		return
+	}
+
+	var jsonData interface{}
+	if err := json.Unmarshal(response.Body, &jsonData); err == nil {
+		result["json"] = jsonData
 	}
 }
@@ -1,80 +1,444 @@
 package script
 
 import (
-	"context"
+	"encoding/json"
+	"fmt"
 	"main/util"
+	"time"
 
 	"github.com/dop251/goja"
 )
 
-// Redis_set (group, key, value)
-func Redis_set(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
-	var group string
-	var key string
-	var value string
-	// var outputBuffer strings.Builder
-	if len(call.Arguments) < 2 {
-		return goja.Undefined(), nil
-	} else if len(call.Arguments) == 2 {
-		key = call.Arguments[0].String()
-		value = call.Arguments[1].String()
-		util.RedisData.Set(key, value)
-	} else {
-		group = call.Arguments[0].String()
-		key = call.Arguments[1].String()
-		value = call.Arguments[2].String()
-		util.RedisData.SetHValue(group, key, value)
-	}
-
-	return goja.Undefined(), nil
-}
-
-// Redis_get (group, key) -> string
-func Redis_get(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
-	var group string
-	var key string
-	var value string
-	var err error
-	if len(call.Arguments) < 2 {
-		return goja.Undefined(), nil
-	} else if len(call.Arguments) == 2 {
-		group = call.Arguments[0].String()
-		key = call.Arguments[1].String()
-		value, err = util.RedisData.GetHValue(group, key)
+const (
+	REDIS_CLIENT_DATA   = "data"   // util.RedisData，业务数据
+	REDIS_CLIENT_CONFIG = "config" // util.RedisConfig，脚本与配置
+	redisClientProperty = "__client"
+)
+
+// redisClient returns the client selected by redis.use(), RedisData by default
+func redisClient(rt *goja.Runtime, call goja.FunctionCall) (util.KVStore, error) {
+	name := REDIS_CLIENT_DATA
+	if this, ok := call.This.(*goja.Object); ok {
+		if v := this.Get(redisClientProperty); v != nil && !goja.IsUndefined(v) {
+			name = v.String()
+		}
+	}
+	return getRedisClient(name)
+}
+
+func getRedisClient(name string) (util.KVStore, error) {
+	var client util.KVStore
+	switch name {
+	case REDIS_CLIENT_DATA:
+		client = util.RedisData
+	case REDIS_CLIENT_CONFIG:
+		client = util.RedisConfig
+	default:
+		return nil, fmt.Errorf("unknown redis client %q, use %q or %q", name, REDIS_CLIENT_DATA, REDIS_CLIENT_CONFIG)
+	}
+	if client == nil {
+		return nil, fmt.Errorf("redis client %q is not connected", name)
+	}
+	return client, nil
+}
+
+// redisArgs checks the argument count and returns the client to use
+func redisArgs(rt *goja.Runtime, call goja.FunctionCall, min int, usage string) (util.KVStore, error) {
+	if len(call.Arguments) < min {
+		return nil, fmt.Errorf("usage: redis.%s", usage)
+	}
+	return redisClient(rt, call)
+}
+
+// redisArg converts a JS value to a Redis argument: strings and numbers as
+// is, objects and arrays as JSON
+func redisArg(value goja.Value) (interface{}, error) {
+	if value == nil || goja.IsUndefined(value) || goja.IsNull(value) {
+		return "", nil
+	}
+	switch v := value.Export().(type) {
+	case string, int64, float64, bool:
+		return v, nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	default:
+		data, err := json.Marshal(v)
 		if err != nil {
-			return goja.Undefined(), nil
+			return nil, fmt.Errorf("cannot store %s in redis: %w", value.String(), err)
 		}
-	} else {
-		key = call.Arguments[0].String()
-		value, err = util.RedisData.Get(key)
+		return string(data), nil
+	}
+}
+
+func redisArgList(values []goja.Value) ([]interface{}, error) {
+	args := make([]interface{}, 0, len(values))
+	for _, value := range values {
+		arg, err := redisArg(value)
 		if err != nil {
-			return goja.Undefined(), nil
+			return nil, err
 		}
+		args = append(args, arg)
 	}
+	return args, nil
+}
 
-	return rt.ToValue(value), nil
+func redisStrings(values []goja.Value) []string {
+	result := make([]string, len(values))
+	for i, value := range values {
+		result[i] = value.String()
+	}
+	return result
 }
 
-func Redis_keys(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+// redisValue returns null for missing keys and throws other errors
+func redisValue(rt *goja.Runtime, value interface{}, err error) (goja.Value, error) {
+	if err == util.ErrNil {
+		return goja.Null(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rt.ToValue(toJSValue(value)), nil
+}
+
+// toJSValue converts raw command replies (RESP3 maps included) to values
+// goja can expose to scripts
+func toJSValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = toJSValue(item)
+		}
+		return result
+	case map[interface{}]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			result[fmt.Sprint(key)] = toJSValue(item)
+		}
+		return result
+	default:
+		return v
+	}
+}
+
+func isObjectArg(value goja.Value) bool {
+	_, ok := value.Export().(map[string]interface{})
+	return ok
+}
+
+// Redis_use returns the redis module bound to another client
+// Usage: const cfg = redis.use("config"); cfg.hgetall("DEVICE_TYPE")
+func Redis_use(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
 	if len(call.Arguments) < 1 {
-		return goja.Undefined(), nil
+		return nil, fmt.Errorf("usage: redis.use(\"data\" | \"config\")")
+	}
+	name := call.Arguments[0].String()
+	if _, err := getRedisClient(name); err != nil {
+		return nil, err
+	}
+	module := rt.Get("redis")
+	if module == nil || goja.IsUndefined(module) {
+		return nil, fmt.Errorf("redis module is not available")
+	}
+	source := module.ToObject(rt)
+	obj := rt.NewObject()
+	for _, key := range source.Keys() {
+		obj.Set(key, source.Get(key))
+	}
+	obj.Set(redisClientProperty, name)
+	return obj, nil
+}
+
+// Redis_set writes a string key, or a hash field with a group
+// Usage: redis.set(key, value, [{ex: seconds, nx: true}]) or redis.set(group, key, value)
+// Returns false if nx prevented the write. An object third argument is always
+// options; objects are written to hash fields with redis.hset.
+func Redis_set(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+	rc, err := redisArgs(rt, call, 2, "set(key, value, [options]) or redis.set(group, key, value)")
+	if err != nil {
+		return nil, err
+	}
+
+	// 第三个参数为对象时视为选项，只能包含 ex/px/nx；否则可能是想把对象写入哈希字段
+	if len(call.Arguments) >= 3 && isObjectArg(call.Arguments[2]) {
+		for _, name := range call.Arguments[2].ToObject(rt).Keys() {
+			if name != "ex" && name != "px" && name != "nx" {
+				return nil, fmt.Errorf("redis.set: unknown option %q, the options are {ex, px, nx}; use redis.hset(group, key, value) to store an object in a hash field", name)
+			}
+		}
+	}
+
+	if len(call.Arguments) >= 3 && !isObjectArg(call.Arguments[2]) {
+		group := call.Arguments[0].String()
+		key := call.Arguments[1].String()
+		value, err := redisArg(call.Arguments[2])
+		if err != nil {
+			return nil, err
+		}
+		if err := rc.SetHValue(group, key, fmt.Sprint(value)); err != nil {
+			return nil, err
+		}
+		return rt.ToValue(true), nil
+	}
+
+	key := call.Arguments[0].String()
+	value, err := redisArg(call.Arguments[1])
+	if err != nil {
+		return nil, err
+	}
+	var ttl time.Duration
+	var nx bool
+	if len(call.Arguments) >= 3 {
+		options := call.Arguments[2].ToObject(rt)
+		if ex := options.Get("ex"); ex != nil && !goja.IsUndefined(ex) {
+			ttl = time.Duration(ex.ToFloat() * float64(time.Second))
+		}
+		if px := options.Get("px"); px != nil && !goja.IsUndefined(px) {
+			ttl = time.Duration(px.ToInteger()) * time.Millisecond
+		}
+		nx = options.Get("nx") != nil && options.Get("nx").ToBoolean()
+	}
+	ok, err := rc.SetArgs(key, fmt.Sprint(value), ttl, nx)
+	if err != nil {
+		return nil, err
+	}
+	return rt.ToValue(ok), nil
+}
+
+// Redis_get reads a string key, or a hash field with a group
+// Usage: redis.get(key) or redis.get(group, key) -> string | null
+func Redis_get(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+	rc, err := redisArgs(rt, call, 1, "get(key) or redis.get(group, key)")
+	if err != nil {
+		return nil, err
+	}
+	if len(call.Arguments) >= 2 {
+		value, err := rc.GetHValue(call.Arguments[0].String(), call.Arguments[1].String())
+		return redisValue(rt, value, err)
+	}
+	value, err := rc.Get(call.Arguments[0].String())
+	return redisValue(rt, value, err)
+}
+
+// Redis_del deletes keys
+// Usage: redis.del(key1, [key2, ...]) -> number of deleted keys
+func Redis_del(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+	rc, err := redisArgs(rt, call, 1, "del(key, ...)")
+	if err != nil {
+		return nil, err
+	}
+	count, err := rc.Del(redisStrings(call.Arguments)...)
+	return redisValue(rt, count, err)
+}
+
+// Redis_exists counts the keys that exist
+// Usage: redis.exists(key1, [key2, ...]) -> number
+func Redis_exists(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+	rc, err := redisArgs(rt, call, 1, "exists(key, ...)")
+	if err != nil {
+		return nil, err
+	}
+	count, err := rc.Exists(redisStrings(call.Arguments)...)
+	return redisValue(rt, count, err)
+}
+
+// Redis_expire sets the time to live of a key in seconds
+// Usage: redis.expire(key, seconds) -> false if the key does not exist
+func Redis_expire(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+	rc, err := redisArgs(rt, call, 2, "expire(key, seconds)")
+	if err != nil {
+		return nil, err
+	}
+	ttl := time.Duration(call.Arguments[1].ToFloat() * float64(time.Second))
+	ok, err := rc.Expire(call.Arguments[0].String(), ttl)
+	return redisValue(rt, ok, err)
+}
+
+// Redis_persist removes the time to live of a key
+// Usage: redis.persist(key) -> bool
+func Redis_persist(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+	rc, err := redisArgs(rt, call, 1, "persist(key)")
+	if err != nil {
+		return nil, err
 	}
-	group := call.Arguments[0].Export().(string)
-	keys, err := util.RedisData.HKeys(group)
+	ok, err := rc.Persist(call.Arguments[0].String())
+	return redisValue(rt, ok, err)
+}
+
+// Redis_ttl returns the time to live of a key in seconds, -1 if it has no
+// expiry and -2 if it does not exist
+// Usage: redis.ttl(key) -> number
+func Redis_ttl(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+	rc, err := redisArgs(rt, call, 1, "ttl(key)")
 	if err != nil {
-		return rt.ToValue(""), nil
+		return nil, err
 	}
-	return rt.ToValue(keys), nil
+	ttl, err := rc.TTL(call.Arguments[0].String())
+	if err != nil {
+		return nil, err
+	}
+	if ttl < 0 {
+		// go-redis 对 -1 / -2 原样返回（未乘以秒）
+		return rt.ToValue(int64(ttl)), nil
+	}
+	return rt.ToValue(int64(ttl / time.Second)), nil
+}
+
+// Redis_incr increments a counter, by 1 or the given (possibly fractional) amount
+// Usage: redis.incr(key, [by]) -> new value
+func Redis_incr(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+	return redisIncr(rt, call, "incr", 1)
 }
 
+// Redis_decr decrements a counter
+// Usage: redis.decr(key, [by]) -> new value
+func Redis_decr(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+	return redisIncr(rt, call, "decr", -1)
+}
+
+func redisIncr(rt *goja.Runtime, call goja.FunctionCall, name string, sign int64) (goja.Value, error) {
+	rc, err := redisArgs(rt, call, 1, name+"(key, [by])")
+	if err != nil {
+		return nil, err
+	}
+	key := call.Arguments[0].String()
+	by := int64(1)
+	if len(call.Arguments) > 1 {
+		switch v := call.Arguments[1].Export().(type) {
+		case int64:
+			by = v
+		case float64:
+			value, err := rc.IncrByFloat(key, float64(sign)*v)
+			return redisValue(rt, value, err)
+		default:
+			return nil, fmt.Errorf("redis.%s: amount must be a number", name)
+		}
+	}
+	value, err := rc.IncrBy(key, sign*by)
+	return redisValue(rt, value, err)
+}
+
+// Redis_keys returns the fields of a hash
+// Usage: redis.keys(group) -> array
+func Redis_keys(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+	rc, err := redisArgs(rt, call, 1, "keys(group)")
+	if err != nil {
+		return nil, err
+	}
+	keys, err := rc.HKeys(call.Arguments[0].String())
+	return redisValue(rt, keys, err)
+}
+
+// Redis_hgetall returns all fields of a hash
+// Usage: redis.hgetall(key) -> object
 func Redis_hgetall(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
-	if len(call.Arguments) < 1 {
-		return goja.Undefined(), nil
+	rc, err := redisArgs(rt, call, 1, "hgetall(key)")
+	if err != nil {
+		return nil, err
 	}
-	group := call.Arguments[0].Export().(string)
-	cmd := util.RedisData.Client.HGetAll(context.Background(), group)
-	if cmd.Err() != nil {
-		return rt.ToValue(""), nil
+	values, err := rc.HGetAll(call.Arguments[0].String())
+	return redisValue(rt, values, err)
+}
+
+// Redis_hget reads a hash field
+// Usage: redis.hget(key, field) -> string | null
+func Redis_hget(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+	rc, err := redisArgs(rt, call, 2, "hget(key, field)")
+	if err != nil {
+		return nil, err
+	}
+	value, err := rc.GetHValue(call.Arguments[0].String(), call.Arguments[1].String())
+	return redisValue(rt, value, err)
+}
+
+// Redis_hset writes hash fields
+// Usage: redis.hset(key, field, value) or redis.hset(key, {field: value, ...})
+func Redis_hset(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+	rc, err := redisArgs(rt, call, 2, "hset(key, field, value) or redis.hset(key, {field: value})")
+	if err != nil {
+		return nil, err
+	}
+	if len(call.Arguments) == 2 {
+		return Redis_hmset(rt, call)
+	}
+	value, err := redisArg(call.Arguments[2])
+	if err != nil {
+		return nil, err
+	}
+	err = rc.HMSet(call.Arguments[0].String(), map[string]interface{}{call.Arguments[1].String(): value})
+	return redisValue(rt, err == nil, err)
+}
+
+// Redis_hmset writes several hash fields
+// Usage: redis.hmset(key, {field: value, ...})
+func Redis_hmset(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+	rc, err := redisArgs(rt, call, 2, "hmset(key, {field: value})")
+	if err != nil {
+		return nil, err
+	}
+	source := call.Arguments[1].ToObject(rt)
+	values := make(map[string]interface{})
+	for _, field := range source.Keys() {
+		if values[field], err = redisArg(source.Get(field)); err != nil {
+			return nil, err
+		}
+	}
+	if len(values) == 0 {
+		return rt.ToValue(false), nil
+	}
+	err = rc.HMSet(call.Arguments[0].String(), values)
+	return redisValue(rt, err == nil, err)
+}
+
+// Redis_hmget reads several hash fields
+// Usage: redis.hmget(key, field1, [field2, ...]) -> array, null for missing fields
+func Redis_hmget(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+	rc, err := redisArgs(rt, call, 2, "hmget(key, field, ...)")
+	if err != nil {
+		return nil, err
+	}
+	values, err := rc.HMGet(call.Arguments[0].String(), redisStrings(call.Arguments[1:])...)
+	return redisValue(rt, values, err)
+}
+
+// Redis_hdel deletes hash fields
+// Usage: redis.hdel(key, field1, [field2, ...]) -> number of deleted fields
+func Redis_hdel(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+	rc, err := redisArgs(rt, call, 2, "hdel(key, field, ...)")
+	if err != nil {
+		return nil, err
+	}
+	count, err := rc.HDelFields(call.Arguments[0].String(), redisStrings(call.Arguments[1:])...)
+	return redisValue(rt, count, err)
+}
+
+// Redis_hincr increments a hash field
+// Usage: redis.hincr(key, field, [by]) -> new value
+func Redis_hincr(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+	rc, err := redisArgs(rt, call, 2, "hincr(key, field, [by])")
+	if err != nil {
+		return nil, err
+	}
+	by := int64(1)
+	if len(call.Arguments) > 2 {
+		by = call.Arguments[2].ToInteger()
+	}
+	value, err := rc.HIncrBy(call.Arguments[0].String(), call.Arguments[1].String(), by)
+	return redisValue(rt, value, err)
+}
+
+// Redis_command runs any Redis command
+// Usage: redis.command("GETRANGE", key, 0, 3)
+func Redis_command(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+	rc, err := redisArgs(rt, call, 1, "command(name, ...args)")
+	if err != nil {
+		return nil, err
+	}
+	args, err := redisArgList(call.Arguments)
+	if err != nil {
+		return nil, err
 	}
-	return rt.ToValue(cmd.Val()), nil
+	value, err := rc.Do(args...)
+	return redisValue(rt, value, err)
 }
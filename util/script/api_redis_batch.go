@@ -0,0 +1,286 @@
+package script
+
+import (
+	"fmt"
+	"main/util"
+	"strings"
+
+	"github.com/dop251/goja"
+)
+
+const (
+	REDIS_SCAN_LIMIT    = 10000
+	REDIS_WATCH_RETRIES = 3 // redis.multi 在 watch 冲突时默认重试次数
+)
+
+// REDIS_BATCH_COMMANDS are the methods of the pipeline object passed to
+// redis.pipeline / redis.multi, arguments are given in Redis order
+var REDIS_BATCH_COMMANDS = []string{
+	"get", "set", "del", "exists", "expire", "ttl", "persist",
+	"incr", "incrby", "incrbyfloat", "decr", "decrby",
+	"hget", "hset", "hmget", "hdel", "hgetall", "hkeys", "hincrby",
+	"lpush", "rpush", "lpop", "rpop", "lrange", "llen", "ltrim",
+	"sadd", "srem", "scard", "smembers", "sismember",
+	"zadd", "zrem", "zscore", "zincrby", "zcard", "zrange", "zrangebyscore",
+	"publish",
+}
+
+// Redis_scan iterates over keys matching a pattern without blocking the server
+// Usage in JS:
+//
+//	redis.scan("device_*", function(key) { ... })   // return false to stop; returns the number of keys visited
+//	redis.scan("device_*", {type: "hash"})          // all keys (at most limit, default 10000)
+//	redis.scan("device_*", {cursor: 0, count: 100}) // one page: {cursor, keys}, cursor 0 when done (not in cluster mode)
+//
+// Options: type (string, hash, list, set, zset), count (batch size hint), limit
+func Redis_scan(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+	rc, err := redisArgs(rt, call, 1, "scan(pattern, [options], [callback])")
+	if err != nil {
+		return nil, err
+	}
+	pattern := call.Arguments[0].String()
+
+	var options *goja.Object
+	var callback goja.Callable
+	for _, arg := range call.Arguments[1:] {
+		if fn, ok := goja.AssertFunction(arg); ok {
+			callback = fn
+		} else if isObjectArg(arg) {
+			options = arg.ToObject(rt)
+		}
+	}
+	keyType, count, limit := "", int64(100), REDIS_SCAN_LIMIT
+	var cursor goja.Value
+	if options != nil {
+		keyType = extractStringOption(rt, options, "type", "")
+		if v := options.Get("count"); v != nil && !goja.IsUndefined(v) && v.ToInteger() > 0 {
+			count = v.ToInteger()
+		}
+		if v := options.Get("limit"); v != nil && !goja.IsUndefined(v) && v.ToInteger() > 0 {
+			limit = int(v.ToInteger())
+		}
+		if v := options.Get("cursor"); v != nil && !goja.IsUndefined(v) && !goja.IsNull(v) {
+			cursor = v
+		}
+	}
+
+	if cursor != nil {
+		keys, next, err := rc.Scan(uint64(cursor.ToInteger()), pattern, count, keyType)
+		if err != nil {
+			return nil, err
+		}
+		return rt.ToValue(map[string]interface{}{"cursor": next, "keys": keys}), nil
+	}
+
+	result := make([]string, 0)
+	visited := 0
+	var callbackErr error
+	err = rc.ScanAll(pattern, count, keyType, func(key string) bool {
+		visited++
+		if callback == nil {
+			result = append(result, key)
+			return len(result) < limit
+		}
+		ret, err := callback(goja.Undefined(), rt.ToValue(key))
+		if err != nil {
+			callbackErr = err
+			return false
+		}
+		return ret == nil || goja.IsUndefined(ret) || ret.ToBoolean()
+	})
+	if callbackErr != nil {
+		return nil, callbackErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	if callback != nil {
+		return rt.ToValue(visited), nil
+	}
+	return rt.ToValue(result), nil
+}
+
+// Redis_eval runs a Lua script on the server
+// Usage: redis.eval("return redis.call('INCRBY', KEYS[1], ARGV[1])", ["counter"], [5])
+func Redis_eval(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+	rc, err := redisArgs(rt, call, 1, "eval(script, [keys], [args])")
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	var args []interface{}
+	if len(call.Arguments) > 1 {
+		keys = redisStrings(arrayArg(rt, call.Arguments[1]))
+	}
+	if len(call.Arguments) > 2 {
+		if args, err = redisArgList(arrayArg(rt, call.Arguments[2])); err != nil {
+			return nil, err
+		}
+	}
+	value, err := rc.Eval(call.Arguments[0].String(), keys, args...)
+	return redisValue(rt, value, err)
+}
+
+// Redis_publish posts a message to a pub/sub channel
+// Usage: redis.publish(channel, message) -> number of subscribers that received it
+func Redis_publish(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+	rc, err := redisArgs(rt, call, 2, "publish(channel, message)")
+	if err != nil {
+		return nil, err
+	}
+	message, err := redisArg(call.Arguments[1])
+	if err != nil {
+		return nil, err
+	}
+	count, err := rc.Publish(call.Arguments[0].String(), message)
+	return redisValue(rt, count, err)
+}
+
+// Redis_pipeline sends the commands queued by the callback in one round trip
+// Usage in JS:
+//
+//	var results = redis.pipeline(function(p) {
+//	  p.incr("counter");
+//	  p.hset("device_pump", "p1", "{}");
+//	  p.command("EXPIRE", "counter", 60);
+//	});
+//
+// Returns the replies in order; throws if any command failed
+func Redis_pipeline(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+	return redisBatch(rt, call, "pipeline", false)
+}
+
+// Redis_multi is like redis.pipeline but runs the commands atomically in
+// MULTI/EXEC; it is also injected as redis.transaction
+// Usage in JS:
+//
+//	redis.multi(function(p) {
+//	  var stock = Number(redis.get("stock")); // 读取使用普通命令
+//	  if (stock > 0) p.set("stock", stock - 1);
+//	}, {watch: ["stock"], retries: 3});
+//
+// With watch, the callback runs after the keys are watched; if another client
+// changes them before EXEC, the callback is run again (at most retries times,
+// default 3) and an error is thrown when the retries are exhausted.
+func Redis_multi(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+	return redisBatch(rt, call, "multi", true)
+}
+
+func redisBatch(rt *goja.Runtime, call goja.FunctionCall, name string, transaction bool) (goja.Value, error) {
+	rc, err := redisArgs(rt, call, 1, name+"(function(p) { ... })")
+	if err != nil {
+		return nil, err
+	}
+	callback, ok := goja.AssertFunction(call.Arguments[0])
+	if !ok {
+		return nil, fmt.Errorf("redis.%s: first argument must be a function", name)
+	}
+	var watch []string
+	retries := REDIS_WATCH_RETRIES
+	if len(call.Arguments) > 1 && isObjectArg(call.Arguments[1]) {
+		options := call.Arguments[1].ToObject(rt)
+		if v := options.Get("watch"); v != nil && !goja.IsUndefined(v) && !goja.IsNull(v) {
+			watch = redisStrings(arrayArg(rt, v))
+		}
+		if v := options.Get("retries"); v != nil && !goja.IsUndefined(v) && v.ToInteger() >= 0 {
+			retries = int(v.ToInteger())
+		}
+	}
+	if len(watch) > 0 && !transaction {
+		return nil, fmt.Errorf("redis.%s: watch is only supported by redis.multi", name)
+	}
+
+	var commands [][]interface{}
+	queue := func(command string) func(goja.FunctionCall) goja.Value {
+		return func(fc goja.FunctionCall) goja.Value {
+			args := []interface{}{command}
+			for _, arg := range fc.Arguments {
+				values, err := redisArgList(arrayArg(rt, arg))
+				if err != nil {
+					panic(rt.NewGoError(err))
+				}
+				args = append(args, values...)
+			}
+			commands = append(commands, args)
+			return fc.This
+		}
+	}
+	p := rt.NewObject()
+	for _, command := range REDIS_BATCH_COMMANDS {
+		p.Set(command, queue(command))
+	}
+	p.Set("command", func(fc goja.FunctionCall) goja.Value {
+		if len(fc.Arguments) == 0 {
+			panic(rt.NewGoError(fmt.Errorf("usage: p.command(name, ...args)")))
+		}
+		return queue(fc.Arguments[0].String())(goja.FunctionCall{This: fc.This, Arguments: fc.Arguments[1:]})
+	})
+
+	// 回调抛出异常时不执行任何命令
+	build := func() ([][]interface{}, error) {
+		commands = nil
+		if _, err := callback(goja.Undefined(), p); err != nil {
+			return nil, err
+		}
+		return commands, nil
+	}
+
+	var replies []interface{}
+	if len(watch) > 0 {
+		for attempt := 0; ; attempt++ {
+			replies, err = rc.Watch(watch, build)
+			if err != util.ErrTxFailed || attempt >= retries {
+				break
+			}
+		}
+		if err == util.ErrTxFailed {
+			return nil, fmt.Errorf("redis.%s: %w", name, err)
+		}
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		if _, err := build(); err != nil {
+			return nil, err
+		}
+		if len(commands) == 0 {
+			return rt.ToValue([]interface{}{}), nil
+		}
+		if replies, err = rc.ExecBatch(commands, transaction); err != nil {
+			return nil, fmt.Errorf("redis.%s: %w", name, err)
+		}
+	}
+	if len(commands) == 0 {
+		return rt.ToValue([]interface{}{}), nil
+	}
+	for i, reply := range replies {
+		if cmdErr, ok := reply.(error); ok {
+			return nil, fmt.Errorf("redis.%s: command %d (%s) failed: %w", name, i+1, strings.ToUpper(fmt.Sprint(commands[i][0])), cmdErr)
+		}
+		replies[i] = toJSValue(reply)
+	}
+	return rt.ToValue(replies), nil
+}
+
+// arrayArg expands arrays (and hset-style objects into field, value pairs)
+func arrayArg(rt *goja.Runtime, value goja.Value) []goja.Value {
+	switch value.Export().(type) {
+	case []interface{}:
+		obj := value.ToObject(rt)
+		length := int(obj.Get("length").ToInteger())
+		values := make([]goja.Value, length)
+		for i := 0; i < length; i++ {
+			values[i] = obj.Get(fmt.Sprint(i))
+		}
+		return values
+	case map[string]interface{}:
+		obj := value.ToObject(rt)
+		values := make([]goja.Value, 0)
+		for _, key := range obj.Keys() {
+			values = append(values, rt.ToValue(key), obj.Get(key))
+		}
+		return values
+	default:
+		return []goja.Value{value}
+	}
+}
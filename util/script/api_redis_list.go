@@ -0,0 +1,103 @@
+package script
+
+import (
+	"github.com/dop251/goja"
+)
+
+// Redis_lpush prepends values to a list
+// Usage: redis.lpush(key, value1, [value2, ...]) -> length of the list
+func Redis_lpush(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+	rc, err := redisArgs(rt, call, 2, "lpush(key, value, ...)")
+	if err != nil {
+		return nil, err
+	}
+	values, err := redisArgList(call.Arguments[1:])
+	if err != nil {
+		return nil, err
+	}
+	length, err := rc.LPush(call.Arguments[0].String(), values...)
+	return redisValue(rt, length, err)
+}
+
+// Redis_rpush appends values to a list
+// Usage: redis.rpush(key, value1, [value2, ...]) -> length of the list
+func Redis_rpush(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+	rc, err := redisArgs(rt, call, 2, "rpush(key, value, ...)")
+	if err != nil {
+		return nil, err
+	}
+	values, err := redisArgList(call.Arguments[1:])
+	if err != nil {
+		return nil, err
+	}
+	length, err := rc.RPush(call.Arguments[0].String(), values...)
+	return redisValue(rt, length, err)
+}
+
+// Redis_lpop removes and returns the first value of a list
+// Usage: redis.lpop(key) -> string | null
+func Redis_lpop(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+	rc, err := redisArgs(rt, call, 1, "lpop(key)")
+	if err != nil {
+		return nil, err
+	}
+	value, err := rc.LPop(call.Arguments[0].String())
+	return redisValue(rt, value, err)
+}
+
+// Redis_rpop removes and returns the last value of a list
+// Usage: redis.rpop(key) -> string | null
+func Redis_rpop(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+	rc, err := redisArgs(rt, call, 1, "rpop(key)")
+	if err != nil {
+		return nil, err
+	}
+	value, err := rc.RPop(call.Arguments[0].String())
+	return redisValue(rt, value, err)
+}
+
+// Redis_lrange returns a range of a list, negative indexes count from the end
+// Usage: redis.lrange(key, [start = 0], [stop = -1]) -> array
+func Redis_lrange(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+	rc, err := redisArgs(rt, call, 1, "lrange(key, [start], [stop])")
+	if err != nil {
+		return nil, err
+	}
+	start, stop := rangeArgs(call, 1)
+	values, err := rc.LRange(call.Arguments[0].String(), start, stop)
+	return redisValue(rt, values, err)
+}
+
+// Redis_llen returns the length of a list
+// Usage: redis.llen(key) -> number
+func Redis_llen(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+	rc, err := redisArgs(rt, call, 1, "llen(key)")
+	if err != nil {
+		return nil, err
+	}
+	length, err := rc.LLen(call.Arguments[0].String())
+	return redisValue(rt, length, err)
+}
+
+// Redis_ltrim keeps only the given range of a list
+// Usage: redis.ltrim(key, start, stop), e.g. redis.ltrim(key, 0, 99) keeps the first 100
+func Redis_ltrim(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+	rc, err := redisArgs(rt, call, 3, "ltrim(key, start, stop)")
+	if err != nil {
+		return nil, err
+	}
+	err = rc.LTrim(call.Arguments[0].String(), call.Arguments[1].ToInteger(), call.Arguments[2].ToInteger())
+	return redisValue(rt, err == nil, err)
+}
+
+// rangeArgs reads optional start / stop arguments, defaulting to the whole range
+func rangeArgs(call goja.FunctionCall, index int) (int64, int64) {
+	start, stop := int64(0), int64(-1)
+	if len(call.Arguments) > index {
+		start = call.Arguments[index].ToInteger()
+	}
+	if len(call.Arguments) > index+1 {
+		stop = call.Arguments[index+1].ToInteger()
+	}
+	return start, stop
+}
@@ -0,0 +1,173 @@
+package script
+
+import (
+	"fmt"
+	"main/util"
+	"strconv"
+
+	"github.com/dop251/goja"
+)
+
+// Redis_zadd adds members to a sorted set or updates their scores
+// Usage: redis.zadd(key, score, member) or redis.zadd(key, {member: score, ...})
+// Returns the number of added members
+func Redis_zadd(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+	rc, err := redisArgs(rt, call, 2, "zadd(key, score, member) or redis.zadd(key, {member: score})")
+	if err != nil {
+		return nil, err
+	}
+
+	var members []util.ZMember
+	if len(call.Arguments) == 2 {
+		source := call.Arguments[1].ToObject(rt)
+		for _, member := range source.Keys() {
+			members = append(members, util.ZMember{Member: member, Score: source.Get(member).ToFloat()})
+		}
+	} else {
+		members = append(members, util.ZMember{Member: call.Arguments[2].String(), Score: call.Arguments[1].ToFloat()})
+	}
+	if len(members) == 0 {
+		return rt.ToValue(0), nil
+	}
+	count, err := rc.ZAdd(call.Arguments[0].String(), members...)
+	return redisValue(rt, count, err)
+}
+
+// Redis_zrem removes members from a sorted set
+// Usage: redis.zrem(key, member1, [member2, ...]) -> number of removed members
+func Redis_zrem(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+	rc, err := redisArgs(rt, call, 2, "zrem(key, member, ...)")
+	if err != nil {
+		return nil, err
+	}
+	members, err := redisArgList(call.Arguments[1:])
+	if err != nil {
+		return nil, err
+	}
+	count, err := rc.ZRem(call.Arguments[0].String(), members...)
+	return redisValue(rt, count, err)
+}
+
+// Redis_zscore returns the score of a member
+// Usage: redis.zscore(key, member) -> number | null
+func Redis_zscore(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+	rc, err := redisArgs(rt, call, 2, "zscore(key, member)")
+	if err != nil {
+		return nil, err
+	}
+	score, err := rc.ZScore(call.Arguments[0].String(), call.Arguments[1].String())
+	return redisValue(rt, score, err)
+}
+
+// Redis_zincrby increments the score of a member
+// Usage: redis.zincrby(key, increment, member) -> new score
+func Redis_zincrby(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+	rc, err := redisArgs(rt, call, 3, "zincrby(key, increment, member)")
+	if err != nil {
+		return nil, err
+	}
+	score, err := rc.ZIncrBy(call.Arguments[0].String(), call.Arguments[1].ToFloat(), call.Arguments[2].String())
+	return redisValue(rt, score, err)
+}
+
+// Redis_zcard returns the number of members of a sorted set
+// Usage: redis.zcard(key) -> number
+func Redis_zcard(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+	rc, err := redisArgs(rt, call, 1, "zcard(key)")
+	if err != nil {
+		return nil, err
+	}
+	count, err := rc.ZCard(call.Arguments[0].String())
+	return redisValue(rt, count, err)
+}
+
+// Redis_zrange returns members by rank
+// Usage in JS:
+//
+//	redis.zrange(key, [start = 0], [stop = -1], {
+//	  rev: true,       // highest score first
+//	  withScores: true // [{member, score}] instead of member names
+//	})
+func Redis_zrange(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+	rc, err := redisArgs(rt, call, 1, "zrange(key, [start], [stop], [options])")
+	if err != nil {
+		return nil, err
+	}
+	args := call.Arguments
+	var options *goja.Object
+	if last := len(args) - 1; last > 0 && isObjectArg(args[last]) {
+		options = args[last].ToObject(rt)
+		call.Arguments = args[:last]
+	}
+	start, stop := rangeArgs(call, 1)
+	members, err := rc.ZRange(args[0].String(), start, stop, boolOption(options, "rev"))
+	if err != nil {
+		return nil, err
+	}
+	return rt.ToValue(zsetResult(members, boolOption(options, "withScores"))), nil
+}
+
+// Redis_zrangebyscore returns members with min <= score <= max, ordered by score
+// Usage in JS:
+//
+//	redis.zrangebyscore(key, min, max, {  // min / max: number, "-inf", "+inf" or "(5" (exclusive)
+//	  offset: 0, count: 10,               // paging (optional)
+//	  withScores: true
+//	})
+func Redis_zrangebyscore(rt *goja.Runtime, call goja.FunctionCall) (goja.Value, error) {
+	rc, err := redisArgs(rt, call, 3, "zrangebyscore(key, min, max, [options])")
+	if err != nil {
+		return nil, err
+	}
+	var options *goja.Object
+	var offset, count int64
+	if len(call.Arguments) > 3 && isObjectArg(call.Arguments[3]) {
+		options = call.Arguments[3].ToObject(rt)
+		if v := options.Get("offset"); v != nil && !goja.IsUndefined(v) {
+			offset = v.ToInteger()
+		}
+		if v := options.Get("count"); v != nil && !goja.IsUndefined(v) {
+			count = v.ToInteger()
+		}
+	}
+	members, err := rc.ZRangeByScore(call.Arguments[0].String(), formatScore(call.Arguments[1]), formatScore(call.Arguments[2]), offset, count)
+	if err != nil {
+		return nil, err
+	}
+	return rt.ToValue(zsetResult(members, boolOption(options, "withScores"))), nil
+}
+
+func zsetResult(members []util.ZMember, withScores bool) interface{} {
+	if withScores {
+		result := make([]map[string]interface{}, len(members))
+		for i, m := range members {
+			result[i] = map[string]interface{}{"member": m.Member, "score": m.Score}
+		}
+		return result
+	}
+	names := make([]string, len(members))
+	for i, m := range members {
+		names[i] = m.Member
+	}
+	return names
+}
+
+func boolOption(options *goja.Object, key string) bool {
+	if options == nil {
+		return false
+	}
+	v := options.Get(key)
+	return v != nil && v.ToBoolean()
+}
+
+// formatScore formats a score bound, keeping "-inf", "+inf" and "(" prefixes
+func formatScore(value goja.Value) string {
+	switch v := value.Export().(type) {
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -2,6 +2,7 @@ package script
 
 import (
 	"log"
+	"sort"
 	"sync"
 	"time"
 
@@ -20,9 +21,24 @@ type ScriptCache struct {
 type ScriptEntry struct {
 	Name      string
 	Code      string
+	Meta      *ScriptMeta
 	UpdatedAt time.Time
 }
 
+// newScriptEntry creates a cache entry and parses the script metadata
+func newScriptEntry(name string, code string) *ScriptEntry {
+	meta, err := ParseScriptMeta(code)
+	if err != nil {
+		log.Printf("Invalid metadata in script '%s': %v", name, err)
+	}
+	return &ScriptEntry{
+		Name:      name,
+		Code:      code,
+		Meta:      meta,
+		UpdatedAt: time.Now(),
+	}
+}
+
 // Global script cache instance
 var scriptCache *ScriptCache
 
@@ -39,11 +55,7 @@ func (sc *ScriptCache) Initialize() error {
 	var initErr error
 	sc.initOnce.Do(func() {
 		sc.Store.Load(func(scriptName string, scriptCode string) {
-			sc.scripts.Store(scriptName, &ScriptEntry{
-				Name:      scriptName,
-				Code:      scriptCode,
-				UpdatedAt: time.Now(),
-			})
+			sc.scripts.Store(scriptName, newScriptEntry(scriptName, scriptCode))
 		})
 		log.Printf("Script cache initialized with %d scripts", sc.scripts.Size())
 		sc.initialized = true
@@ -66,11 +78,7 @@ func (sc *ScriptCache) GetScript(name string) (string, error) {
 	}
 
 	// Store in cache for future use
-	sc.scripts.Store(name, &ScriptEntry{
-		Name:      name,
-		Code:      code,
-		UpdatedAt: time.Now(),
-	})
+	sc.scripts.Store(name, newScriptEntry(name, code))
 
 	return code, nil
 }
@@ -84,11 +92,7 @@ func (sc *ScriptCache) StoreScript(name string, code string) error {
 	}
 
 	// Then update the cache
-	sc.scripts.Store(name, &ScriptEntry{
-		Name:      name,
-		Code:      code,
-		UpdatedAt: time.Now(),
-	})
+	sc.scripts.Store(name, newScriptEntry(name, code))
 
 	return nil
 }
@@ -144,12 +148,30 @@ func (sc *ScriptCache) ScriptExists(name string) (bool, error) {
 			return true, nil // Still return true since it exists in Redis
 		}
 
-		sc.scripts.Store(name, &ScriptEntry{
-			Name:      name,
-			Code:      code,
-			UpdatedAt: time.Now(),
-		})
+		sc.scripts.Store(name, newScriptEntry(name, code))
 	}
 
 	return exists, nil
 }
+
+// GetMeta returns the metadata of a cached script
+func (sc *ScriptCache) GetMeta(name string) (*ScriptMeta, bool) {
+	entry, ok := sc.scripts.Load(name)
+	if !ok || entry == nil {
+		return nil, false
+	}
+	return entry.Meta, true
+}
+
+// FindSubscribers returns the names of scripts whose metadata subscribes to the event
+func (sc *ScriptCache) FindSubscribers(event string) []string {
+	names := make([]string, 0)
+	sc.scripts.Range(func(name string, entry *ScriptEntry) bool {
+		if entry.Meta != nil && entry.Meta.Subscribes(event) {
+			names = append(names, name)
+		}
+		return true
+	})
+	sort.Strings(names)
+	return names
+}
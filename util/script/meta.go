@@ -0,0 +1,90 @@
+package script
+
+import (
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	META_START = "/*---"
+	META_END   = "---*/"
+)
+
+// StringList accepts either a single string or a list of strings in YAML
+type StringList []string
+
+func (l *StringList) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*l = nil
+		for _, item := range strings.Split(value.Value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*l = append(*l, item)
+			}
+		}
+		return nil
+	}
+	var items []string
+	if err := value.Decode(&items); err != nil {
+		return err
+	}
+	*l = items
+	return nil
+}
+
+// ScriptMeta is the metadata declared in a leading YAML block of a script:
+//
+//	/*---
+//	description: sync device to MES
+//	on: [device.add, device.update]
+//	capabilities: [mes-api]
+//	---*/
+type ScriptMeta struct {
+	Description  string         `yaml:"description,omitempty" json:"description,omitempty"`
+	On           StringList     `yaml:"on,omitempty" json:"on,omitempty"`
+	Migration    *MigrationMeta `yaml:"migration,omitempty" json:"migration,omitempty"`
+	Capabilities StringList     `yaml:"capabilities,omitempty" json:"capabilities,omitempty"` // net.egress.capabilities granted to the script
+}
+
+// MigrationMeta marks a script as a schema migration defining up(db) and
+// optionally down(db):
+//
+//	/*---
+//	migration: {version: 20240105, db: report}
+//	---*/
+type MigrationMeta struct {
+	Version string `yaml:"version" json:"version"`
+	DB      string `yaml:"db,omitempty" json:"db,omitempty"`
+}
+
+// ParseScriptMeta extracts the metadata block from the start of a script.
+// Scripts without a (valid) block get empty metadata.
+func ParseScriptMeta(code string) (*ScriptMeta, error) {
+	meta := &ScriptMeta{}
+	code = strings.TrimLeft(code, " \t\r\n\ufeff")
+	if !strings.HasPrefix(code, META_START) {
+		return meta, nil
+	}
+	end := strings.Index(code, META_END)
+	if end < 0 {
+		return meta, nil
+	}
+	if err := yaml.Unmarshal([]byte(code[len(META_START):end]), meta); err != nil {
+		return &ScriptMeta{}, err
+	}
+	return meta, nil
+}
+
+// Subscribes reports whether the script listens to the event. Patterns may
+// end with ".*" to match a whole category, e.g. "device.*"
+func (m *ScriptMeta) Subscribes(event string) bool {
+	for _, pattern := range m.On {
+		if pattern == event || pattern == "*" {
+			return true
+		}
+		if strings.HasSuffix(pattern, ".*") && strings.HasPrefix(event, strings.TrimSuffix(pattern, "*")) {
+			return true
+		}
+	}
+	return false
+}
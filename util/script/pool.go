@@ -39,6 +39,30 @@ type Result struct {
 	Duration time.Duration
 }
 
+// 正在执行的脚本：运行时 -> 脚本名及元数据，宿主方法通过 ScriptName 获取调用方
+var runningScripts = xsync.NewMap[*goja.Runtime, runningScript]()
+
+type runningScript struct {
+	name string
+	meta *ScriptMeta
+}
+
+// ScriptName returns the name of the script running in rt, "" if unknown
+func ScriptName(rt *goja.Runtime) string {
+	running, _ := runningScripts.Load(rt)
+	return running.name
+}
+
+// ScriptCapabilities returns the capabilities declared by the script
+// running in rt
+func ScriptCapabilities(rt *goja.Runtime) []string {
+	running, _ := runningScripts.Load(rt)
+	if running.meta == nil {
+		return nil
+	}
+	return running.meta.Capabilities
+}
+
 // ScriptPool：脚本编译缓存 + 方法注入注册表（基于 xsync.Map）
 type ScriptPool struct {
 	scripts *xsync.Map[string, *programEntry]
@@ -48,7 +72,7 @@ type ScriptPool struct {
 }
 
 // NewScriptPool 创建一个脚本池（xsync 容器替代锁）
-func NewScriptPool(groupName string, redisClient *util.RedisClient) *ScriptPool {
+func NewScriptPool(groupName string, redisClient util.KVStore) *ScriptPool {
 	if redisClient == nil {
 		panic("redisClient is nil")
 	}
@@ -136,6 +160,13 @@ func (p *ScriptPool) runScriptWithContext(ctx context.Context, name string, opts
 	start := time.Now()
 	// 每次创建新的运行时实例，确保并发安全
 	rt := goja.New()
+	running := runningScript{name: name}
+	if p.Cache != nil {
+		running.meta, _ = p.Cache.GetMeta(name)
+	}
+	runningScripts.Store(rt, running)
+	defer runningScripts.Delete(rt)
+	defer closeCursors(rt, nil)
 
 	// 预先分配对象映射空间，优化内存分配
 	objMap := make(map[string]map[string]interface{}, 8) // 预分配合理的初始容量
@@ -1,7 +1,6 @@
 package script
 
 import (
-	"context"
 	"errors"
 	"log"
 	"main/util"
@@ -9,10 +8,10 @@ import (
 
 type ScriptRedisStore struct {
 	Group string
-	Redis *util.RedisClient
+	Redis util.KVStore
 }
 
-func NewScriptRedisStore(groupName string, redis *util.RedisClient) *ScriptRedisStore {
+func NewScriptRedisStore(groupName string, redis util.KVStore) *ScriptRedisStore {
 	return &ScriptRedisStore{Group: groupName, Redis: redis}
 }
 
@@ -95,7 +94,7 @@ func (s *ScriptRedisStore) Exists(scriptName string) (bool, error) {
 		return false, errors.New("redis client not initialized")
 	}
 
-	exists, err := s.Redis.Client.HExists(context.Background(), s.Group, scriptName).Result()
+	exists, err := s.Redis.HExists(s.Group, scriptName)
 	if err != nil {
 		return false, err
 	}
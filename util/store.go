@@ -0,0 +1,131 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"time"
+
+	"main/config"
+)
+
+// ErrNotSupported is returned by stores that cannot run a command, e.g.
+// Lua scripts on the memory store
+var ErrNotSupported = errors.New("not supported by the storage backend")
+
+// ErrTxFailed is returned by Watch when a watched key changed before the
+// transaction ran
+var ErrTxFailed = errors.New("transaction aborted: watched keys changed")
+
+// KVStore is the key-value storage behind RedisData and RedisConfig.
+// Missing keys and fields are reported as ErrNil; group arguments fall back
+// to DEFAULT_HSET_GROUP when empty.
+type KVStore interface {
+	// Strings and keys
+	Get(key string) (string, error)
+	Set(key string, value string) error
+	SetArgs(key string, value string, ttl time.Duration, nx bool) (bool, error)
+	Del(keys ...string) (int64, error)
+	Exists(keys ...string) (int64, error)
+	Expire(key string, ttl time.Duration) (bool, error)
+	Persist(key string) (bool, error)
+	TTL(key string) (time.Duration, error)
+	IncrBy(key string, value int64) (int64, error)
+	IncrByFloat(key string, value float64) (float64, error)
+
+	// Hashes
+	GetHValue(group string, key string) (string, error)
+	SetHValue(group string, key string, value string) error
+	HKeys(group string) ([]string, error)
+	HDel(group string, key string) error
+	HExists(key string, field string) (bool, error)
+	HGetAll(key string) (map[string]string, error)
+	HMGet(key string, fields ...string) ([]interface{}, error)
+	HMSet(key string, values map[string]interface{}) error
+	HDelFields(key string, fields ...string) (int64, error)
+	HIncrBy(key string, field string, value int64) (int64, error)
+
+	// Lists
+	LPush(key string, values ...interface{}) (int64, error)
+	RPush(key string, values ...interface{}) (int64, error)
+	LPop(key string) (string, error)
+	RPop(key string) (string, error)
+	LRange(key string, start, stop int64) ([]string, error)
+	LLen(key string) (int64, error)
+	LTrim(key string, start, stop int64) error
+
+	// Sets
+	SAdd(key string, members ...interface{}) (int64, error)
+	SRem(key string, members ...interface{}) (int64, error)
+	SCard(key string) (int64, error)
+	SMembers(key string) ([]string, error)
+
+	// Sorted sets
+	ZAdd(key string, members ...ZMember) (int64, error)
+	ZRem(key string, members ...interface{}) (int64, error)
+	ZScore(key string, member string) (float64, error)
+	ZIncrBy(key string, increment float64, member string) (float64, error)
+	ZCard(key string) (int64, error)
+	ZRange(key string, start, stop int64, rev bool) ([]ZMember, error)
+	ZRangeByScore(key string, min, max string, offset, count int64) ([]ZMember, error)
+	ZRemRangeByScore(key string, min, max string) (int64, error)
+
+	// Scanning, scripting, batches and pub/sub
+	Scan(cursor uint64, match string, count int64, keyType string) ([]string, uint64, error)
+	ScanAll(match string, count int64, keyType string, fn func(key string) bool) error
+	Eval(script string, keys []string, args ...interface{}) (interface{}, error)
+	Publish(channel string, message interface{}) (int64, error)
+	Do(args ...interface{}) (interface{}, error)
+	ExecBatch(commands [][]interface{}, transaction bool) ([]interface{}, error)
+	Watch(keys []string, fn func() ([][]interface{}, error)) ([]interface{}, error)
+
+	Close() error
+}
+
+var (
+	_ KVStore = (*RedisClient)(nil)
+	_ KVStore = (*MemoryStore)(nil)
+)
+
+// InitStorage creates RedisData and RedisConfig with the configured backend
+func InitStorage() error {
+	cfg := &config.CONFIG.Storage
+	switch cfg.Backend {
+	case "", config.STORAGE_BACKEND_REDIS:
+		return InitRedisClient()
+	case config.STORAGE_BACKEND_MEMORY:
+		dbConfig := config.CONFIG.Database.Redis.DBConfig
+		dbData := config.CONFIG.Database.Redis.DB
+		store, err := NewMemoryStore(memorySnapshotPath(cfg, dbConfig), time.Duration(cfg.SnapshotInterval)*time.Second)
+		if err != nil {
+			return err
+		}
+		RedisConfig = store
+		if dbData == dbConfig {
+			RedisData = store
+		} else if RedisData, err = NewMemoryStore(memorySnapshotPath(cfg, dbData), time.Duration(cfg.SnapshotInterval)*time.Second); err != nil {
+			return err
+		}
+		log.Printf("Using in-memory storage (snapshot dir: %q)", cfg.SnapshotDir)
+		return nil
+	default:
+		return fmt.Errorf("unknown storage backend %q", cfg.Backend)
+	}
+}
+
+func memorySnapshotPath(cfg *config.StorageConfig, db int) string {
+	if cfg.SnapshotDir == "" {
+		return ""
+	}
+	return filepath.Join(cfg.SnapshotDir, fmt.Sprintf("db%d.json", db))
+}
+
+// CloseStorage flushes and closes the stores
+func CloseStorage() {
+	for _, store := range []KVStore{RedisData, RedisConfig} {
+		if store != nil {
+			store.Close()
+		}
+	}
+}
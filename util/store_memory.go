@@ -0,0 +1,1516 @@
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/**
+ * 内存存储：进程内的 Redis 替身，用于本地开发与单元测试
+ * 1. 支持项目用到的字符串、哈希、列表、集合、有序集合命令及过期时间
+ * 2. 所有命令经 Do 分发，pipeline / multi 在同一把锁内执行（天然原子）
+ *    WATCH 不区分键：期间有任何写入都视为冲突
+ * 3. 配置快照文件时，定期及关闭时把变更写入磁盘，启动时恢复
+ * 不支持 Lua 脚本（EVAL），PUBLISH 没有订阅者
+ */
+
+const (
+	TYPE_STRING = "string"
+	TYPE_HASH   = "hash"
+	TYPE_LIST   = "list"
+	TYPE_SET    = "set"
+	TYPE_ZSET   = "zset"
+)
+
+var errWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+
+type memoryEntry struct {
+	Type     string             `json:"type"`
+	String   string             `json:"string,omitempty"`
+	Hash     map[string]string  `json:"hash,omitempty"`
+	List     []string           `json:"list,omitempty"`
+	Set      map[string]bool    `json:"set,omitempty"`
+	ZSet     map[string]float64 `json:"zset,omitempty"`
+	ExpireAt int64              `json:"expireAt,omitempty"` // unix ms, 0 = no expiry
+}
+
+// MemoryStore implements KVStore in process memory
+type MemoryStore struct {
+	mu     sync.Mutex
+	data   map[string]*memoryEntry
+	path   string
+	dirty  bool
+	writes uint64 // 写入计数，用于 Watch 检测冲突
+	closed bool
+	stop   chan struct{}
+}
+
+// NewMemoryStore creates a memory store; if path is set the data is restored
+// from and snapshotted to that file every interval
+func NewMemoryStore(path string, interval time.Duration) (*MemoryStore, error) {
+	m := &MemoryStore{
+		data: make(map[string]*memoryEntry),
+		path: path,
+		stop: make(chan struct{}),
+	}
+	if path == "" {
+		return m, nil
+	}
+	if err := m.load(); err != nil {
+		return nil, err
+	}
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	go m.snapshotLoop(interval)
+	return m, nil
+}
+
+func (m *MemoryStore) load() error {
+	data, err := os.ReadFile(m.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read memory store snapshot: %w", err)
+	}
+	if err := json.Unmarshal(data, &m.data); err != nil {
+		return fmt.Errorf("invalid memory store snapshot %s: %w", m.path, err)
+	}
+	log.Printf("Memory store restored %d keys from %s", len(m.data), m.path)
+	return nil
+}
+
+func (m *MemoryStore) snapshotLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := m.Snapshot(); err != nil {
+				log.Printf("Failed to snapshot memory store: %v", err)
+			}
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+// Snapshot writes the data to the snapshot file if it changed
+func (m *MemoryStore) Snapshot() error {
+	m.mu.Lock()
+	if m.path == "" || !m.dirty {
+		m.mu.Unlock()
+		return nil
+	}
+	m.expireAll()
+	data, err := json.Marshal(m.data)
+	m.dirty = false
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(m.path), 0755); err != nil {
+		return err
+	}
+	tmp := m.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.path)
+}
+
+func (m *MemoryStore) Close() error {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil
+	}
+	m.closed = true
+	close(m.stop)
+	m.mu.Unlock()
+	return m.Snapshot()
+}
+
+// Do runs a Redis command, e.g. Do("HSET", "key", "field", "value")
+func (m *MemoryStore) Do(args ...interface{}) (interface{}, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.do(args)
+}
+
+// ExecBatch runs the commands under one lock, so batches are always atomic
+func (m *MemoryStore) ExecBatch(commands [][]interface{}, transaction bool) ([]interface{}, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.execBatch(commands), nil
+}
+
+// Watch runs fn and executes its commands unless anything was written
+// meanwhile (the memory store does not track versions per key)
+func (m *MemoryStore) Watch(keys []string, fn func() ([][]interface{}, error)) ([]interface{}, error) {
+	m.mu.Lock()
+	writes := m.writes
+	m.mu.Unlock()
+
+	commands, err := fn()
+	if err != nil || len(commands) == 0 {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.writes != writes {
+		return nil, ErrTxFailed
+	}
+	return m.execBatch(commands), nil
+}
+
+func (m *MemoryStore) execBatch(commands [][]interface{}) []interface{} {
+	results := make([]interface{}, len(commands))
+	for i, args := range commands {
+		value, err := m.do(args)
+		if err != nil && err != ErrNil {
+			results[i] = err
+		} else {
+			results[i] = value
+		}
+	}
+	return results
+}
+
+func (m *MemoryStore) Eval(script string, keys []string, args ...interface{}) (interface{}, error) {
+	return nil, fmt.Errorf("eval: %w", ErrNotSupported)
+}
+
+func (m *MemoryStore) Publish(channel string, message interface{}) (int64, error) {
+	return 0, nil
+}
+
+// ---- typed API, see RedisClient ----
+
+func (m *MemoryStore) Get(key string) (string, error) {
+	return resultString(m.Do("GET", key))
+}
+
+func (m *MemoryStore) Set(key string, value string) error {
+	_, err := m.Do("SET", key, value)
+	return err
+}
+
+func (m *MemoryStore) SetArgs(key string, value string, ttl time.Duration, nx bool) (bool, error) {
+	args := []interface{}{"SET", key, value}
+	if ttl > 0 {
+		args = append(args, "PX", ttl.Milliseconds())
+	}
+	if nx {
+		args = append(args, "NX")
+	}
+	_, err := m.Do(args...)
+	if err == ErrNil {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (m *MemoryStore) Del(keys ...string) (int64, error) {
+	return resultInt(m.Do(stringArgs("DEL", keys)...))
+}
+
+func (m *MemoryStore) Exists(keys ...string) (int64, error) {
+	return resultInt(m.Do(stringArgs("EXISTS", keys)...))
+}
+
+func (m *MemoryStore) Expire(key string, ttl time.Duration) (bool, error) {
+	return resultBool(m.Do("PEXPIRE", key, ttl.Milliseconds()))
+}
+
+func (m *MemoryStore) Persist(key string) (bool, error) {
+	return resultBool(m.Do("PERSIST", key))
+}
+
+func (m *MemoryStore) TTL(key string) (time.Duration, error) {
+	ttl, err := resultInt(m.Do("TTL", key))
+	if err != nil || ttl < 0 {
+		return time.Duration(ttl), err
+	}
+	return time.Duration(ttl) * time.Second, nil
+}
+
+func (m *MemoryStore) IncrBy(key string, value int64) (int64, error) {
+	return resultInt(m.Do("INCRBY", key, value))
+}
+
+func (m *MemoryStore) IncrByFloat(key string, value float64) (float64, error) {
+	result, err := resultString(m.Do("INCRBYFLOAT", key, value))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(result, 64)
+}
+
+func (m *MemoryStore) GetHValue(group string, key string) (string, error) {
+	return resultString(m.Do("HGET", getGroupName(group), key))
+}
+
+func (m *MemoryStore) SetHValue(group string, key string, value string) error {
+	_, err := m.Do("HSET", getGroupName(group), key, value)
+	return err
+}
+
+func (m *MemoryStore) HKeys(group string) ([]string, error) {
+	return resultStrings(m.Do("HKEYS", getGroupName(group)))
+}
+
+func (m *MemoryStore) HDel(group string, key string) error {
+	_, err := m.Do("HDEL", getGroupName(group), key)
+	return err
+}
+
+func (m *MemoryStore) HExists(key string, field string) (bool, error) {
+	return resultBool(m.Do("HEXISTS", key, field))
+}
+
+func (m *MemoryStore) HGetAll(key string) (map[string]string, error) {
+	value, err := m.Do("HGETALL", key)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]string)
+	for field, v := range value.(map[interface{}]interface{}) {
+		result[field.(string)] = v.(string)
+	}
+	return result, nil
+}
+
+func (m *MemoryStore) HMGet(key string, fields ...string) ([]interface{}, error) {
+	value, err := m.Do(stringArgs("HMGET", append([]string{key}, fields...))...)
+	if err != nil {
+		return nil, err
+	}
+	return value.([]interface{}), nil
+}
+
+func (m *MemoryStore) HMSet(key string, values map[string]interface{}) error {
+	args := []interface{}{"HSET", key}
+	for field, value := range values {
+		args = append(args, field, value)
+	}
+	_, err := m.Do(args...)
+	return err
+}
+
+func (m *MemoryStore) HDelFields(key string, fields ...string) (int64, error) {
+	return resultInt(m.Do(stringArgs("HDEL", append([]string{key}, fields...))...))
+}
+
+func (m *MemoryStore) HIncrBy(key string, field string, value int64) (int64, error) {
+	return resultInt(m.Do("HINCRBY", key, field, value))
+}
+
+func (m *MemoryStore) LPush(key string, values ...interface{}) (int64, error) {
+	return resultInt(m.Do(append([]interface{}{"LPUSH", key}, values...)...))
+}
+
+func (m *MemoryStore) RPush(key string, values ...interface{}) (int64, error) {
+	return resultInt(m.Do(append([]interface{}{"RPUSH", key}, values...)...))
+}
+
+func (m *MemoryStore) LPop(key string) (string, error) {
+	return resultString(m.Do("LPOP", key))
+}
+
+func (m *MemoryStore) RPop(key string) (string, error) {
+	return resultString(m.Do("RPOP", key))
+}
+
+func (m *MemoryStore) LRange(key string, start, stop int64) ([]string, error) {
+	return resultStrings(m.Do("LRANGE", key, start, stop))
+}
+
+func (m *MemoryStore) LLen(key string) (int64, error) {
+	return resultInt(m.Do("LLEN", key))
+}
+
+func (m *MemoryStore) LTrim(key string, start, stop int64) error {
+	_, err := m.Do("LTRIM", key, start, stop)
+	return err
+}
+
+func (m *MemoryStore) SAdd(key string, members ...interface{}) (int64, error) {
+	return resultInt(m.Do(append([]interface{}{"SADD", key}, members...)...))
+}
+
+func (m *MemoryStore) SRem(key string, members ...interface{}) (int64, error) {
+	return resultInt(m.Do(append([]interface{}{"SREM", key}, members...)...))
+}
+
+func (m *MemoryStore) SCard(key string) (int64, error) {
+	return resultInt(m.Do("SCARD", key))
+}
+
+func (m *MemoryStore) SMembers(key string) ([]string, error) {
+	return resultStrings(m.Do("SMEMBERS", key))
+}
+
+func (m *MemoryStore) ZAdd(key string, members ...ZMember) (int64, error) {
+	args := []interface{}{"ZADD", key}
+	for _, member := range members {
+		args = append(args, member.Score, member.Member)
+	}
+	return resultInt(m.Do(args...))
+}
+
+func (m *MemoryStore) ZRem(key string, members ...interface{}) (int64, error) {
+	return resultInt(m.Do(append([]interface{}{"ZREM", key}, members...)...))
+}
+
+func (m *MemoryStore) ZScore(key string, member string) (float64, error) {
+	return resultFloat(m.Do("ZSCORE", key, member))
+}
+
+func (m *MemoryStore) ZIncrBy(key string, increment float64, member string) (float64, error) {
+	return resultFloat(m.Do("ZINCRBY", key, increment, member))
+}
+
+func (m *MemoryStore) ZCard(key string) (int64, error) {
+	return resultInt(m.Do("ZCARD", key))
+}
+
+func (m *MemoryStore) ZRange(key string, start, stop int64, rev bool) ([]ZMember, error) {
+	command := "ZRANGE"
+	if rev {
+		command = "ZREVRANGE"
+	}
+	return resultZMembers(m.Do(command, key, start, stop, "WITHSCORES"))
+}
+
+func (m *MemoryStore) ZRangeByScore(key string, min, max string, offset, count int64) ([]ZMember, error) {
+	args := []interface{}{"ZRANGEBYSCORE", key, min, max, "WITHSCORES"}
+	if count > 0 {
+		args = append(args, "LIMIT", offset, count)
+	}
+	return resultZMembers(m.Do(args...))
+}
+
+func (m *MemoryStore) ZRemRangeByScore(key string, min, max string) (int64, error) {
+	return resultInt(m.Do("ZREMRANGEBYSCORE", key, min, max))
+}
+
+func (m *MemoryStore) Scan(cursor uint64, match string, count int64, keyType string) ([]string, uint64, error) {
+	args := []interface{}{"SCAN", cursor}
+	if match != "" {
+		args = append(args, "MATCH", match)
+	}
+	if count > 0 {
+		args = append(args, "COUNT", count)
+	}
+	if keyType != "" {
+		args = append(args, "TYPE", keyType)
+	}
+	value, err := m.Do(args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	reply := value.([]interface{})
+	next, _ := strconv.ParseUint(reply[0].(string), 10, 64)
+	keys, _ := resultStrings(reply[1], nil)
+	return keys, next, nil
+}
+
+func (m *MemoryStore) ScanAll(match string, count int64, keyType string, fn func(key string) bool) error {
+	return scanNode(func(cursor uint64) ([]string, uint64, error) {
+		return m.Scan(cursor, match, count, keyType)
+	}, fn)
+}
+
+// ---- command dispatcher ----
+
+type memoryCommand struct {
+	arity int  // minimum number of arguments after the command name
+	write bool // marks the store dirty for snapshots
+	fn    func(m *MemoryStore, args []string) (interface{}, error)
+}
+
+var memoryCommands map[string]memoryCommand
+
+func init() {
+	memoryCommands = map[string]memoryCommand{
+		"PING":             {0, false, func(m *MemoryStore, args []string) (interface{}, error) { return "PONG", nil }},
+		"GET":              {1, false, (*MemoryStore).cmdGet},
+		"SET":              {2, true, (*MemoryStore).cmdSet},
+		"DEL":              {1, true, (*MemoryStore).cmdDel},
+		"EXISTS":           {1, false, (*MemoryStore).cmdExists},
+		"TYPE":             {1, false, (*MemoryStore).cmdType},
+		"KEYS":             {1, false, (*MemoryStore).cmdKeys},
+		"SCAN":             {1, false, (*MemoryStore).cmdScan},
+		"DBSIZE":           {0, false, (*MemoryStore).cmdDBSize},
+		"FLUSHDB":          {0, true, (*MemoryStore).cmdFlushDB},
+		"EXPIRE":           {2, true, (*MemoryStore).cmdExpire},
+		"PEXPIRE":          {2, true, (*MemoryStore).cmdExpire},
+		"PERSIST":          {1, true, (*MemoryStore).cmdPersist},
+		"TTL":              {1, false, (*MemoryStore).cmdTTL},
+		"PTTL":             {1, false, (*MemoryStore).cmdTTL},
+		"INCR":             {1, true, (*MemoryStore).cmdIncr},
+		"INCRBY":           {2, true, (*MemoryStore).cmdIncr},
+		"DECR":             {1, true, (*MemoryStore).cmdIncr},
+		"DECRBY":           {2, true, (*MemoryStore).cmdIncr},
+		"PUBLISH":          {2, false, func(m *MemoryStore, args []string) (interface{}, error) { return int64(0), nil }},
+		"HGET":             {2, false, (*MemoryStore).cmdHGet},
+		"HSET":             {3, true, (*MemoryStore).cmdHSet},
+		"HMSET":            {3, true, (*MemoryStore).cmdHSet},
+		"HMGET":            {2, false, (*MemoryStore).cmdHMGet},
+		"HDEL":             {2, true, (*MemoryStore).cmdHDel},
+		"HGETALL":          {1, false, (*MemoryStore).cmdHGetAll},
+		"HKEYS":            {1, false, (*MemoryStore).cmdHKeys},
+		"HLEN":             {1, false, (*MemoryStore).cmdHLen},
+		"HEXISTS":          {2, false, (*MemoryStore).cmdHExists},
+		"HINCRBY":          {3, true, (*MemoryStore).cmdHIncrBy},
+		"LPUSH":            {2, true, (*MemoryStore).cmdPush},
+		"RPUSH":            {2, true, (*MemoryStore).cmdPush},
+		"LPOP":             {1, true, (*MemoryStore).cmdPop},
+		"RPOP":             {1, true, (*MemoryStore).cmdPop},
+		"LRANGE":           {3, false, (*MemoryStore).cmdLRange},
+		"LLEN":             {1, false, (*MemoryStore).cmdLLen},
+		"LTRIM":            {3, true, (*MemoryStore).cmdLTrim},
+		"SADD":             {2, true, (*MemoryStore).cmdSAdd},
+		"SREM":             {2, true, (*MemoryStore).cmdSRem},
+		"SCARD":            {1, false, (*MemoryStore).cmdSCard},
+		"SMEMBERS":         {1, false, (*MemoryStore).cmdSMembers},
+		"ZADD":             {3, true, (*MemoryStore).cmdZAdd},
+		"ZREM":             {2, true, (*MemoryStore).cmdZRem},
+		"ZSCORE":           {2, false, (*MemoryStore).cmdZScore},
+		"ZINCRBY":          {3, true, (*MemoryStore).cmdZIncrBy},
+		"ZCARD":            {1, false, (*MemoryStore).cmdZCard},
+		"ZRANGE":           {3, false, (*MemoryStore).cmdZRange},
+		"ZREVRANGE":        {3, false, (*MemoryStore).cmdZRange},
+		"ZRANGEBYSCORE":    {3, false, (*MemoryStore).cmdZRangeByScore},
+		"ZREMRANGEBYSCORE": {3, true, (*MemoryStore).cmdZRemRangeByScore},
+		"SISMEMBER":        {2, false, (*MemoryStore).cmdSIsMember},
+		"INCRBYFLOAT":      {2, true, (*MemoryStore).cmdIncrByFloat},
+	}
+}
+
+func (m *MemoryStore) do(args []interface{}) (interface{}, error) {
+	if len(args) == 0 {
+		return nil, errors.New("ERR empty command")
+	}
+	strs := make([]string, len(args))
+	for i, arg := range args {
+		strs[i] = argString(arg)
+	}
+	name := strings.ToUpper(strs[0])
+	command, ok := memoryCommands[name]
+	if !ok {
+		return nil, fmt.Errorf("ERR unknown command '%s': %w", strs[0], ErrNotSupported)
+	}
+	if len(strs)-1 < command.arity {
+		return nil, fmt.Errorf("ERR wrong number of arguments for '%s' command", strings.ToLower(name))
+	}
+	// 命令实现通过 strs[0] 区分同类命令（如 LPUSH / RPUSH）
+	strs[0] = name
+	value, err := command.fn(m, strs)
+	if command.write && err == nil {
+		m.dirty = true
+		m.writes++
+	}
+	return value, err
+}
+
+func argString(arg interface{}) string {
+	switch v := arg.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case time.Duration:
+		return strconv.FormatInt(int64(v), 10)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// entry returns a live entry, removing it if expired; typ "" accepts any type
+func (m *MemoryStore) entry(key, typ string) (*memoryEntry, error) {
+	e, ok := m.data[key]
+	if !ok {
+		return nil, nil
+	}
+	if e.ExpireAt > 0 && e.ExpireAt <= time.Now().UnixMilli() {
+		delete(m.data, key)
+		m.dirty = true
+		return nil, nil
+	}
+	if typ != "" && e.Type != typ {
+		return nil, errWrongType
+	}
+	return e, nil
+}
+
+// create returns the entry of a key, creating an empty one of the type
+func (m *MemoryStore) create(key, typ string) (*memoryEntry, error) {
+	e, err := m.entry(key, typ)
+	if err != nil || e != nil {
+		return e, err
+	}
+	e = &memoryEntry{Type: typ}
+	switch typ {
+	case TYPE_HASH:
+		e.Hash = make(map[string]string)
+	case TYPE_SET:
+		e.Set = make(map[string]bool)
+	case TYPE_ZSET:
+		e.ZSet = make(map[string]float64)
+	}
+	m.data[key] = e
+	return e, nil
+}
+
+// dropEmpty removes containers that became empty, as Redis does
+func (m *MemoryStore) dropEmpty(key string, e *memoryEntry) {
+	if len(e.Hash) == 0 && len(e.List) == 0 && len(e.Set) == 0 && len(e.ZSet) == 0 && e.Type != TYPE_STRING {
+		delete(m.data, key)
+	}
+}
+
+func (m *MemoryStore) expireAll() {
+	now := time.Now().UnixMilli()
+	for key, e := range m.data {
+		if e.ExpireAt > 0 && e.ExpireAt <= now {
+			delete(m.data, key)
+		}
+	}
+}
+
+func parseInt(s string) (int64, error) {
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, errors.New("ERR value is not an integer or out of range")
+	}
+	return v, nil
+}
+
+func parseFloat(s string) (float64, error) {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(v) {
+		return 0, errors.New("ERR value is not a valid float")
+	}
+	return v, nil
+}
+
+// ---- keys and strings ----
+
+func (m *MemoryStore) cmdGet(args []string) (interface{}, error) {
+	e, err := m.entry(args[1], TYPE_STRING)
+	if err != nil {
+		return nil, err
+	}
+	if e == nil {
+		return nil, ErrNil
+	}
+	return e.String, nil
+}
+
+// SET key value [EX seconds | PX milliseconds] [NX | XX] [KEEPTTL]
+func (m *MemoryStore) cmdSet(args []string) (interface{}, error) {
+	key := args[1]
+	var expireAt int64
+	var nx, xx, keepTTL bool
+	for i := 3; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "KEEPTTL":
+			keepTTL = true
+		case "EX", "PX":
+			if i+1 >= len(args) {
+				return nil, errors.New("ERR syntax error")
+			}
+			n, err := parseInt(args[i+1])
+			if err != nil {
+				return nil, err
+			}
+			if strings.ToUpper(args[i]) == "EX" {
+				n *= 1000
+			}
+			expireAt = time.Now().UnixMilli() + n
+			i++
+		default:
+			return nil, errors.New("ERR syntax error")
+		}
+	}
+
+	current, _ := m.entry(key, "")
+	if (nx && current != nil) || (xx && current == nil) {
+		return nil, ErrNil
+	}
+	if keepTTL && current != nil {
+		expireAt = current.ExpireAt
+	}
+	m.data[key] = &memoryEntry{Type: TYPE_STRING, String: args[2], ExpireAt: expireAt}
+	return "OK", nil
+}
+
+func (m *MemoryStore) cmdDel(args []string) (interface{}, error) {
+	var count int64
+	for _, key := range args[1:] {
+		if e, _ := m.entry(key, ""); e != nil {
+			delete(m.data, key)
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *MemoryStore) cmdExists(args []string) (interface{}, error) {
+	var count int64
+	for _, key := range args[1:] {
+		if e, _ := m.entry(key, ""); e != nil {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *MemoryStore) cmdType(args []string) (interface{}, error) {
+	e, _ := m.entry(args[1], "")
+	if e == nil {
+		return "none", nil
+	}
+	return e.Type, nil
+}
+
+func (m *MemoryStore) cmdKeys(args []string) (interface{}, error) {
+	pattern, err := globPattern(args[1])
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]interface{}, 0)
+	for _, key := range m.sortedKeys() {
+		if pattern.MatchString(key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// SCAN cursor [MATCH pattern] [COUNT count] [TYPE type], the cursor is an
+// offset into the sorted keys
+func (m *MemoryStore) cmdScan(args []string) (interface{}, error) {
+	cursor, err := parseInt(args[1])
+	if err != nil || cursor < 0 {
+		return nil, errors.New("ERR invalid cursor")
+	}
+	match, count, keyType := "*", int64(10), ""
+	for i := 2; i+1 < len(args); i += 2 {
+		switch strings.ToUpper(args[i]) {
+		case "MATCH":
+			match = args[i+1]
+		case "COUNT":
+			if count, err = parseInt(args[i+1]); err != nil || count <= 0 {
+				return nil, errors.New("ERR syntax error")
+			}
+		case "TYPE":
+			keyType = strings.ToLower(args[i+1])
+		default:
+			return nil, errors.New("ERR syntax error")
+		}
+	}
+	pattern, err := globPattern(match)
+	if err != nil {
+		return nil, err
+	}
+
+	all := m.sortedKeys()
+	keys := make([]interface{}, 0)
+	i := int(cursor)
+	for ; i < len(all) && int64(len(keys)) < count; i++ {
+		e, _ := m.entry(all[i], "")
+		if e == nil || !pattern.MatchString(all[i]) || (keyType != "" && e.Type != keyType) {
+			continue
+		}
+		keys = append(keys, all[i])
+	}
+	next := "0"
+	if i < len(all) {
+		next = strconv.Itoa(i)
+	}
+	return []interface{}{next, keys}, nil
+}
+
+func (m *MemoryStore) cmdDBSize(args []string) (interface{}, error) {
+	m.expireAll()
+	return int64(len(m.data)), nil
+}
+
+func (m *MemoryStore) cmdFlushDB(args []string) (interface{}, error) {
+	m.data = make(map[string]*memoryEntry)
+	return "OK", nil
+}
+
+func (m *MemoryStore) sortedKeys() []string {
+	keys := make([]string, 0, len(m.data))
+	for key := range m.data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (m *MemoryStore) cmdExpire(args []string) (interface{}, error) {
+	n, err := parseInt(args[2])
+	if err != nil {
+		return nil, err
+	}
+	e, _ := m.entry(args[1], "")
+	if e == nil {
+		return int64(0), nil
+	}
+	if args[0] == "EXPIRE" {
+		n *= 1000
+	}
+	if n <= 0 {
+		delete(m.data, args[1])
+		return int64(1), nil
+	}
+	e.ExpireAt = time.Now().UnixMilli() + n
+	return int64(1), nil
+}
+
+func (m *MemoryStore) cmdPersist(args []string) (interface{}, error) {
+	e, _ := m.entry(args[1], "")
+	if e == nil || e.ExpireAt == 0 {
+		return int64(0), nil
+	}
+	e.ExpireAt = 0
+	return int64(1), nil
+}
+
+func (m *MemoryStore) cmdTTL(args []string) (interface{}, error) {
+	e, _ := m.entry(args[1], "")
+	if e == nil {
+		return int64(-2), nil
+	}
+	if e.ExpireAt == 0 {
+		return int64(-1), nil
+	}
+	ms := e.ExpireAt - time.Now().UnixMilli()
+	if args[0] == "PTTL" {
+		return ms, nil
+	}
+	return (ms + 500) / 1000, nil
+}
+
+func (m *MemoryStore) cmdIncr(args []string) (interface{}, error) {
+	by := int64(1)
+	if len(args) > 2 {
+		var err error
+		if by, err = parseInt(args[2]); err != nil {
+			return nil, err
+		}
+	}
+	if args[0] == "DECR" || args[0] == "DECRBY" {
+		by = -by
+	}
+	e, err := m.create(args[1], TYPE_STRING)
+	if err != nil {
+		return nil, err
+	}
+	current := int64(0)
+	if e.String != "" {
+		if current, err = parseInt(e.String); err != nil {
+			return nil, err
+		}
+	}
+	current += by
+	e.String = strconv.FormatInt(current, 10)
+	return current, nil
+}
+
+func (m *MemoryStore) cmdIncrByFloat(args []string) (interface{}, error) {
+	by, err := parseFloat(args[2])
+	if err != nil {
+		return nil, err
+	}
+	e, err := m.create(args[1], TYPE_STRING)
+	if err != nil {
+		return nil, err
+	}
+	current := 0.0
+	if e.String != "" {
+		if current, err = parseFloat(e.String); err != nil {
+			return nil, err
+		}
+	}
+	e.String = strconv.FormatFloat(current+by, 'f', -1, 64)
+	return e.String, nil
+}
+
+// ---- hashes ----
+
+func (m *MemoryStore) cmdHGet(args []string) (interface{}, error) {
+	e, err := m.entry(args[1], TYPE_HASH)
+	if err != nil {
+		return nil, err
+	}
+	if e == nil {
+		return nil, ErrNil
+	}
+	value, ok := e.Hash[args[2]]
+	if !ok {
+		return nil, ErrNil
+	}
+	return value, nil
+}
+
+func (m *MemoryStore) cmdHSet(args []string) (interface{}, error) {
+	if len(args)%2 != 0 {
+		return nil, fmt.Errorf("ERR wrong number of arguments for '%s' command", strings.ToLower(args[0]))
+	}
+	e, err := m.create(args[1], TYPE_HASH)
+	if err != nil {
+		return nil, err
+	}
+	var added int64
+	for i := 2; i+1 < len(args); i += 2 {
+		if _, exists := e.Hash[args[i]]; !exists {
+			added++
+		}
+		e.Hash[args[i]] = args[i+1]
+	}
+	if args[0] == "HMSET" {
+		return "OK", nil
+	}
+	return added, nil
+}
+
+func (m *MemoryStore) cmdHMGet(args []string) (interface{}, error) {
+	e, err := m.entry(args[1], TYPE_HASH)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]interface{}, len(args)-2)
+	for i, field := range args[2:] {
+		if e == nil {
+			continue
+		}
+		if value, ok := e.Hash[field]; ok {
+			values[i] = value
+		}
+	}
+	return values, nil
+}
+
+func (m *MemoryStore) cmdHDel(args []string) (interface{}, error) {
+	e, err := m.entry(args[1], TYPE_HASH)
+	if err != nil || e == nil {
+		return int64(0), err
+	}
+	var count int64
+	for _, field := range args[2:] {
+		if _, ok := e.Hash[field]; ok {
+			delete(e.Hash, field)
+			count++
+		}
+	}
+	m.dropEmpty(args[1], e)
+	return count, nil
+}
+
+func (m *MemoryStore) cmdHGetAll(args []string) (interface{}, error) {
+	e, err := m.entry(args[1], TYPE_HASH)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[interface{}]interface{})
+	if e != nil {
+		for field, value := range e.Hash {
+			result[field] = value
+		}
+	}
+	return result, nil
+}
+
+func (m *MemoryStore) cmdHKeys(args []string) (interface{}, error) {
+	e, err := m.entry(args[1], TYPE_HASH)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]interface{}, 0)
+	if e != nil {
+		for _, field := range sortedKeys(e.Hash) {
+			keys = append(keys, field)
+		}
+	}
+	return keys, nil
+}
+
+func (m *MemoryStore) cmdHLen(args []string) (interface{}, error) {
+	e, err := m.entry(args[1], TYPE_HASH)
+	if err != nil || e == nil {
+		return int64(0), err
+	}
+	return int64(len(e.Hash)), nil
+}
+
+func (m *MemoryStore) cmdHExists(args []string) (interface{}, error) {
+	e, err := m.entry(args[1], TYPE_HASH)
+	if err != nil || e == nil {
+		return int64(0), err
+	}
+	if _, ok := e.Hash[args[2]]; ok {
+		return int64(1), nil
+	}
+	return int64(0), nil
+}
+
+func (m *MemoryStore) cmdHIncrBy(args []string) (interface{}, error) {
+	by, err := parseInt(args[3])
+	if err != nil {
+		return nil, err
+	}
+	e, err := m.create(args[1], TYPE_HASH)
+	if err != nil {
+		return nil, err
+	}
+	current := int64(0)
+	if value, ok := e.Hash[args[2]]; ok {
+		if current, err = parseInt(value); err != nil {
+			return nil, errors.New("ERR hash value is not an integer")
+		}
+	}
+	current += by
+	e.Hash[args[2]] = strconv.FormatInt(current, 10)
+	return current, nil
+}
+
+// ---- lists ----
+
+func (m *MemoryStore) cmdPush(args []string) (interface{}, error) {
+	e, err := m.create(args[1], TYPE_LIST)
+	if err != nil {
+		return nil, err
+	}
+	for _, value := range args[2:] {
+		if args[0] == "LPUSH" {
+			e.List = append([]string{value}, e.List...)
+		} else {
+			e.List = append(e.List, value)
+		}
+	}
+	return int64(len(e.List)), nil
+}
+
+func (m *MemoryStore) cmdPop(args []string) (interface{}, error) {
+	e, err := m.entry(args[1], TYPE_LIST)
+	if err != nil {
+		return nil, err
+	}
+	if e == nil || len(e.List) == 0 {
+		return nil, ErrNil
+	}
+	var value string
+	if args[0] == "LPOP" {
+		value, e.List = e.List[0], e.List[1:]
+	} else {
+		value, e.List = e.List[len(e.List)-1], e.List[:len(e.List)-1]
+	}
+	m.dropEmpty(args[1], e)
+	return value, nil
+}
+
+// normalizeRange converts Redis start / stop indexes into a slice range
+func normalizeRange(startArg, stopArg string, length int) (int, int, error) {
+	start, err := parseInt(startArg)
+	if err != nil {
+		return 0, 0, err
+	}
+	stop, err := parseInt(stopArg)
+	if err != nil {
+		return 0, 0, err
+	}
+	n := int64(length)
+	if start < 0 {
+		start += n
+	}
+	if stop < 0 {
+		stop += n
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= n {
+		stop = n - 1
+	}
+	if start > stop {
+		return 0, 0, nil
+	}
+	return int(start), int(stop) + 1, nil
+}
+
+func (m *MemoryStore) cmdLRange(args []string) (interface{}, error) {
+	e, err := m.entry(args[1], TYPE_LIST)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]interface{}, 0)
+	if e == nil {
+		return values, nil
+	}
+	from, to, err := normalizeRange(args[2], args[3], len(e.List))
+	if err != nil {
+		return nil, err
+	}
+	for _, value := range e.List[from:to] {
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+func (m *MemoryStore) cmdLLen(args []string) (interface{}, error) {
+	e, err := m.entry(args[1], TYPE_LIST)
+	if err != nil || e == nil {
+		return int64(0), err
+	}
+	return int64(len(e.List)), nil
+}
+
+func (m *MemoryStore) cmdLTrim(args []string) (interface{}, error) {
+	e, err := m.entry(args[1], TYPE_LIST)
+	if err != nil || e == nil {
+		return "OK", err
+	}
+	from, to, err := normalizeRange(args[2], args[3], len(e.List))
+	if err != nil {
+		return nil, err
+	}
+	e.List = append([]string(nil), e.List[from:to]...)
+	m.dropEmpty(args[1], e)
+	return "OK", nil
+}
+
+// ---- sets ----
+
+func (m *MemoryStore) cmdSAdd(args []string) (interface{}, error) {
+	e, err := m.create(args[1], TYPE_SET)
+	if err != nil {
+		return nil, err
+	}
+	var added int64
+	for _, member := range args[2:] {
+		if !e.Set[member] {
+			e.Set[member] = true
+			added++
+		}
+	}
+	return added, nil
+}
+
+func (m *MemoryStore) cmdSRem(args []string) (interface{}, error) {
+	e, err := m.entry(args[1], TYPE_SET)
+	if err != nil || e == nil {
+		return int64(0), err
+	}
+	var removed int64
+	for _, member := range args[2:] {
+		if e.Set[member] {
+			delete(e.Set, member)
+			removed++
+		}
+	}
+	m.dropEmpty(args[1], e)
+	return removed, nil
+}
+
+func (m *MemoryStore) cmdSCard(args []string) (interface{}, error) {
+	e, err := m.entry(args[1], TYPE_SET)
+	if err != nil || e == nil {
+		return int64(0), err
+	}
+	return int64(len(e.Set)), nil
+}
+
+func (m *MemoryStore) cmdSMembers(args []string) (interface{}, error) {
+	e, err := m.entry(args[1], TYPE_SET)
+	if err != nil {
+		return nil, err
+	}
+	members := make([]interface{}, 0)
+	if e != nil {
+		for _, member := range sortedKeys(e.Set) {
+			members = append(members, member)
+		}
+	}
+	return members, nil
+}
+
+func (m *MemoryStore) cmdSIsMember(args []string) (interface{}, error) {
+	e, err := m.entry(args[1], TYPE_SET)
+	if err != nil || e == nil || !e.Set[args[2]] {
+		return int64(0), err
+	}
+	return int64(1), nil
+}
+
+// ---- sorted sets ----
+
+func (m *MemoryStore) cmdZAdd(args []string) (interface{}, error) {
+	if len(args)%2 != 0 {
+		return nil, errors.New("ERR syntax error")
+	}
+	scores := make([]float64, 0, (len(args)-2)/2)
+	for i := 2; i < len(args); i += 2 {
+		score, err := parseFloat(args[i])
+		if err != nil {
+			return nil, err
+		}
+		scores = append(scores, score)
+	}
+	e, err := m.create(args[1], TYPE_ZSET)
+	if err != nil {
+		return nil, err
+	}
+	var added int64
+	for i, score := range scores {
+		member := args[3+2*i]
+		if _, exists := e.ZSet[member]; !exists {
+			added++
+		}
+		e.ZSet[member] = score
+	}
+	return added, nil
+}
+
+func (m *MemoryStore) cmdZRem(args []string) (interface{}, error) {
+	e, err := m.entry(args[1], TYPE_ZSET)
+	if err != nil || e == nil {
+		return int64(0), err
+	}
+	var removed int64
+	for _, member := range args[2:] {
+		if _, ok := e.ZSet[member]; ok {
+			delete(e.ZSet, member)
+			removed++
+		}
+	}
+	m.dropEmpty(args[1], e)
+	return removed, nil
+}
+
+func (m *MemoryStore) cmdZScore(args []string) (interface{}, error) {
+	e, err := m.entry(args[1], TYPE_ZSET)
+	if err != nil {
+		return nil, err
+	}
+	if e == nil {
+		return nil, ErrNil
+	}
+	score, ok := e.ZSet[args[2]]
+	if !ok {
+		return nil, ErrNil
+	}
+	return score, nil
+}
+
+func (m *MemoryStore) cmdZIncrBy(args []string) (interface{}, error) {
+	by, err := parseFloat(args[2])
+	if err != nil {
+		return nil, err
+	}
+	e, err := m.create(args[1], TYPE_ZSET)
+	if err != nil {
+		return nil, err
+	}
+	e.ZSet[args[3]] += by
+	return e.ZSet[args[3]], nil
+}
+
+func (m *MemoryStore) cmdZCard(args []string) (interface{}, error) {
+	e, err := m.entry(args[1], TYPE_ZSET)
+	if err != nil || e == nil {
+		return int64(0), err
+	}
+	return int64(len(e.ZSet)), nil
+}
+
+// sortedZSet orders members by score, then by member
+func sortedZSet(e *memoryEntry) []ZMember {
+	members := make([]ZMember, 0)
+	if e == nil {
+		return members
+	}
+	for member, score := range e.ZSet {
+		members = append(members, ZMember{Member: member, Score: score})
+	}
+	sort.Slice(members, func(i, j int) bool {
+		if members[i].Score != members[j].Score {
+			return members[i].Score < members[j].Score
+		}
+		return members[i].Member < members[j].Member
+	})
+	return members
+}
+
+// zsetReply returns member names, or [member, score] pairs with WITHSCORES
+func zsetReply(members []ZMember, withScores bool) []interface{} {
+	reply := make([]interface{}, len(members))
+	for i, member := range members {
+		if withScores {
+			reply[i] = []interface{}{member.Member, member.Score}
+		} else {
+			reply[i] = member.Member
+		}
+	}
+	return reply
+}
+
+func hasOption(args []string, option string) bool {
+	for _, arg := range args {
+		if strings.EqualFold(arg, option) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MemoryStore) cmdZRange(args []string) (interface{}, error) {
+	e, err := m.entry(args[1], TYPE_ZSET)
+	if err != nil {
+		return nil, err
+	}
+	members := sortedZSet(e)
+	if args[0] == "ZREVRANGE" || hasOption(args[4:], "REV") {
+		for i, j := 0, len(members)-1; i < j; i, j = i+1, j-1 {
+			members[i], members[j] = members[j], members[i]
+		}
+	}
+	from, to, err := normalizeRange(args[2], args[3], len(members))
+	if err != nil {
+		return nil, err
+	}
+	return zsetReply(members[from:to], hasOption(args[4:], "WITHSCORES")), nil
+}
+
+// parseScoreBound parses -inf, +inf, 5 and (5 (exclusive)
+func parseScoreBound(bound string) (float64, bool, error) {
+	exclusive := strings.HasPrefix(bound, "(")
+	bound = strings.TrimPrefix(bound, "(")
+	switch strings.ToLower(bound) {
+	case "-inf":
+		return math.Inf(-1), exclusive, nil
+	case "+inf", "inf":
+		return math.Inf(1), exclusive, nil
+	}
+	value, err := strconv.ParseFloat(bound, 64)
+	if err != nil {
+		return 0, false, errors.New("ERR min or max is not a float")
+	}
+	return value, exclusive, nil
+}
+
+func (m *MemoryStore) scoreRange(e *memoryEntry, minArg, maxArg string) ([]ZMember, error) {
+	min, minExclusive, err := parseScoreBound(minArg)
+	if err != nil {
+		return nil, err
+	}
+	max, maxExclusive, err := parseScoreBound(maxArg)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]ZMember, 0)
+	for _, member := range sortedZSet(e) {
+		if member.Score < min || (minExclusive && member.Score == min) {
+			continue
+		}
+		if member.Score > max || (maxExclusive && member.Score == max) {
+			continue
+		}
+		result = append(result, member)
+	}
+	return result, nil
+}
+
+// ZRANGEBYSCORE key min max [WITHSCORES] [LIMIT offset count]
+func (m *MemoryStore) cmdZRangeByScore(args []string) (interface{}, error) {
+	e, err := m.entry(args[1], TYPE_ZSET)
+	if err != nil {
+		return nil, err
+	}
+	members, err := m.scoreRange(e, args[2], args[3])
+	if err != nil {
+		return nil, err
+	}
+	for i := 4; i < len(args); i++ {
+		if !strings.EqualFold(args[i], "LIMIT") {
+			continue
+		}
+		if i+2 >= len(args) {
+			return nil, errors.New("ERR syntax error")
+		}
+		offset, err1 := parseInt(args[i+1])
+		count, err2 := parseInt(args[i+2])
+		if err1 != nil || err2 != nil {
+			return nil, errors.New("ERR syntax error")
+		}
+		if offset < 0 || offset >= int64(len(members)) {
+			members = members[:0]
+		} else {
+			members = members[offset:]
+			if count >= 0 && count < int64(len(members)) {
+				members = members[:count]
+			}
+		}
+		break
+	}
+	return zsetReply(members, hasOption(args[4:], "WITHSCORES")), nil
+}
+
+func (m *MemoryStore) cmdZRemRangeByScore(args []string) (interface{}, error) {
+	e, err := m.entry(args[1], TYPE_ZSET)
+	if err != nil || e == nil {
+		return int64(0), err
+	}
+	members, err := m.scoreRange(e, args[2], args[3])
+	if err != nil {
+		return nil, err
+	}
+	for _, member := range members {
+		delete(e.ZSet, member.Member)
+	}
+	m.dropEmpty(args[1], e)
+	return int64(len(members)), nil
+}
+
+// ---- helpers ----
+
+// globPattern converts a Redis glob (*, ?, [abc], \x) into a regexp
+func globPattern(glob string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch c {
+		case '*':
+			b.WriteString("(?s:.*)")
+		case '?':
+			b.WriteString("(?s:.)")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(regexp.QuoteMeta(string(c)))
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "^") {
+				class = "^" + regexp.QuoteMeta(class[1:])
+			} else {
+				class = regexp.QuoteMeta(class)
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\-`, "-") + "]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+				b.WriteString(regexp.QuoteMeta(string(glob[i])))
+			}
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+func sortedKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func stringArgs(command string, values []string) []interface{} {
+	args := make([]interface{}, 0, len(values)+1)
+	args = append(args, command)
+	for _, value := range values {
+		args = append(args, value)
+	}
+	return args
+}
+
+func resultString(value interface{}, err error) (string, error) {
+	if err != nil {
+		return "", err
+	}
+	return value.(string), nil
+}
+
+func resultInt(value interface{}, err error) (int64, error) {
+	if err != nil {
+		return 0, err
+	}
+	return value.(int64), nil
+}
+
+func resultBool(value interface{}, err error) (bool, error) {
+	n, err := resultInt(value, err)
+	return n != 0, err
+}
+
+func resultFloat(value interface{}, err error) (float64, error) {
+	if err != nil {
+		return 0, err
+	}
+	return value.(float64), nil
+}
+
+func resultStrings(value interface{}, err error) ([]string, error) {
+	if err != nil {
+		return nil, err
+	}
+	items := value.([]interface{})
+	result := make([]string, len(items))
+	for i, item := range items {
+		result[i] = item.(string)
+	}
+	return result, nil
+}
+
+func resultZMembers(value interface{}, err error) ([]ZMember, error) {
+	if err != nil {
+		return nil, err
+	}
+	items := value.([]interface{})
+	members := make([]ZMember, len(items))
+	for i, item := range items {
+		pair := item.([]interface{})
+		members[i] = ZMember{Member: pair[0].(string), Score: pair[1].(float64)}
+	}
+	return members, nil
+}
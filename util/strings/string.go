@@ -3,6 +3,7 @@ package strings
 import (
 	"fmt"
 	"math/big"
+	"strconv"
 	"strings"
 )
 
@@ -25,6 +26,38 @@ func ToString(val interface{}) string {
 	}
 }
 
+// ToFloat converts numeric, boolean and numeric string values to float64
+func ToFloat(val interface{}) (float64, bool) {
+	switch value := val.(type) {
+	case float64:
+		return value, true
+	case float32:
+		return float64(value), true
+	case int:
+		return float64(value), true
+	case int32:
+		return float64(value), true
+	case int64:
+		return float64(value), true
+	case uint16:
+		return float64(value), true
+	case uint32:
+		return float64(value), true
+	case uint64:
+		return float64(value), true
+	case bool:
+		if value {
+			return 1, true
+		}
+		return 0, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
 func ParsePropertyMap(propertyString string) map[string]string {
 	if propertyString == "" {
 		return make(map[string]string)